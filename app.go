@@ -9,9 +9,9 @@ import (
 	"Cloak/server"
 	"database/sql"
 	"fyne.io/systray"
-	"github.com/pkg/browser"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/rs/zerolog"
 
@@ -36,6 +36,7 @@ type App struct {
 	releaseMode bool
 	config      *config.Configurator
 	configCh    chan map[string]string
+	stopWatcher func() // stops watching system suspend & screen lock, nil if not watching
 }
 
 // migrate runs database migrations
@@ -86,6 +87,28 @@ func (a *App) loadConfig() {
 	a.config.Load()
 }
 
+// watchSession starts or stops locking vaults on system suspend & screen lock.
+func (a *App) watchSession(enabled bool) error {
+	if a.stopWatcher != nil {
+		a.stopWatcher()
+		a.stopWatcher = nil
+	}
+	if !enabled {
+		return nil
+	}
+
+	stop, err := extension.WatchSession(func(event string) {
+		a.apiServer.LockVaultsOnSessionEvent(event)
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to watch system suspend & screen lock")
+		return err
+	}
+	a.stopWatcher = stop
+	logger.Debug().Msg("Watching system suspend & screen lock")
+	return nil
+}
+
 // NewApp constructs and returns a new App instance
 func NewApp() *App {
 	app := &App{
@@ -124,7 +147,13 @@ func NewApp() *App {
 	// Load app config
 	app.loadConfig()
 
-	app.apiServer = server.NewApiServer(app.repo, app.releaseMode, app.configCh)
+	app.apiServer = server.NewApiServer(app.repo, app.config, app.releaseMode, app.configCh)
+
+	// Session events lock vaults through the API server, so watch them only after it's created
+	app.config.SetCallback("lockonsuspend", func(v string) error {
+		return app.watchSession(v == "true")
+	})
+	_ = app.watchSession(app.config.Get("lockonsuspend") == "true")
 
 	logger.Debug().Msg("App created")
 	return app
@@ -141,11 +170,15 @@ func (a *App) Start() {
 	// i18n
 	translator := i18n.GetLocalizer()
 
-	// Setup menu items
-	openMenu := systray.AddMenuItem(translator.T("open"), "")
-	quitMenu := systray.AddMenuItem(translator.T("quit"), "")
+	// Setup menu items, they are rebuilt as vaults change
+	a.buildTray()
+	events, _ := a.apiServer.Subscribe()
+
+	// Tell users about vault events they might miss otherwise
+	a.watchNotifications()
 
 	go func() {
+		var rebuild <-chan time.Time
 		for {
 			select {
 			// Someone requested to change config, so we should notify the configurator
@@ -154,19 +187,25 @@ func (a *App) Start() {
 					for k, v := range kv {
 						a.config.Set(k, v)
 					}
+					// Clients reload config once told, so only tell them after it changed
+					a.apiServer.ConfigChanged(kv)
 				}
 			// Locale actually changed, so we're okay to load new localed strings from translator
 			case locale, ok := <-translator.Ch:
 				if ok {
 					logger.Debug().Str("locale", locale).Msg("Locale changed")
-					openMenu.SetTitle(translator.T("open"))
-					quitMenu.SetTitle(translator.T("quit"))
+					a.buildTray()
 				}
-			// Menu item events
-			case <-quitMenu.ClickedCh:
-				systray.Quit()
-			case <-openMenu.ClickedCh:
-				browser.OpenURL(a.apiServer.GetAccessUrl())
+			// Vaults changed, rebuild menu items after things settle down
+			case event, ok := <-events:
+				if !ok {
+					events = nil
+				} else if trayEvents[event.Type] && rebuild == nil {
+					rebuild = time.After(trayRebuildDelay)
+				}
+			case <-rebuild:
+				rebuild = nil
+				a.buildTray()
 			}
 		}
 	}()
@@ -179,6 +218,12 @@ func (a *App) Start() {
 
 // Stop stops the app
 func (a *App) Stop() {
+	if a.stopWatcher != nil {
+		a.stopWatcher()
+	}
+	if err := a.apiServer.Stop(); err != nil {
+		logger.Warn().Err(err).Msg("Failed to stop API server")
+	}
 	a.db.Close()
 	logger.Info().Msg("App stopped")
 }
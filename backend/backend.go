@@ -0,0 +1,297 @@
+// Package backend drives the command line tools which encrypt vaults, e.g. gocryptfs or CryFS.
+// Each tool has a driver implementing `Backend`, failures of the tools are reported as `ExitError`
+// with a cause shared by all drivers, so callers never deal with tool-specific exit codes.
+package backend
+
+import (
+	"Cloak/extension"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+var logger zerolog.Logger
+
+func init() {
+	logger = extension.GetLogger("backend")
+}
+
+// Backend types, as stored along with vaults
+const (
+	Gocryptfs = "gocryptfs" // https://github.com/rfjakob/gocryptfs
+	CryFS     = "cryfs"     // https://www.cryfs.org
+	Securefs  = "securefs"  // https://github.com/netheril96/securefs
+)
+
+// Names lists all backend types, the default one goes first.
+var Names = []string{Gocryptfs, CryFS, Securefs}
+
+// Causes of backend failures, `ExitError.Err` is one of them if the cause is known.
+// Some are returned as is, when the failure is detected before running the tool.
+var (
+	ErrUnknownBackend     = errors.New("unknown backend")
+	ErrUnsupported        = errors.New("not supported by this backend")
+	ErrDirNotEmpty        = errors.New("vault directory is not empty")
+	ErrMountpointNotEmpty = errors.New("mountpoint is not empty")
+	ErrPasswordEmpty      = errors.New("password is empty")
+	ErrWrongPassword      = errors.New("password incorrect")
+	ErrConfRead           = errors.New("vault config could not be read")
+	ErrConfWrite          = errors.New("vault config could not be written")
+)
+
+// ExitError is returned when a backend process failed.
+type ExitError struct {
+	Err    error  // cause of the failure, nil if it's unknown
+	RC     int    // exit code of the process
+	Output string // error output of the process
+}
+
+func (e *ExitError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%v (RC %d)", e.Err, e.RC)
+	}
+	return fmt.Sprintf("exited with RC %d: %s", e.RC, strings.TrimSpace(e.Output))
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// Features tells which optional features a backend supports.
+type Features struct {
+	Reverse        bool // reverse mode, an encrypted view of a plaintext directory
+	ChangePassword bool // changing vault password
+	IdleTimeout    bool // unmounting by itself after being idle for a while
+}
+
+// InitOptions holds options for creating a new vault.
+type InitOptions struct {
+	Reverse bool     // create a reverse vault, only if `Features.Reverse` is supported
+	Args    []string // extra backend-specific flags, e.g. `-xchacha` for gocryptfs
+}
+
+// PasswordOptions holds options for changing vault password.
+type PasswordOptions struct {
+	Reverse bool     // the vault is a reverse one
+	Args    []string // extra backend-specific flags, e.g. `-scryptn 17` for gocryptfs
+}
+
+// MountOptions holds options for mounting a vault.
+type MountOptions struct {
+	ReadOnly    bool
+	Reverse     bool          // mount a reverse vault, only if `Features.Reverse` is supported
+	IdleTimeout time.Duration // unmount after being idle for this long, 0 to disable. Only if `Features.IdleTimeout` is supported
+	VolumeName  string        // name shown by file managers, empty for the default one
+	FuseOptions []string      // extra FUSE options, e.g. `allow_other`
+	Debug       bool          // log debug messages to the output
+	Args        []string      // extra backend-specific flags, e.g. `-sharedstorage` for gocryptfs
+}
+
+// Backend is a driver for a tool encrypting vaults.
+type Backend interface {
+	// Name returns the backend type, e.g. `gocryptfs`.
+	Name() string
+	// Binary returns path of the tool.
+	Binary() string
+	// Init creates a new vault in empty directory `dir`, protected by `password`.
+	// The tool gets killed if `ctx` is done.
+	Init(ctx context.Context, dir string, password string, options InitOptions) error
+	// ChangePassword changes password of the vault in `dir`. The tool gets killed if `ctx` is done.
+	ChangePassword(ctx context.Context, dir string, password string, newPassword string, options PasswordOptions) error
+	// Mount returns a command mounting the vault in `dir` at `mountPoint` in foreground, with password piped through STDIN.
+	// The process keeps running until the vault gets unmounted, its exit should be checked with `MountError`.
+	Mount(dir string, mountPoint string, password string, options MountOptions) (*exec.Cmd, error)
+	// MountError converts exit code of a mount process into an error, nil if it exited because the vault got unmounted.
+	MountError(rc int, output string) error
+	// Unmount unmounts the vault mounted at `mountPoint`, lazily if `force` is true.
+	// `extension.ErrMountBusy` is returned if files are still open inside the vault.
+	Unmount(mountPoint string, force bool) error
+}
+
+// driver describes a backend type.
+type driver struct {
+	binary   string                    // name of the tool
+	fsType   string                    // type of mounted filesystems, as shown in mount tables
+	features Features                  // optional features supported
+	config   func(reverse bool) string // name of the config file in vault directory
+	new      func(cmd string) Backend  // creates a backend using given binary
+}
+
+var drivers = map[string]driver{
+	Gocryptfs: {
+		binary:   "gocryptfs",
+		fsType:   "fuse.gocryptfs",
+		features: Features{Reverse: true, ChangePassword: true, IdleTimeout: true},
+		config:   gocryptfsConfig,
+		new:      func(cmd string) Backend { return NewGocryptfs(cmd) },
+	},
+	CryFS: {
+		binary:   "cryfs",
+		fsType:   "fuse.cryfs",
+		features: Features{IdleTimeout: true},
+		config:   func(bool) string { return cryfsConfigName },
+		new:      func(cmd string) Backend { return NewCryFS(cmd) },
+	},
+	Securefs: {
+		binary:   "securefs",
+		fsType:   "fuse.securefs",
+		features: Features{ChangePassword: true},
+		config:   func(bool) string { return securefsConfigName },
+		new:      func(cmd string) Backend { return NewSecurefs(cmd) },
+	},
+}
+
+// lookup returns the driver of given backend type, an empty type means gocryptfs,
+// which was the only backend before backends became pluggable.
+func lookup(kind string) (driver, bool) {
+	if kind == "" {
+		kind = Gocryptfs
+	}
+	d, ok := drivers[kind]
+	return d, ok
+}
+
+// IsKnown tells whether given backend type is supported.
+func IsKnown(kind string) bool {
+	_, ok := lookup(kind)
+	return ok
+}
+
+// FeaturesOf returns optional features supported by given backend type.
+func FeaturesOf(kind string) Features {
+	d, _ := lookup(kind)
+	return d.features
+}
+
+// ConfigName returns name of the config file kept in vault directory by given backend type.
+func ConfigName(kind string, reverse bool) string {
+	d, ok := lookup(kind)
+	if !ok {
+		return ""
+	}
+	return d.config(reverse)
+}
+
+// ParseConfigName tells which backend type keeps config files of given name, and whether it's for reverse vaults.
+// An empty type is returned if no backend does.
+func ParseConfigName(name string) (kind string, reverse bool) {
+	for _, kind := range Names {
+		for _, reverse := range []bool{false, drivers[kind].features.Reverse} {
+			if ConfigName(kind, reverse) == name {
+				return kind, reverse
+			}
+		}
+	}
+	return "", false
+}
+
+// Detect returns type of the backend whose config file is found in `dir`, or an empty string if there's none.
+func Detect(dir string, reverse bool) string {
+	for _, kind := range Names {
+		if reverse && !drivers[kind].features.Reverse {
+			continue
+		}
+		if info, err := os.Stat(filepath.Join(dir, ConfigName(kind, reverse))); err == nil && info.Mode().IsRegular() {
+			return kind
+		}
+	}
+	return ""
+}
+
+// FromFsType returns type of the backend mounting filesystems of given type, e.g. `fuse.gocryptfs`,
+// or an empty string if it's not mounted by any backend.
+func FromFsType(fsType string) string {
+	for _, kind := range Names {
+		if drivers[kind].fsType == fsType {
+			return kind
+		}
+	}
+	return ""
+}
+
+// New creates a backend of given type using the tool at `cmd`.
+func New(kind string, cmd string) (Backend, error) {
+	d, ok := lookup(kind)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownBackend, kind)
+	}
+	return d.new(cmd), nil
+}
+
+// Locate creates a backend of given type, using its tool found next to us or in `PATH`.
+func Locate(kind string) (Backend, error) {
+	d, ok := lookup(kind)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownBackend, kind)
+	}
+	cmd, err := extension.LocateBinary(d.binary)
+	if err != nil {
+		return nil, err
+	}
+	return d.new(cmd), nil
+}
+
+// run runs a backend process with `input` piped through STDIN, until it exits or `ctx` is done.
+// If the process failed, `classify` converts its exit code and error output into an error.
+func run(ctx context.Context, proc *exec.Cmd, input string, classify func(rc int, output string) error) error {
+	var errorOutput bytes.Buffer
+	proc.Stdin = strings.NewReader(input)
+	proc.Stderr = &errorOutput
+	err := proc.Run()
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		// Failed to start
+		return err
+	}
+	return classify(exitErr.ExitCode(), errorOutput.String())
+}
+
+// checkEmptyDir makes sure `dir` is an empty directory, tools creating vaults on first mount would open existing ones otherwise.
+func checkEmptyDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	if len(entries) > 0 {
+		return ErrDirNotEmpty
+	}
+	return nil
+}
+
+// fuseMountpointNotEmpty matches the error printed by libfuse when mounting at a non-empty directory.
+var fuseMountpointNotEmpty = regexp.MustCompile(`(?i)mountpoint is not empty`)
+
+// fuseOptions returns FUSE options for mounting with given options, the volume name is set by FUSE on macOS.
+func fuseOptions(options MountOptions) []string {
+	var fuseOptions []string
+	if runtime.GOOS == "darwin" {
+		fuseOptions = append(fuseOptions, "local", "auto_xattr", "noappledouble")
+		if options.VolumeName != "" {
+			fuseOptions = append(fuseOptions, "volname="+options.VolumeName)
+		}
+	}
+	return append(fuseOptions, options.FuseOptions...)
+}
+
+// fuseUnmount unmounts FUSE filesystems, for backends which do nothing special about it.
+type fuseUnmount struct{}
+
+func (fuseUnmount) Unmount(mountPoint string, force bool) error {
+	return extension.Unmount(mountPoint, force)
+}
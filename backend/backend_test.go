@@ -0,0 +1,218 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type backendTestSuite struct {
+	suite.Suite
+	dir string // records arguments and STDIN of fake binaries
+}
+
+func (s *backendTestSuite) SetupTest() {
+	if runtime.GOOS == "windows" {
+		s.T().Skip("fake binaries are shell scripts")
+	}
+	s.dir = s.T().TempDir()
+}
+
+// fakeBinary creates a shell script named `name` which records its arguments and STDIN, then runs `body`.
+func (s *backendTestSuite) fakeBinary(name string, body string) string {
+	path := filepath.Join(s.dir, name)
+	script := "#!/bin/sh\n" +
+		`echo "$@" > "` + filepath.Join(s.dir, name+".args") + "\"\n" +
+		`cat > "` + filepath.Join(s.dir, name+".stdin") + "\"\n" +
+		body + "\n"
+	s.Require().NoError(os.WriteFile(path, []byte(script), 0755))
+	return path
+}
+
+// recorded returns arguments and STDIN recorded by the fake binary `name`.
+func (s *backendTestSuite) recorded(name string) (args string, stdin string) {
+	argsContent, err := os.ReadFile(filepath.Join(s.dir, name+".args"))
+	s.Require().NoError(err)
+	stdinContent, err := os.ReadFile(filepath.Join(s.dir, name+".stdin"))
+	s.Require().NoError(err)
+	return strings.TrimSpace(string(argsContent)), string(stdinContent)
+}
+
+func (s *backendTestSuite) Test_01_Registry() {
+	s.True(IsKnown(""))
+	s.True(IsKnown(CryFS))
+	s.False(IsKnown("veracrypt"))
+
+	s.Equal(Features{Reverse: true, ChangePassword: true, IdleTimeout: true}, FeaturesOf(""))
+	s.Equal(Features{IdleTimeout: true}, FeaturesOf(CryFS))
+	s.Equal(Features{ChangePassword: true}, FeaturesOf(Securefs))
+
+	s.Equal("gocryptfs.conf", ConfigName(Gocryptfs, false))
+	s.Equal(".gocryptfs.reverse.conf", ConfigName(Gocryptfs, true))
+	s.Equal("cryfs.config", ConfigName(CryFS, false))
+	s.Equal(".securefs.json", ConfigName(Securefs, false))
+	s.Equal("", ConfigName("veracrypt", false))
+
+	kind, reverse := ParseConfigName(".gocryptfs.reverse.conf")
+	s.Equal(Gocryptfs, kind)
+	s.True(reverse)
+	kind, reverse = ParseConfigName("cryfs.config")
+	s.Equal(CryFS, kind)
+	s.False(reverse)
+	kind, _ = ParseConfigName("notes.txt")
+	s.Equal("", kind)
+
+	s.Equal(Gocryptfs, FromFsType("fuse.gocryptfs"))
+	s.Equal(CryFS, FromFsType("fuse.cryfs"))
+	s.Equal("", FromFsType("ext4"))
+
+	_, err := New("veracrypt", "/usr/bin/veracrypt")
+	s.ErrorIs(err, ErrUnknownBackend)
+	b, err := New(Securefs, "/usr/bin/securefs")
+	s.Require().NoError(err)
+	s.Equal(Securefs, b.Name())
+	s.Equal("/usr/bin/securefs", b.Binary())
+}
+
+func (s *backendTestSuite) Test_02_Detect() {
+	dir := s.T().TempDir()
+	s.Equal("", Detect(dir, false))
+
+	s.Require().NoError(os.WriteFile(filepath.Join(dir, "cryfs.config"), []byte("encrypted"), 0600))
+	s.Equal(CryFS, Detect(dir, false))
+	s.Equal("", Detect(dir, true))
+
+	s.Require().NoError(os.WriteFile(filepath.Join(dir, ".gocryptfs.reverse.conf"), []byte("{}"), 0600))
+	s.Equal(Gocryptfs, Detect(dir, true))
+}
+
+func (s *backendTestSuite) Test_03_GocryptfsInit() {
+	g := NewGocryptfs(s.fakeBinary("gocryptfs", "exit 0"))
+	s.Require().NoError(g.Init(context.Background(), "/vault", "secret", InitOptions{Reverse: true, Args: []string{"-xchacha"}}))
+	args, stdin := s.recorded("gocryptfs")
+	s.Equal("-init -reverse -xchacha -- /vault", args)
+	s.Equal("secret", stdin)
+
+	s.Require().NoError(g.ChangePassword(context.Background(), "/vault", "old", "new", PasswordOptions{Args: []string{"-scryptn", "17"}}))
+	args, stdin = s.recorded("gocryptfs")
+	s.Equal("-passwd -scryptn 17 -- /vault", args)
+	s.Equal("old\nnew", stdin)
+
+	// Exit codes are converted to causes
+	g = NewGocryptfs(s.fakeBinary("gocryptfs", "exit 6"))
+	err := g.Init(context.Background(), "/vault", "secret", InitOptions{})
+	s.ErrorIs(err, ErrDirNotEmpty)
+	var exitErr *ExitError
+	s.Require().ErrorAs(err, &exitErr)
+	s.Equal(6, exitErr.RC)
+
+	g = NewGocryptfs(s.fakeBinary("gocryptfs", "exit 12"))
+	s.ErrorIs(g.ChangePassword(context.Background(), "/vault", "old", "new", PasswordOptions{}), ErrWrongPassword)
+
+	// Unknown failures keep the error output
+	g = NewGocryptfs(s.fakeBinary("gocryptfs", "echo 'something broke' >&2; exit 99"))
+	err = g.Init(context.Background(), "/vault", "secret", InitOptions{})
+	s.Require().ErrorAs(err, &exitErr)
+	s.Nil(exitErr.Err)
+	s.Equal(99, exitErr.RC)
+	s.Contains(exitErr.Output, "something broke")
+
+	// The process gets killed once the context is done
+	g = NewGocryptfs(s.fakeBinary("gocryptfs", "exec sleep 10"))
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	s.ErrorIs(g.Init(ctx, "/vault", "secret", InitOptions{}), context.DeadlineExceeded)
+}
+
+func (s *backendTestSuite) Test_04_GocryptfsMount() {
+	if runtime.GOOS != "linux" {
+		s.T().Skip("FUSE options differ on macOS")
+	}
+	g := NewGocryptfs(s.fakeBinary("gocryptfs", "echo '-fsname string'"))
+	proc, err := g.Mount("/vault", "/mnt", "secret", MountOptions{
+		ReadOnly:    true,
+		IdleTimeout: time.Hour,
+		VolumeName:  "work",
+		FuseOptions: []string{"noatime"},
+		Debug:       true,
+		Args:        []string{"-sharedstorage"},
+	})
+	s.Require().NoError(err)
+	s.Equal([]string{
+		g.Binary(), "-fg", "-debug", "-sharedstorage", "-fsname", "work", "-ko", "noatime", "-idle", "1h0m0s", "-ro", "--", "/vault", "/mnt",
+	}, proc.Args)
+
+	s.NoError(g.MountError(0, ""))
+	s.NoError(g.MountError(gocryptfsExitSigInt, ""))
+	s.ErrorIs(g.MountError(gocryptfsExitMountPoint, ""), ErrMountpointNotEmpty)
+}
+
+func (s *backendTestSuite) Test_05_CryFS() {
+	if runtime.GOOS != "linux" {
+		s.T().Skip("vaults are unmounted by fusermount on Linux only")
+	}
+	// Creating vaults unmounts them right away
+	s.fakeBinary("fusermount", "exit 0")
+	s.T().Setenv("PATH", s.dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	c := NewCryFS(s.fakeBinary("cryfs", `[ "$CRYFS_FRONTEND" = noninteractive ] || exit 1`))
+	vaultDir := s.T().TempDir()
+	s.Require().NoError(c.Init(context.Background(), vaultDir, "secret", InitOptions{}))
+	args, stdin := s.recorded("cryfs")
+	s.True(strings.HasPrefix(args, vaultDir+" "))
+	s.Equal("secret\n", stdin)
+	args, _ = s.recorded("fusermount")
+	s.True(strings.HasPrefix(args, "-u -- "))
+
+	s.ErrorIs(c.Init(context.Background(), vaultDir, "", InitOptions{}), ErrPasswordEmpty)
+	s.ErrorIs(c.Init(context.Background(), vaultDir, "secret", InitOptions{Reverse: true}), ErrUnsupported)
+	s.Require().NoError(os.WriteFile(filepath.Join(vaultDir, "cryfs.config"), []byte("encrypted"), 0600))
+	s.ErrorIs(c.Init(context.Background(), vaultDir, "secret", InitOptions{}), ErrDirNotEmpty)
+	s.ErrorIs(c.ChangePassword(context.Background(), vaultDir, "secret", "new", PasswordOptions{}), ErrUnsupported)
+
+	proc, err := c.Mount(vaultDir, "/mnt", "secret", MountOptions{ReadOnly: true, IdleTimeout: 90 * time.Second})
+	s.Require().NoError(err)
+	s.Equal([]string{c.Binary(), "-f", "--unmount-idle", "1.5", vaultDir, "/mnt", "--", "-o", "ro"}, proc.Args)
+	s.Contains(proc.Env, "CRYFS_FRONTEND=noninteractive")
+
+	s.ErrorIs(c.MountError(cryfsExitPassword, ""), ErrWrongPassword)
+	s.ErrorIs(c.MountError(1, "fuse: mountpoint is not empty"), ErrMountpointNotEmpty)
+}
+
+func (s *backendTestSuite) Test_06_Securefs() {
+	f := NewSecurefs(s.fakeBinary("securefs", "exit 0"))
+	vaultDir := s.T().TempDir()
+	s.Require().NoError(f.Init(context.Background(), vaultDir, "secret", InitOptions{}))
+	args, stdin := s.recorded("securefs")
+	s.Equal("create "+vaultDir, args)
+	s.Equal("secret\nsecret\n", stdin)
+
+	s.Require().NoError(f.ChangePassword(context.Background(), vaultDir, "old", "new", PasswordOptions{}))
+	args, stdin = s.recorded("securefs")
+	s.Equal("chpass "+vaultDir, args)
+	s.Equal("old\nnew\nnew\n", stdin)
+
+	// securefs exits with 1 on most failures, causes are told by its output
+	f = NewSecurefs(s.fakeBinary("securefs", "echo 'Error: Invalid password' >&2; exit 1"))
+	s.ErrorIs(f.ChangePassword(context.Background(), vaultDir, "old", "new", PasswordOptions{}), ErrWrongPassword)
+	s.ErrorIs(f.MountError(1, "Error opening .securefs.json: No such file or directory"), ErrConfRead)
+	err := f.MountError(1, "Segmentation fault")
+	var exitErr *ExitError
+	s.Require().ErrorAs(err, &exitErr)
+	s.Nil(exitErr.Err)
+	s.False(errors.Is(err, ErrWrongPassword))
+
+	_, err = f.Mount(vaultDir, "/mnt", "secret", MountOptions{IdleTimeout: time.Minute})
+	s.ErrorIs(err, ErrUnsupported)
+}
+
+func Test_Backend(t *testing.T) {
+	suite.Run(t, new(backendTestSuite))
+}
@@ -0,0 +1,151 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// cryfsConfigName is name of the CryFS config file, in the vault directory.
+const cryfsConfigName = "cryfs.config"
+
+// Exit codes of CryFS, see https://github.com/cryfs/cryfs/blob/develop/src/cryfs/impl/ErrorCodes.h
+const (
+	cryfsExitPassword        = 11 // password incorrect
+	cryfsExitPasswordEmpty   = 12 // password is empty when creating a vault
+	cryfsExitInvalidFs       = 19 // cryfs.config is missing or malformed
+	cryfsExitInaccessibleDir = 16 // vault directory does not exist or is not accessible
+)
+
+// CryFSBackend drives CryFS. CryFS cannot change passwords, nor create vaults without mounting them.
+type CryFSBackend struct {
+	fuseUnmount
+	cmd string
+}
+
+// NewCryFS creates a CryFS backend using the binary at `cmd`.
+func NewCryFS(cmd string) *CryFSBackend {
+	return &CryFSBackend{cmd: cmd}
+}
+
+func (c *CryFSBackend) Name() string {
+	return CryFS
+}
+
+func (c *CryFSBackend) Binary() string {
+	return c.cmd
+}
+
+// withEnv makes given CryFS process never ask questions, reading the password from STDIN instead.
+func withEnv(proc *exec.Cmd) *exec.Cmd {
+	proc.Env = append(os.Environ(), "CRYFS_FRONTEND=noninteractive", "CRYFS_NO_UPDATE_CHECK=true")
+	return proc
+}
+
+// Init creates a new vault by mounting the empty directory in background, then unmounting it right away.
+func (c *CryFSBackend) Init(ctx context.Context, dir string, password string, options InitOptions) error {
+	if options.Reverse {
+		return ErrUnsupported
+	}
+	if password == "" {
+		return ErrPasswordEmpty
+	}
+	if err := checkEmptyDir(dir); err != nil {
+		return err
+	}
+	mountPoint, err := os.MkdirTemp("", "cloak-cryfs-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(mountPoint)
+
+	// CryFS keeps running in background with inherited output, so the output goes to a file instead of a pipe,
+	// otherwise we would wait for the pipe to be closed until the vault got unmounted.
+	output, err := os.CreateTemp("", "cloak-cryfs-*.log")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(output.Name())
+	defer output.Close()
+
+	args := append([]string{}, options.Args...)
+	args = append(args, dir, mountPoint)
+	proc := withEnv(exec.CommandContext(ctx, c.cmd, args...))
+	proc.Stdin = strings.NewReader(password + "\n")
+	proc.Stdout = output
+	proc.Stderr = output
+	if err := proc.Run(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return err
+		}
+		content, _ := os.ReadFile(output.Name())
+		return c.exitError(exitErr.ExitCode(), string(content))
+	}
+	return c.Unmount(mountPoint, false)
+}
+
+func (c *CryFSBackend) ChangePassword(context.Context, string, string, string, PasswordOptions) error {
+	return ErrUnsupported
+}
+
+func (c *CryFSBackend) Mount(dir string, mountPoint string, password string, options MountOptions) (*exec.Cmd, error) {
+	if options.Reverse {
+		return nil, ErrUnsupported
+	}
+	args := []string{"-f"}
+	if options.IdleTimeout > 0 {
+		// In minutes, fractions are allowed
+		args = append(args, "--unmount-idle", strconv.FormatFloat(options.IdleTimeout.Minutes(), 'f', -1, 64))
+	}
+	args = append(args, options.Args...)
+	args = append(args, dir, mountPoint)
+
+	// FUSE options go after CryFS ones, FUSE names the volume on macOS
+	fuseOptions := fuseOptions(options)
+	if options.ReadOnly {
+		fuseOptions = append(fuseOptions, "ro")
+	}
+	if options.VolumeName != "" && runtime.GOOS != "darwin" {
+		fuseOptions = append(fuseOptions, "fsname="+options.VolumeName)
+	}
+	if len(fuseOptions) > 0 {
+		args = append(args, "--", "-o", strings.Join(fuseOptions, ","))
+	}
+
+	proc := withEnv(exec.Command(c.cmd, args...))
+	proc.Stdin = strings.NewReader(password + "\n")
+	return proc, nil
+}
+
+func (c *CryFSBackend) MountError(rc int, output string) error {
+	if rc == 0 {
+		return nil
+	}
+	return c.exitError(rc, output)
+}
+
+// exitError converts exit code of CryFS into an error.
+func (c *CryFSBackend) exitError(rc int, output string) error {
+	exitErr := &ExitError{RC: rc, Output: output}
+	switch rc {
+	case cryfsExitPassword:
+		exitErr.Err = ErrWrongPassword
+	case cryfsExitPasswordEmpty:
+		exitErr.Err = ErrPasswordEmpty
+	case cryfsExitInvalidFs, cryfsExitInaccessibleDir:
+		exitErr.Err = ErrConfRead
+	default:
+		if fuseMountpointNotEmpty.MatchString(output) {
+			exitErr.Err = ErrMountpointNotEmpty
+		}
+	}
+	return exitErr
+}
@@ -0,0 +1,175 @@
+package backend
+
+import (
+	"Cloak/gocryptfs"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Exit codes of gocryptfs, see https://github.com/rfjakob/gocryptfs/blob/master/internal/exitcodes/exitcodes.go
+const (
+	gocryptfsExitCipherDir     = 6  // CIPHERDIR is not an empty directory when initializing
+	gocryptfsExitMountPoint    = 10 // mountpoint is not an empty directory
+	gocryptfsExitPassword      = 12 // password incorrect
+	gocryptfsExitSigInt        = 15 // interrupted by SIGINT, after unmounting the vault
+	gocryptfsExitPasswordEmpty = 22 // password is empty when initializing
+	gocryptfsExitReadConf      = 23 // gocryptfs.conf could not be read
+	gocryptfsExitWriteConf     = 24 // gocryptfs.conf could not be written
+)
+
+// gocryptfsConfig returns name of the gocryptfs config file.
+func gocryptfsConfig(reverse bool) string {
+	if reverse {
+		return gocryptfs.ConfReverseName
+	}
+	return gocryptfs.ConfDefaultName
+}
+
+// GocryptfsBackend drives gocryptfs.
+// Besides `Backend`, it supports resetting passwords with master keys, and tells which flags the binary supports.
+type GocryptfsBackend struct {
+	fuseUnmount
+	cmd string
+
+	flagsOnce sync.Once       // guards detection of `flags`
+	flags     map[string]bool // command line flags supported by the binary
+}
+
+// NewGocryptfs creates a gocryptfs backend using the binary at `cmd`.
+func NewGocryptfs(cmd string) *GocryptfsBackend {
+	return &GocryptfsBackend{cmd: cmd}
+}
+
+func (g *GocryptfsBackend) Name() string {
+	return Gocryptfs
+}
+
+func (g *GocryptfsBackend) Binary() string {
+	return g.cmd
+}
+
+// Supports tells whether the gocryptfs binary supports given command line flag, e.g. `-xchacha`.
+// Supported flags are detected once from the full help text (`gocryptfs -hh`).
+func (g *GocryptfsBackend) Supports(flag string) bool {
+	g.flagsOnce.Do(func() {
+		g.flags = make(map[string]bool)
+		// gocryptfs might exit with non-zero code after printing help, so the error is not fatal
+		output, err := exec.Command(g.cmd, "-hh").CombinedOutput()
+		for _, field := range strings.Fields(string(output)) {
+			if strings.HasPrefix(field, "-") {
+				g.flags[strings.TrimRight(field, ",.:")] = true
+			}
+		}
+		logger.Debug().Err(err).
+			Int("flags", len(g.flags)).
+			Msg("Detected flags supported by gocryptfs")
+	})
+	return g.flags[flag]
+}
+
+func (g *GocryptfsBackend) Init(ctx context.Context, dir string, password string, options InitOptions) error {
+	args := []string{"-init"}
+	if options.Reverse {
+		args = append(args, "-reverse")
+	}
+	args = append(args, options.Args...)
+	args = append(args, "--", dir)
+	return run(ctx, exec.CommandContext(ctx, g.cmd, args...), password, g.exitError)
+}
+
+func (g *GocryptfsBackend) ChangePassword(ctx context.Context, dir string, password string, newPassword string, options PasswordOptions) error {
+	return run(ctx, exec.CommandContext(ctx, g.cmd, passwdArgs(dir, options.Reverse, options.Args...)...), password+"\n"+newPassword, g.exitError)
+}
+
+// ResetPassword resets password of the vault in `dir` using its master key, formatted as gocryptfs prints it.
+// gocryptfs keeps a backup of the original config, it's renamed after its creation time
+// so the next reset won't conflict with it. gocryptfs gets killed if `ctx` is done.
+func (g *GocryptfsBackend) ResetPassword(ctx context.Context, dir string, masterKey string, newPassword string, reverse bool) error {
+	proc := exec.CommandContext(ctx, g.cmd, passwdArgs(dir, reverse, "-masterkey", masterKey)...)
+	if err := run(ctx, proc, newPassword+"\n"+newPassword, g.exitError); err != nil {
+		return err
+	}
+
+	backup := filepath.Join(dir, gocryptfsConfig(reverse)) + ".bak"
+	backupWithTime := fmt.Sprintf("%s.%s", backup, time.Now().UTC().Format("2006-01-02T15:04:05.000 MST"))
+	if err := os.Rename(backup, backupWithTime); err != nil && !os.IsNotExist(err) {
+		logger.Warn().Err(err).
+			Str("vaultPath", dir).
+			Str("from", backup).
+			Str("to", backupWithTime).
+			Msg("Failed to rename backup of original gocryptfs.conf file")
+	}
+	return nil
+}
+
+// passwdArgs returns gocryptfs arguments for changing password of the vault in `dir`, with `extra` flags.
+func passwdArgs(dir string, reverse bool, extra ...string) []string {
+	args := append([]string{"-passwd"}, extra...)
+	if reverse {
+		args = append(args, "-reverse")
+	}
+	return append(args, "--", dir)
+}
+
+func (g *GocryptfsBackend) Mount(dir string, mountPoint string, password string, options MountOptions) (*exec.Cmd, error) {
+	args := []string{"-fg"}
+	if options.Debug {
+		args = append(args, "-debug")
+	}
+	args = append(args, options.Args...)
+	// FUSE names the volume on macOS
+	if options.VolumeName != "" && runtime.GOOS != "darwin" && g.Supports("-fsname") {
+		args = append(args, "-fsname", options.VolumeName)
+	}
+	if fuseOptions := fuseOptions(options); len(fuseOptions) > 0 {
+		args = append(args, "-ko", strings.Join(fuseOptions, ","))
+	}
+	if options.IdleTimeout > 0 {
+		args = append(args, "-idle", options.IdleTimeout.String())
+	}
+	if options.Reverse {
+		args = append(args, "-reverse")
+	}
+	if options.ReadOnly {
+		args = append(args, "-ro")
+	}
+	args = append(args, "--", dir, mountPoint)
+
+	proc := exec.Command(g.cmd, args...)
+	proc.Stdin = strings.NewReader(password)
+	return proc, nil
+}
+
+func (g *GocryptfsBackend) MountError(rc int, output string) error {
+	if rc == 0 || rc == gocryptfsExitSigInt {
+		return nil
+	}
+	return g.exitError(rc, output)
+}
+
+// exitError converts exit code of gocryptfs into an error, exit codes mean the same in all modes.
+func (g *GocryptfsBackend) exitError(rc int, output string) error {
+	exitErr := &ExitError{RC: rc, Output: output}
+	switch rc {
+	case gocryptfsExitCipherDir:
+		exitErr.Err = ErrDirNotEmpty
+	case gocryptfsExitMountPoint:
+		exitErr.Err = ErrMountpointNotEmpty
+	case gocryptfsExitPassword:
+		exitErr.Err = ErrWrongPassword
+	case gocryptfsExitPasswordEmpty:
+		exitErr.Err = ErrPasswordEmpty
+	case gocryptfsExitReadConf:
+		exitErr.Err = ErrConfRead
+	case gocryptfsExitWriteConf:
+		exitErr.Err = ErrConfWrite
+	}
+	return exitErr
+}
@@ -0,0 +1,111 @@
+package backend
+
+import (
+	"context"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// securefsConfigName is name of the securefs config file, in the vault directory.
+const securefsConfigName = ".securefs.json"
+
+// securefs exits with 1 on most failures, so causes are told by its output instead
+var (
+	securefsWrongPassword = regexp.MustCompile(`(?i)(invalid|wrong|incorrect) password|password.*(invalid|incorrect|mismatch)`)
+	securefsConfRead      = regexp.MustCompile(`(?i)` + regexp.QuoteMeta(securefsConfigName) + `.*(no such file|permission denied|parse|invalid)`)
+)
+
+// SecurefsBackend drives securefs. securefs vaults never unmount by themselves after being idle.
+type SecurefsBackend struct {
+	fuseUnmount
+	cmd string
+}
+
+// NewSecurefs creates a securefs backend using the binary at `cmd`.
+func NewSecurefs(cmd string) *SecurefsBackend {
+	return &SecurefsBackend{cmd: cmd}
+}
+
+func (s *SecurefsBackend) Name() string {
+	return Securefs
+}
+
+func (s *SecurefsBackend) Binary() string {
+	return s.cmd
+}
+
+// Init creates a new vault, securefs asks for the password twice.
+func (s *SecurefsBackend) Init(ctx context.Context, dir string, password string, options InitOptions) error {
+	if options.Reverse {
+		return ErrUnsupported
+	}
+	if password == "" {
+		return ErrPasswordEmpty
+	}
+	if err := checkEmptyDir(dir); err != nil {
+		return err
+	}
+	args := append([]string{"create"}, options.Args...)
+	args = append(args, dir)
+	return run(ctx, exec.CommandContext(ctx, s.cmd, args...), password+"\n"+password+"\n", s.exitError)
+}
+
+// ChangePassword changes vault password, securefs asks for the new password twice.
+func (s *SecurefsBackend) ChangePassword(ctx context.Context, dir string, password string, newPassword string, options PasswordOptions) error {
+	if options.Reverse {
+		return ErrUnsupported
+	}
+	args := append([]string{"chpass"}, options.Args...)
+	args = append(args, dir)
+	input := strings.Join([]string{password, newPassword, newPassword}, "\n") + "\n"
+	return run(ctx, exec.CommandContext(ctx, s.cmd, args...), input, s.exitError)
+}
+
+func (s *SecurefsBackend) Mount(dir string, mountPoint string, password string, options MountOptions) (*exec.Cmd, error) {
+	if options.Reverse || options.IdleTimeout > 0 {
+		return nil, ErrUnsupported
+	}
+	// securefs stays in foreground unless told otherwise
+	args := []string{"mount"}
+	if options.Debug {
+		args = append(args, "--trace")
+	}
+	if options.VolumeName != "" {
+		args = append(args, "--fsname", options.VolumeName)
+	}
+	fuseOptions := fuseOptions(options)
+	if options.ReadOnly {
+		fuseOptions = append(fuseOptions, "ro")
+	}
+	for _, option := range fuseOptions {
+		args = append(args, "-o", option)
+	}
+	args = append(args, options.Args...)
+	args = append(args, dir, mountPoint)
+
+	proc := exec.Command(s.cmd, args...)
+	proc.Stdin = strings.NewReader(password + "\n")
+	return proc, nil
+}
+
+func (s *SecurefsBackend) MountError(rc int, output string) error {
+	if rc == 0 {
+		return nil
+	}
+	return s.exitError(rc, output)
+}
+
+// exitError converts a failure of securefs into an error, by looking into its output.
+func (s *SecurefsBackend) exitError(rc int, output string) error {
+	exitErr := &ExitError{RC: rc, Output: output}
+	switch {
+	case securefsWrongPassword.MatchString(output):
+		exitErr.Err = ErrWrongPassword
+	case securefsConfRead.MatchString(output):
+		exitErr.Err = ErrConfRead
+	case fuseMountpointNotEmpty.MatchString(output):
+		exitErr.Err = ErrMountpointNotEmpty
+	}
+	return exitErr
+}
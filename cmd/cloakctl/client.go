@@ -0,0 +1,228 @@
+package main
+
+import (
+	"Cloak/instance"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// response mirrors the JSON representation of `server.ApiError` and `server.DataContainer`.
+type response struct {
+	Code  int             `json:"code"`
+	Msg   string          `json:"msg"`
+	Item  json.RawMessage `json:"item,omitempty"`
+	Items json.RawMessage `json:"items,omitempty"`
+	State string          `json:"state,omitempty"`
+	Log   []string        `json:"log,omitempty"`
+}
+
+// apiError is returned when the API responds with a non-zero error code.
+type apiError struct {
+	Code    int
+	Message string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// connectionError is returned when the running instance cannot be found or reached.
+type connectionError struct {
+	err error
+}
+
+func (e *connectionError) Error() string {
+	return fmt.Sprintf("cannot reach Cloak app: %v", e.err)
+}
+
+// client talks to the API server of a running Cloak app.
+// The app is located on the first request, so that arguments are checked before that.
+type client struct {
+	baseUrl string // empty until the app is located
+	token   string
+	http    *http.Client
+}
+
+// newClient creates a client for the running Cloak app instance.
+func newClient() *client {
+	// Some operations (e.g. creating a vault) run scrypt and might take a while
+	return &client{http: &http.Client{Timeout: time.Minute * 5}}
+}
+
+// connect locates the running Cloak app instance, unless it's located already.
+// Commands asking for passwords call it first, users should not type a password for nothing.
+func (c *client) connect() error {
+	if c.baseUrl != "" {
+		return nil
+	}
+	info, err := instance.Read()
+	if err != nil {
+		return &connectionError{err}
+	}
+	c.baseUrl = fmt.Sprintf("http://%s/api", info.Address)
+	c.token = info.Token
+	return nil
+}
+
+// call sends a request to given API path, `form` is sent as JSON body if not nil.
+// A non-zero error code in the response is converted to *apiError.
+func (c *client) call(method string, api string, form interface{}) (*response, error) {
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+	var body bytes.Buffer
+	if form != nil {
+		if err := json.NewEncoder(&body).Encode(form); err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequest(method, c.baseUrl+"/"+strings.TrimPrefix(api, "/"), &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, &connectionError{err}
+	}
+	defer resp.Body.Close()
+
+	var result response
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("malformed API response (HTTP %d): %w", resp.StatusCode, err)
+	}
+	if result.Code != 0 {
+		return &result, &apiError{Code: result.Code, Message: result.Msg}
+	}
+	return &result, nil
+}
+
+// download sends a GET request to given API path and copies the response body to `w`, for APIs exporting files.
+// An API error responded instead of the file is converted to *apiError.
+func (c *client) download(api string, w io.Writer) error {
+	if err := c.connect(); err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodGet, c.baseUrl+"/"+strings.TrimPrefix(api, "/"), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return &connectionError{err}
+	}
+	defer resp.Body.Close()
+
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") && resp.Header.Get("Content-Disposition") == "" {
+		var result response
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return fmt.Errorf("malformed API response (HTTP %d): %w", resp.StatusCode, err)
+		}
+		return &apiError{Code: result.Code, Message: result.Msg}
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// vault is the vault representation returned by `GET /api/vaults`.
+type vault struct {
+	ID         int64    `json:"id"`
+	Path       string   `json:"path"`
+	MountPoint string   `json:"mountpoint"`
+	AutoReveal bool     `json:"autoreveal"`
+	ReadOnly   bool     `json:"readonly"`
+	DebugMount bool     `json:"debugmount"`
+	State      string   `json:"state"`
+	Missing    bool     `json:"missing,omitempty"`
+	MountOpts  []string `json:"mountoptions,omitempty"`
+	Name       string   `json:"name,omitempty"`
+	Notes      string   `json:"notes,omitempty"`
+	Icon       string   `json:"icon,omitempty"`
+	Identity   string   `json:"identity,omitempty"`
+	Backend    string   `json:"backend"`
+	Groups     []struct {
+		ID   int64  `json:"id"`
+		Name string `json:"name"`
+	} `json:"groups,omitempty"`
+	Tags []string `json:"tags,omitempty"`
+}
+
+// label returns the name shown for this vault, which is its display name or its directory name.
+func (v vault) label() string {
+	if v.Name != "" {
+		return v.Name
+	}
+	return filepath.Base(v.Path)
+}
+
+// group is the group representation returned by `GET /api/groups`.
+type group struct {
+	ID     int64   `json:"id"`
+	Name   string  `json:"name"`
+	Vaults []int64 `json:"vaults"`
+}
+
+// listGroups returns all groups.
+func (c *client) listGroups() ([]group, error) {
+	resp, err := c.call(http.MethodGet, "groups", nil)
+	if err != nil {
+		return nil, err
+	}
+	var groups []group
+	if len(resp.Items) > 0 {
+		if err := json.Unmarshal(resp.Items, &groups); err != nil {
+			return nil, err
+		}
+	}
+	return groups, nil
+}
+
+// updateVaultOptions updates some options of given vault.
+// Options the API always expects are sent along with their current values, or they'd be reset.
+func (c *client) updateVaultOptions(vaultId int64, changes map[string]interface{}) error {
+	vaults, err := c.listVaults()
+	if err != nil {
+		return err
+	}
+	for _, v := range vaults {
+		if v.ID != vaultId {
+			continue
+		}
+		form := map[string]interface{}{
+			"autoreveal": v.AutoReveal,
+			"readonly":   v.ReadOnly,
+			"mountpoint": v.MountPoint,
+		}
+		for key, value := range changes {
+			form[key] = value
+		}
+		_, err = c.call(http.MethodPost, fmt.Sprintf("vault/%d/options", vaultId), form)
+		return err
+	}
+	return &usageError{fmt.Sprintf("no vault found with ID %d", vaultId)}
+}
+
+// listVaults returns all known vaults.
+func (c *client) listVaults() ([]vault, error) {
+	resp, err := c.call(http.MethodGet, "vaults", nil)
+	if err != nil {
+		return nil, err
+	}
+	var vaults []vault
+	if len(resp.Items) > 0 {
+		if err := json.Unmarshal(resp.Items, &vaults); err != nil {
+			return nil, err
+		}
+	}
+	return vaults, nil
+}
@@ -0,0 +1,1423 @@
+// Command cloakctl controls vaults managed by a running Cloak app from the command line.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// Exit codes are part of the command line interface, keep them stable.
+const (
+	exitOk           = 0  // operation succeeded
+	exitFailure      = 1  // unexpected failure, e.g. malformed API response
+	exitUsage        = 2  // invalid command line arguments
+	exitNoInstance   = 3  // no running Cloak app found, or it cannot be reached
+	exitApiErrorBase = 10 // an API error with code N results in exit code 10+N
+)
+
+const usage = `Usage: cloakctl <command> [arguments]
+
+Commands:
+  list [-json]                   List all vaults and their states
+  add <path>                     Add an existing vault, <path> is the vault directory or its config file
+  create [-backend TYPE] [flags] <directory> <name>
+                                 Create a new vault named <name> inside <directory>, TYPE is gocryptfs (default),
+                                 cryfs or securefs, flags are gocryptfs features: -plaintextnames -aessiv -xchacha
+                                 -deterministic-names -longnamemax N -scryptn N
+  create -reverse [flags] [-exclude P ...] [-exclude-wildcard P ...] <directory>
+                                 Turn plaintext <directory> into a reverse vault, for encrypted backups
+  unlock [-stored] <vault>       Unlock a vault, -stored uses the password stored in system keyring
+  lock [-force] <vault>          Lock a vault, -force unmounts it even if files are still open
+  info <vault>                   Show vault metadata from its gocryptfs config
+  benchmark [-target MS]         Suggest the scrypt cost (-scryptn) for unlocking to take about MS milliseconds
+  upgrade-kdf [-scryptn N] <vault>
+                                 Re-wrap the master key of a locked vault with scrypt cost N, keeping its password,
+                                 N defaults to the suggestion of benchmark
+  reveal [-vault] <vault>        Reveal mountpoint of an unlocked vault (or the vault directory) in file manager
+  passwd [-masterkey] <vault>    Change vault password, or reset it using the master key
+  forget <vault>                 Forget vault password stored in system keyring
+  label [-name NAME] [-notes TEXT] [-icon KEY] <vault>
+                                 Set display name, notes or colour/icon key of a vault, empty values clear them
+  mountopts <vault> [<option> ...]
+                                 Replace advanced mount options of a vault, no options to clear them, allowed:
+                                 -allow_other -force_owner=UID:GID -kernel_cache -sharedstorage -noprealloc
+                                 -nosyslog -fsname=NAME -ko=FUSE_OPTIONS
+  log [-debug on|off] <vault>    Show output of gocryptfs which unlocked a vault most recently,
+                                 -debug turns debug mode on or off for the next unlock
+  relocate [-depth N] <vault> <directory> [<directory> ...]
+                                 Search directories for a vault which was moved, and update its path,
+                                 N directory levels deep (4 by default)
+  fsck [-stored] <vault>         Check integrity of a locked vault, corrupt paths are printed
+  fsck -cancel <vault>           Cancel the running check of a vault
+  tag <vault> [<tag> ...]        Replace tags of a vault, no tags to clear them
+  groups                         List vault groups
+  group create <name> [<vault> ...]
+                                 Create a vault group
+  group set <group> [<vault> ...]
+                                 Replace vaults of a group
+  group rename <group> <name>    Rename a vault group
+  group delete <group>           Delete a vault group, its vaults are kept
+  group unlock [-stored] <group> Unlock all vaults of a group with one password, or their stored passwords
+  group lock [-force] <group>    Lock all vaults of a group
+  jobs [-cancel ID]              List background jobs, or cancel one
+  audit [-vault V] [-op OP] [-outcome O] [-since T] [-until T] [-page N] [-limit N] [-csv]
+                                 Show audit log of vault operations, -csv exports matching events as CSV,
+                                 T is either a unix timestamp or RFC 3339 time
+  export [<file>]                Export vaults, groups and app settings as JSON, to STDOUT by default
+  import [-replace] [-dry-run] <file>
+                                 Import an exported file, "-" reads STDIN. Known vaults are updated and others added,
+                                 -replace removes vaults and groups missing from the file, -dry-run only shows changes
+  import -from sirikali|vaults [-dry-run] [<directory> ...]
+                                 Add vaults saved by SiriKali or GNOME Vaults, only those in <directory> if given,
+                                 -dry-run lists found vaults and whether they can be added
+  options [key=value ...]        Show app options, or set them
+  pathvar [<name> <path>]        List path variables, or set one, an empty <path> unsets it
+
+<vault> is either a vault ID, the vault directory path or its name, <group> is either a group ID or its name.
+
+Passwords are prompted for on a terminal. Otherwise they are read from STDIN, one per line:
+  create: the new password
+  unlock, group unlock: the password
+  passwd: the current password (or the master key with -masterkey), then the new password
+  upgrade-kdf, fsck: the password
+
+Exit codes:
+  0     success
+  1     unexpected failure
+  2     invalid arguments
+  3     no running Cloak app found
+  10+N  the app responded with API error code N, e.g. 20 for incorrect password
+`
+
+type command func(c *client, args []string) error
+
+var commands = map[string]command{
+	"list":        listCommand,
+	"add":         addCommand,
+	"create":      createCommand,
+	"unlock":      unlockCommand,
+	"lock":        lockCommand,
+	"info":        infoCommand,
+	"benchmark":   benchmarkCommand,
+	"upgrade-kdf": upgradeKDFCommand,
+	"reveal":      revealCommand,
+	"passwd":      passwdCommand,
+	"forget":      forgetCommand,
+	"label":       labelCommand,
+	"mountopts":   mountOptsCommand,
+	"log":         logCommand,
+	"relocate":    relocateCommand,
+	"fsck":        fsckCommand,
+	"tag":         tagCommand,
+	"groups":      groupsCommand,
+	"group":       groupCommand,
+	"jobs":        jobsCommand,
+	"audit":       auditCommand,
+	"export":      exportCommand,
+	"import":      importCommand,
+	"options":     optionsCommand,
+	"pathvar":     pathVarCommand,
+}
+
+// usageError indicates invalid command line arguments.
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "help" {
+		fmt.Fprint(os.Stderr, usage)
+		if len(args) == 0 {
+			return exitUsage
+		}
+		return exitOk
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "cloakctl: unknown command %q\n\n%s", args[0], usage)
+		return exitUsage
+	}
+
+	return exitCode(cmd(newClient(), args[1:]))
+}
+
+// exitCode reports given error and maps it to an exit code.
+func exitCode(err error) int {
+	if err == nil {
+		return exitOk
+	}
+	fmt.Fprintf(os.Stderr, "cloakctl: %v\n", err)
+
+	var apiErr *apiError
+	var connErr *connectionError
+	var usageErr *usageError
+	switch {
+	case errors.As(err, &apiErr):
+		return exitApiErrorBase + apiErr.Code
+	case errors.As(err, &connErr):
+		return exitNoInstance
+	case errors.As(err, &usageErr), errors.Is(err, flag.ErrHelp):
+		return exitUsage
+	default:
+		return exitFailure
+	}
+}
+
+// parseFlags parses flags of a subcommand, and checks the number of positional arguments.
+// A negative `nArgs` skips the check.
+func parseFlags(fs *flag.FlagSet, args []string, nArgs int, argsUsage string) error {
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		return &usageError{fmt.Sprintf("%s: %v", fs.Name(), err)}
+	}
+	if nArgs >= 0 && fs.NArg() != nArgs {
+		return &usageError{fmt.Sprintf("usage: cloakctl %s %s", fs.Name(), argsUsage)}
+	}
+	return nil
+}
+
+// resolveVault finds the ID of a vault, given either its ID, its directory path or its name as listed.
+// Names shared by several vaults are rejected, such vaults have to be referred to by ID or path.
+func resolveVault(c *client, ref string) (int64, error) {
+	if id, err := strconv.ParseInt(ref, 10, 64); err == nil {
+		return id, nil
+	}
+	path, err := filepath.Abs(ref)
+	if err != nil {
+		return 0, err
+	}
+	vaults, err := c.listVaults()
+	if err != nil {
+		return 0, err
+	}
+	var named []int64
+	for _, v := range vaults {
+		if v.Path == path || filepath.Dir(path) == v.Path && isConfFile(path) {
+			return v.ID, nil
+		}
+		if v.label() == ref {
+			named = append(named, v.ID)
+		}
+	}
+	switch len(named) {
+	case 0:
+		return 0, &usageError{fmt.Sprintf("no vault found at %s or named %s", path, ref)}
+	case 1:
+		return named[0], nil
+	default:
+		return 0, &usageError{fmt.Sprintf("%d vaults are named %s, refer to one by ID or path", len(named), ref)}
+	}
+}
+
+// resolveGroup finds the ID of a group, given either its ID or its name.
+func resolveGroup(c *client, ref string) (int64, error) {
+	if id, err := strconv.ParseInt(ref, 10, 64); err == nil {
+		return id, nil
+	}
+	groups, err := c.listGroups()
+	if err != nil {
+		return 0, err
+	}
+	for _, g := range groups {
+		if g.Name == ref {
+			return g.ID, nil
+		}
+	}
+	return 0, &usageError{fmt.Sprintf("no group named %s", ref)}
+}
+
+// resolveVaults finds IDs of given vaults, see `resolveVault`.
+func resolveVaults(c *client, refs []string) ([]int64, error) {
+	vaultIds := make([]int64, 0, len(refs))
+	for _, ref := range refs {
+		vaultId, err := resolveVault(c, ref)
+		if err != nil {
+			return nil, err
+		}
+		vaultIds = append(vaultIds, vaultId)
+	}
+	return vaultIds, nil
+}
+
+// confNames lists names of vault config files, in the order they are looked for in vault directories.
+var confNames = []string{"gocryptfs.conf", ".gocryptfs.reverse.conf", "cryfs.config", ".securefs.json"}
+
+// isConfFile tells whether given path looks like a vault config file, of any backend.
+func isConfFile(path string) bool {
+	name := filepath.Base(path)
+	for _, confName := range confNames {
+		if name == confName {
+			return true
+		}
+	}
+	return false
+}
+
+// stringList collects values of a flag given multiple times.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// passwordReader reads passwords from terminal or STDIN, which might be redirected from a file.
+type passwordReader struct {
+	file  *os.File // STDIN if nil
+	stdin *bufio.Reader
+}
+
+// read reads a single password.
+// When `confirm` is true and we're on a terminal, the password is asked twice.
+func (r *passwordReader) read(prompt string, confirm bool) (string, error) {
+	if r.file == nil {
+		r.file = os.Stdin
+	}
+	fd := int(r.file.Fd())
+	if !term.IsTerminal(fd) {
+		if r.stdin == nil {
+			r.stdin = bufio.NewReader(r.file)
+		}
+		line, err := r.stdin.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return "", fmt.Errorf("failed to read %s from STDIN: %w", strings.ToLower(prompt), err)
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	fmt.Fprintf(os.Stderr, "%s: ", prompt)
+	pw, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	if confirm {
+		fmt.Fprintf(os.Stderr, "Repeat %s: ", strings.ToLower(prompt))
+		repeated, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		if string(repeated) != string(pw) {
+			return "", &usageError{"passwords do not match"}
+		}
+	}
+	return string(pw), nil
+}
+
+func listCommand(c *client, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	asJson := fs.Bool("json", false, "print vaults as JSON")
+	if err := parseFlags(fs, args, 0, "[-json]"); err != nil {
+		return err
+	}
+
+	vaults, err := c.listVaults()
+	if err != nil {
+		return err
+	}
+	if *asJson {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if vaults == nil {
+			vaults = []vault{}
+		}
+		return encoder.Encode(vaults)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTATE\tNAME\tBACKEND\tPATH\tMOUNTPOINT")
+	for _, v := range vaults {
+		state := v.State
+		if v.Missing {
+			state += " (missing)"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", v.ID, state, v.label(), v.Backend, v.Path, v.MountPoint)
+	}
+	return w.Flush()
+}
+
+func addCommand(c *client, args []string) error {
+	fs := flag.NewFlagSet("add", flag.ContinueOnError)
+	if err := parseFlags(fs, args, 1, "<path>"); err != nil {
+		return err
+	}
+
+	path, err := filepath.Abs(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	// The API expects path to the vault config, e.g. `gocryptfs.conf`, or `.gocryptfs.reverse.conf` for reverse vaults
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		dir := path
+		path = filepath.Join(dir, confNames[0])
+		for _, confName := range confNames {
+			if _, err := os.Stat(filepath.Join(dir, confName)); err == nil {
+				path = filepath.Join(dir, confName)
+				break
+			}
+		}
+	}
+	resp, err := c.call(http.MethodPost, "vaults", map[string]string{"op": "add", "path": path})
+	if err != nil {
+		return err
+	}
+	return printVaultId(resp)
+}
+
+func createCommand(c *client, args []string) error {
+	fs := flag.NewFlagSet("create", flag.ContinueOnError)
+	var exclude, excludeWildcard stringList
+	fs.Var(&exclude, "exclude", "exclude a path from the encrypted view of a reverse vault, can be repeated")
+	fs.Var(&excludeWildcard, "exclude-wildcard", "exclude a pattern from the encrypted view of a reverse vault, can be repeated")
+	backend := fs.String("backend", "gocryptfs", "tool encrypting the vault: gocryptfs, cryfs or securefs")
+	options := map[string]interface{}{
+		"reverse":            fs.Bool("reverse", false, "create a reverse vault"),
+		"plaintextnames":     fs.Bool("plaintextnames", false, "do not encrypt file names"),
+		"aessiv":             fs.Bool("aessiv", false, "use AES-SIV encryption"),
+		"xchacha":            fs.Bool("xchacha", false, "use XChaCha20-Poly1305 encryption"),
+		"deterministicnames": fs.Bool("deterministic-names", false, "disable per-directory IVs for file names"),
+		"longnamemax":        fs.Int("longnamemax", 0, "hash encrypted names longer than this"),
+		"scryptn":            fs.Int("scryptn", 0, "scrypt cost parameter as logarithm"),
+	}
+	if err := parseFlags(fs, args, -1, ""); err != nil {
+		return err
+	}
+	reverse := *options["reverse"].(*bool)
+	if reverse && fs.NArg() != 1 {
+		return &usageError{"usage: cloakctl create -reverse [flags] <directory>"}
+	}
+	if !reverse && fs.NArg() != 2 {
+		return &usageError{"usage: cloakctl create [flags] <directory> <name>"}
+	}
+
+	dir, err := filepath.Abs(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if err := c.connect(); err != nil {
+		return err
+	}
+	password, err := (&passwordReader{}).read("Password", true)
+	if err != nil {
+		return err
+	}
+	resp, err := c.call(http.MethodPost, "vaults", map[string]interface{}{
+		"op":       "create",
+		"path":     dir,
+		"name":     fs.Arg(1),
+		"password": password,
+		"backend":  *backend,
+		"options":  options,
+
+		"exclude":         exclude,
+		"excludewildcard": excludeWildcard,
+	})
+	if err != nil {
+		return err
+	}
+	return printVaultId(resp)
+}
+
+// printVaultId prints ID of the vault returned by the API.
+func printVaultId(resp *response) error {
+	var v vault
+	if err := json.Unmarshal(resp.Item, &v); err != nil {
+		return err
+	}
+	fmt.Println(v.ID)
+	return nil
+}
+
+func unlockCommand(c *client, args []string) error {
+	fs := flag.NewFlagSet("unlock", flag.ContinueOnError)
+	stored := fs.Bool("stored", false, "use the password stored in system keyring")
+	if err := parseFlags(fs, args, 1, "[-stored] <vault>"); err != nil {
+		return err
+	}
+
+	vaultId, err := resolveVault(c, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if err := c.connect(); err != nil {
+		return err
+	}
+	// An empty password tells the app to use the stored one
+	var password string
+	if !*stored {
+		if password, err = (&passwordReader{}).read("Password", false); err != nil {
+			return err
+		}
+	}
+	resp, err := c.call(http.MethodPost, fmt.Sprintf("vault/%d", vaultId), map[string]string{
+		"op":       "unlock",
+		"password": password,
+	})
+	// Output of gocryptfs tells more about unknown errors
+	if err != nil && resp != nil {
+		for _, line := range resp.Log {
+			fmt.Fprintln(os.Stderr, line)
+		}
+	}
+	return err
+}
+
+func lockCommand(c *client, args []string) error {
+	fs := flag.NewFlagSet("lock", flag.ContinueOnError)
+	force := fs.Bool("force", false, "unmount the vault even if files are still open")
+	if err := parseFlags(fs, args, 1, "[-force] <vault>"); err != nil {
+		return err
+	}
+
+	vaultId, err := resolveVault(c, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	_, err = c.call(http.MethodPost, fmt.Sprintf("vault/%d", vaultId), map[string]interface{}{
+		"op":    "lock",
+		"force": *force,
+	})
+	return err
+}
+
+func infoCommand(c *client, args []string) error {
+	fs := flag.NewFlagSet("info", flag.ContinueOnError)
+	if err := parseFlags(fs, args, 1, "<vault>"); err != nil {
+		return err
+	}
+
+	vaultId, err := resolveVault(c, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	resp, err := c.call(http.MethodGet, fmt.Sprintf("vault/%d/info", vaultId), nil)
+	if err != nil {
+		return err
+	}
+	var info confInfo
+	if err := json.Unmarshal(resp.Item, &info); err != nil {
+		return err
+	}
+	fmt.Printf("creator=%s\n", info.Creator)
+	fmt.Printf("version=%d\n", info.Version)
+	fmt.Printf("featureflags=%s\n", strings.Join(info.FeatureFlags, ","))
+	fmt.Printf("scrypt=%s\n", info.scrypt())
+	if info.LongNameMax > 0 {
+		fmt.Printf("longnamemax=%d\n", info.LongNameMax)
+	}
+	return nil
+}
+
+// confInfo is vault metadata from its gocryptfs config.
+type confInfo struct {
+	Creator      string   `json:"creator"`
+	Version      int      `json:"version"`
+	FeatureFlags []string `json:"featureflags"`
+	ScryptN      int      `json:"scryptn"`
+	ScryptLogN   int      `json:"scryptlogn"`
+	ScryptR      int      `json:"scryptr"`
+	ScryptP      int      `json:"scryptp"`
+	LongNameMax  int      `json:"longnamemax"`
+}
+
+func (i confInfo) scrypt() string {
+	return fmt.Sprintf("N:%d (2^%d) R:%d P:%d", i.ScryptN, i.ScryptLogN, i.ScryptR, i.ScryptP)
+}
+
+// kdfBenchmark is the scrypt cost suggested by the app for given target unlock time.
+type kdfBenchmark struct {
+	Target    int   `json:"target"`
+	ScryptN   int   `json:"scryptn"`
+	Estimated int   `json:"estimated"`
+	Memory    int64 `json:"memory"`
+}
+
+func (c *client) benchmarkKDF(target int) (kdfBenchmark, error) {
+	var result kdfBenchmark
+	resp, err := c.call(http.MethodGet, fmt.Sprintf("kdf/benchmark?target=%d", target), nil)
+	if err != nil {
+		return result, err
+	}
+	err = json.Unmarshal(resp.Item, &result)
+	return result, err
+}
+
+func benchmarkCommand(c *client, args []string) error {
+	fs := flag.NewFlagSet("benchmark", flag.ContinueOnError)
+	target := fs.Int("target", 1000, "target unlock time in milliseconds")
+	if err := parseFlags(fs, args, 0, "[-target MS]"); err != nil {
+		return err
+	}
+
+	result, err := c.benchmarkKDF(*target)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("scryptn=%d\n", result.ScryptN)
+	fmt.Printf("estimated=%dms\n", result.Estimated)
+	fmt.Printf("memory=%dMiB\n", result.Memory>>20)
+	return nil
+}
+
+func upgradeKDFCommand(c *client, args []string) error {
+	fs := flag.NewFlagSet("upgrade-kdf", flag.ContinueOnError)
+	scryptN := fs.Int("scryptn", 0, "scrypt cost parameter as logarithm")
+	if err := parseFlags(fs, args, 1, "[-scryptn N] <vault>"); err != nil {
+		return err
+	}
+
+	vaultId, err := resolveVault(c, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *scryptN == 0 {
+		result, err := c.benchmarkKDF(1000)
+		if err != nil {
+			return err
+		}
+		*scryptN = result.ScryptN
+	}
+	if err := c.connect(); err != nil {
+		return err
+	}
+	password, err := (&passwordReader{}).read("Password", false)
+	if err != nil {
+		return err
+	}
+	resp, err := c.call(http.MethodPost, fmt.Sprintf("vault/%d/kdf", vaultId), map[string]interface{}{
+		"password": password,
+		"scryptn":  *scryptN,
+	})
+	if err != nil {
+		return err
+	}
+	var result struct {
+		Before confInfo `json:"before"`
+		After  confInfo `json:"after"`
+	}
+	if err := json.Unmarshal(resp.Item, &result); err != nil {
+		return err
+	}
+	fmt.Printf("before=%s\n", result.Before.scrypt())
+	fmt.Printf("after=%s\n", result.After.scrypt())
+	return nil
+}
+
+func revealCommand(c *client, args []string) error {
+	fs := flag.NewFlagSet("reveal", flag.ContinueOnError)
+	revealVault := fs.Bool("vault", false, "reveal the vault directory instead of its mountpoint")
+	if err := parseFlags(fs, args, 1, "[-vault] <vault>"); err != nil {
+		return err
+	}
+
+	vaultId, err := resolveVault(c, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	op := "reveal_mountpoint"
+	if *revealVault {
+		op = "reveal_vault"
+	}
+	_, err = c.call(http.MethodPost, fmt.Sprintf("vault/%d", vaultId), map[string]string{"op": op})
+	return err
+}
+
+func passwdCommand(c *client, args []string) error {
+	fs := flag.NewFlagSet("passwd", flag.ContinueOnError)
+	useMasterkey := fs.Bool("masterkey", false, "reset password using the master key")
+	if err := parseFlags(fs, args, 1, "[-masterkey] <vault>"); err != nil {
+		return err
+	}
+
+	vaultId, err := resolveVault(c, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if err := c.connect(); err != nil {
+		return err
+	}
+	reader := &passwordReader{}
+	form := make(map[string]string)
+	if *useMasterkey {
+		if form["masterkey"], err = reader.read("Master key", false); err != nil {
+			return err
+		}
+	} else {
+		if form["password"], err = reader.read("Current password", false); err != nil {
+			return err
+		}
+	}
+	if form["newpassword"], err = reader.read("New password", true); err != nil {
+		return err
+	}
+	_, err = c.call(http.MethodPost, fmt.Sprintf("vault/%d/password", vaultId), form)
+	return err
+}
+
+func forgetCommand(c *client, args []string) error {
+	fs := flag.NewFlagSet("forget", flag.ContinueOnError)
+	if err := parseFlags(fs, args, 1, "<vault>"); err != nil {
+		return err
+	}
+
+	vaultId, err := resolveVault(c, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	_, err = c.call(http.MethodDelete, fmt.Sprintf("vault/%d/password", vaultId), nil)
+	return err
+}
+
+func labelCommand(c *client, args []string) error {
+	fs := flag.NewFlagSet("label", flag.ContinueOnError)
+	fs.String("name", "", "display name")
+	fs.String("notes", "", "free-form notes")
+	fs.String("icon", "", "colour or icon key")
+	if err := parseFlags(fs, args, 1, "[-name NAME] [-notes TEXT] [-icon KEY] <vault>"); err != nil {
+		return err
+	}
+
+	// Only flags given are changed
+	changes := make(map[string]interface{})
+	fs.Visit(func(f *flag.Flag) {
+		changes[f.Name] = f.Value.String()
+	})
+	if len(changes) == 0 {
+		return &usageError{"usage: cloakctl label [-name NAME] [-notes TEXT] [-icon KEY] <vault>"}
+	}
+
+	vaultId, err := resolveVault(c, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	return c.updateVaultOptions(vaultId, changes)
+}
+
+func mountOptsCommand(c *client, args []string) error {
+	// Mount options look like flags, so they are not parsed as such
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		return &usageError{"usage: cloakctl mountopts <vault> [<option> ...]"}
+	}
+
+	vaultId, err := resolveVault(c, args[0])
+	if err != nil {
+		return err
+	}
+	return c.updateVaultOptions(vaultId, map[string]interface{}{
+		"mountoptions": append([]string{}, args[1:]...),
+	})
+}
+
+func logCommand(c *client, args []string) error {
+	fs := flag.NewFlagSet("log", flag.ContinueOnError)
+	debug := fs.String("debug", "", "turn debug mode on or off")
+	if err := parseFlags(fs, args, 1, "[-debug on|off] <vault>"); err != nil {
+		return err
+	}
+	if *debug != "" && *debug != "on" && *debug != "off" {
+		return &usageError{"usage: cloakctl log [-debug on|off] <vault>"}
+	}
+
+	vaultId, err := resolveVault(c, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if *debug != "" {
+		return c.updateVaultOptions(vaultId, map[string]interface{}{"debugmount": *debug == "on"})
+	}
+
+	resp, err := c.call(http.MethodGet, fmt.Sprintf("vault/%d/log", vaultId), nil)
+	if err != nil {
+		return err
+	}
+	var mountLog struct {
+		Lines    []string `json:"lines"`
+		DebugLog string   `json:"debuglog"`
+	}
+	if err := json.Unmarshal(resp.Item, &mountLog); err != nil {
+		return err
+	}
+	for _, line := range mountLog.Lines {
+		fmt.Println(line)
+	}
+	if mountLog.DebugLog != "" {
+		fmt.Fprintf(os.Stderr, "Debug log: %s\n", mountLog.DebugLog)
+	}
+	return nil
+}
+
+func relocateCommand(c *client, args []string) error {
+	fs := flag.NewFlagSet("relocate", flag.ContinueOnError)
+	depth := fs.Int("depth", 0, "directory levels to search")
+	if err := parseFlags(fs, args, -1, "[-depth N] <vault> <directory> [<directory> ...]"); err != nil {
+		return err
+	}
+	if fs.NArg() < 2 {
+		return &usageError{"usage: cloakctl relocate [-depth N] <vault> <directory> [<directory> ...]"}
+	}
+
+	vaultId, err := resolveVault(c, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	roots := make([]string, 0, fs.NArg()-1)
+	for _, root := range fs.Args()[1:] {
+		root, err := filepath.Abs(root)
+		if err != nil {
+			return err
+		}
+		roots = append(roots, root)
+	}
+	resp, err := c.call(http.MethodPost, fmt.Sprintf("vault/%d/relocate", vaultId), map[string]interface{}{
+		"roots": roots,
+		"depth": *depth,
+	})
+	if err != nil {
+		// Several candidates found, show them
+		if resp != nil {
+			var paths []string
+			if json.Unmarshal(resp.Items, &paths) == nil {
+				for _, path := range paths {
+					fmt.Fprintln(os.Stderr, path)
+				}
+			}
+		}
+		return err
+	}
+	var relocated vault
+	if err := json.Unmarshal(resp.Item, &relocated); err != nil {
+		return err
+	}
+	fmt.Println(relocated.Path)
+	return nil
+}
+
+// fsckStatus is the state of a vault check.
+type fsckStatus struct {
+	State    string   `json:"state"`
+	Corrupt  []string `json:"corrupt"`
+	Message  string   `json:"message"`
+	Progress int      `json:"progress"`
+}
+
+func fsckCommand(c *client, args []string) error {
+	fs := flag.NewFlagSet("fsck", flag.ContinueOnError)
+	stored := fs.Bool("stored", false, "use the password stored in system keyring")
+	cancel := fs.Bool("cancel", false, "cancel the running check")
+	if err := parseFlags(fs, args, 1, "[-stored | -cancel] <vault>"); err != nil {
+		return err
+	}
+
+	vaultId, err := resolveVault(c, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	api := fmt.Sprintf("vault/%d/fsck", vaultId)
+	if *cancel {
+		_, err = c.call(http.MethodDelete, api, nil)
+		return err
+	}
+	if err := c.connect(); err != nil {
+		return err
+	}
+	var password string
+	if !*stored {
+		if password, err = (&passwordReader{}).read("Password", false); err != nil {
+			return err
+		}
+	}
+	if _, err = c.call(http.MethodPost, api, map[string]string{"password": password}); err != nil {
+		return err
+	}
+
+	// Follow the check until it finishes
+	var status fsckStatus
+	for status.State == "" || status.State == "running" {
+		time.Sleep(time.Second)
+		resp, err := c.call(http.MethodGet, api, nil)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(resp.Item, &status); err != nil {
+			return err
+		}
+		if status.State == "running" && status.Progress >= 0 {
+			fmt.Fprintf(os.Stderr, "\rChecking... %d%%", status.Progress)
+		}
+	}
+	fmt.Fprintln(os.Stderr)
+	for _, path := range status.Corrupt {
+		fmt.Println(path)
+	}
+	if status.State != "succeeded" {
+		return fmt.Errorf("check %s: %s", status.State, status.Message)
+	}
+	fmt.Fprintln(os.Stderr, status.Message)
+	return nil
+}
+
+func tagCommand(c *client, args []string) error {
+	fs := flag.NewFlagSet("tag", flag.ContinueOnError)
+	if err := parseFlags(fs, args, -1, "<vault> [<tag> ...]"); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 {
+		return &usageError{"usage: cloakctl tag <vault> [<tag> ...]"}
+	}
+
+	vaultId, err := resolveVault(c, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	_, err = c.call(http.MethodPost, fmt.Sprintf("vault/%d/tags", vaultId), map[string][]string{
+		"tags": append([]string{}, fs.Args()[1:]...),
+	})
+	return err
+}
+
+func groupsCommand(c *client, args []string) error {
+	fs := flag.NewFlagSet("groups", flag.ContinueOnError)
+	if err := parseFlags(fs, args, 0, ""); err != nil {
+		return err
+	}
+
+	groups, err := c.listGroups()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tVAULTS")
+	for _, g := range groups {
+		vaultIds := make([]string, len(g.Vaults))
+		for i, vaultId := range g.Vaults {
+			vaultIds[i] = strconv.FormatInt(vaultId, 10)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", g.ID, g.Name, strings.Join(vaultIds, ","))
+	}
+	return w.Flush()
+}
+
+const groupUsage = "usage: cloakctl group create|set|rename|delete|unlock|lock ..."
+
+func groupCommand(c *client, args []string) error {
+	if len(args) == 0 {
+		return &usageError{groupUsage}
+	}
+	fs := flag.NewFlagSet("group "+args[0], flag.ContinueOnError)
+	switch args[0] {
+	case "create":
+		if err := parseFlags(fs, args[1:], -1, "<name> [<vault> ...]"); err != nil {
+			return err
+		}
+		if fs.NArg() < 1 {
+			return &usageError{"usage: cloakctl group create <name> [<vault> ...]"}
+		}
+		vaultIds, err := resolveVaults(c, fs.Args()[1:])
+		if err != nil {
+			return err
+		}
+		resp, err := c.call(http.MethodPost, "groups", map[string]interface{}{
+			"name":   fs.Arg(0),
+			"vaults": vaultIds,
+		})
+		if err != nil {
+			return err
+		}
+		var g group
+		if err := json.Unmarshal(resp.Item, &g); err != nil {
+			return err
+		}
+		fmt.Println(g.ID)
+		return nil
+	case "set", "rename":
+		nArgs, argsUsage := -1, "<group> [<vault> ...]"
+		if args[0] == "rename" {
+			nArgs, argsUsage = 2, "<group> <name>"
+		}
+		if err := parseFlags(fs, args[1:], nArgs, argsUsage); err != nil {
+			return err
+		}
+		if fs.NArg() < 1 {
+			return &usageError{fmt.Sprintf("usage: cloakctl %s %s", fs.Name(), argsUsage)}
+		}
+		groupId, err := resolveGroup(c, fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		form := map[string]interface{}{"op": "update"}
+		if args[0] == "rename" {
+			form["name"] = fs.Arg(1)
+		} else if form["vaults"], err = resolveVaults(c, fs.Args()[1:]); err != nil {
+			return err
+		}
+		_, err = c.call(http.MethodPost, fmt.Sprintf("group/%d", groupId), form)
+		return err
+	case "delete":
+		if err := parseFlags(fs, args[1:], 1, "<group>"); err != nil {
+			return err
+		}
+		groupId, err := resolveGroup(c, fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		_, err = c.call(http.MethodDelete, fmt.Sprintf("group/%d", groupId), nil)
+		return err
+	case "unlock", "lock":
+		stored := fs.Bool("stored", false, "use passwords stored in system keyring")
+		force := fs.Bool("force", false, "unmount vaults even if files are still open")
+		argsUsage := "[-stored] <group>"
+		if args[0] == "lock" {
+			argsUsage = "[-force] <group>"
+		}
+		if err := parseFlags(fs, args[1:], 1, argsUsage); err != nil {
+			return err
+		}
+		groupId, err := resolveGroup(c, fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		form := map[string]interface{}{"op": args[0], "force": *force}
+		if args[0] == "unlock" && !*stored {
+			if err := c.connect(); err != nil {
+				return err
+			}
+			// An empty password tells the app to use stored ones
+			if form["password"], err = (&passwordReader{}).read("Password", false); err != nil {
+				return err
+			}
+		}
+		resp, err := c.call(http.MethodPost, fmt.Sprintf("group/%d", groupId), form)
+		if err != nil {
+			return err
+		}
+		return printGroupResults(resp)
+	default:
+		return &usageError{groupUsage}
+	}
+}
+
+// printGroupResults prints per-vault results of unlocking or locking a group,
+// the first failure is returned so that the exit code tells it.
+func printGroupResults(resp *response) error {
+	var results []struct {
+		VaultID int64  `json:"vault"`
+		State   string `json:"state"`
+		Error   *struct {
+			Code    int    `json:"code"`
+			Message string `json:"msg"`
+		} `json:"error"`
+	}
+	if len(resp.Items) > 0 {
+		if err := json.Unmarshal(resp.Items, &results); err != nil {
+			return err
+		}
+	}
+	var failure error
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VAULT\tSTATE\tERROR")
+	for _, result := range results {
+		msg := ""
+		if result.Error != nil {
+			msg = result.Error.Message
+			if failure == nil {
+				failure = &apiError{Code: result.Error.Code, Message: fmt.Sprintf("vault %d: %s", result.VaultID, msg)}
+			}
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", result.VaultID, result.State, msg)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return failure
+}
+
+func jobsCommand(c *client, args []string) error {
+	fs := flag.NewFlagSet("jobs", flag.ContinueOnError)
+	cancel := fs.Int64("cancel", 0, "cancel the job of given ID")
+	if err := parseFlags(fs, args, 0, "[-cancel ID]"); err != nil {
+		return err
+	}
+
+	if *cancel > 0 {
+		_, err := c.call(http.MethodDelete, fmt.Sprintf("jobs/%d", *cancel), nil)
+		return err
+	}
+	resp, err := c.call(http.MethodGet, "jobs", nil)
+	if err != nil {
+		return err
+	}
+	var jobs []struct {
+		ID       int64  `json:"id"`
+		Kind     string `json:"kind"`
+		VaultID  int64  `json:"vault"`
+		State    string `json:"state"`
+		Progress int    `json:"progress"`
+		Error    *struct {
+			Message string `json:"msg"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(resp.Items, &jobs); err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tKIND\tVAULT\tSTATE\tPROGRESS\tERROR")
+	for _, job := range jobs {
+		vault, progress, msg := "-", "-", ""
+		if job.VaultID > 0 {
+			vault = strconv.FormatInt(job.VaultID, 10)
+		}
+		if job.Progress >= 0 {
+			progress = fmt.Sprintf("%d%%", job.Progress)
+		}
+		if job.Error != nil {
+			msg = job.Error.Message
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", job.ID, job.Kind, vault, job.State, progress, msg)
+	}
+	return w.Flush()
+}
+
+func auditCommand(c *client, args []string) error {
+	fs := flag.NewFlagSet("audit", flag.ContinueOnError)
+	vaultRef := fs.String("vault", "", "only show events of given vault")
+	op := fs.String("op", "", "only show events of given operation, e.g. unlock")
+	outcome := fs.String("outcome", "", "only show events of given outcome: succeeded, failed or cancelled")
+	since := fs.String("since", "", "only show events since given time")
+	until := fs.String("until", "", "only show events before given time")
+	page := fs.Int("page", 0, "page to show")
+	limit := fs.Int("limit", 0, "events per page")
+	asCsv := fs.Bool("csv", false, "export events as CSV")
+	if err := parseFlags(fs, args, 0, "[-vault V] [-op OP] [-outcome O] [-since T] [-until T] [-page N] [-limit N] [-csv]"); err != nil {
+		return err
+	}
+
+	query := url.Values{}
+	if *vaultRef != "" {
+		vaultId, err := resolveVault(c, *vaultRef)
+		if err != nil {
+			return err
+		}
+		query.Set("vault", strconv.FormatInt(vaultId, 10))
+	}
+	for key, value := range map[string]string{"op": *op, "outcome": *outcome, "since": *since, "until": *until} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	if *page > 0 {
+		query.Set("page", strconv.Itoa(*page))
+	}
+	if *limit > 0 {
+		query.Set("limit", strconv.Itoa(*limit))
+	}
+	if *asCsv {
+		query.Set("format", "csv")
+		return c.download("audit?"+query.Encode(), os.Stdout)
+	}
+
+	resp, err := c.call(http.MethodGet, "audit?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	var result struct {
+		Events []struct {
+			ID        int64  `json:"id"`
+			VaultID   int64  `json:"vault"`
+			Operation string `json:"operation"`
+			Outcome   string `json:"outcome"`
+			Code      int    `json:"code"`
+			RC        int    `json:"rc"`
+			Timestamp int64  `json:"timestamp"`
+		} `json:"events"`
+		Total int `json:"total"`
+		Page  int `json:"page"`
+		Limit int `json:"limit"`
+	}
+	if err := json.Unmarshal(resp.Item, &result); err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTIME\tVAULT\tOPERATION\tOUTCOME\tCODE\tRC")
+	for _, event := range result.Events {
+		fmt.Fprintf(w, "%d\t%s\t%d\t%s\t%s\t%d\t%d\n",
+			event.ID, time.Unix(event.Timestamp, 0).Format(time.RFC3339), event.VaultID,
+			event.Operation, event.Outcome, event.Code, event.RC)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if shown := (result.Page-1)*result.Limit + len(result.Events); shown < result.Total {
+		fmt.Fprintf(os.Stderr, "%d of %d events shown, see the next page with -page %d\n", shown, result.Total, result.Page+1)
+	}
+	return nil
+}
+
+func exportCommand(c *client, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	if err := parseFlags(fs, args, -1, "[<file>]"); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return &usageError{"usage: cloakctl export [<file>]"}
+	}
+	if fs.NArg() == 0 {
+		return c.download("export", os.Stdout)
+	}
+
+	// The exported file tells where vaults are, so keep it private
+	f, err := os.OpenFile(fs.Arg(0), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if err := c.download("export", f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func importCommand(c *client, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	replace := fs.Bool("replace", false, "remove vaults and groups missing from the file")
+	dryRun := fs.Bool("dry-run", false, "only show what would change")
+	from := fs.String("from", "", "add vaults saved by another front-end: sirikali or vaults")
+	if err := parseFlags(fs, args, -1, "[-replace] [-dry-run] <file>"); err != nil {
+		return err
+	}
+	if *from != "" {
+		return importForeign(c, *from, fs.Args(), *dryRun)
+	}
+	if fs.NArg() != 1 {
+		return &usageError{"usage: cloakctl import [-replace] [-dry-run] <file>"}
+	}
+
+	var (
+		data []byte
+		err  error
+	)
+	if fs.Arg(0) == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(fs.Arg(0))
+	}
+	if err != nil {
+		return err
+	}
+	mode := "merge"
+	if *replace {
+		mode = "replace"
+	}
+	resp, err := c.call(http.MethodPost, "import", map[string]interface{}{
+		"mode":     mode,
+		"dryrun":   *dryRun,
+		"document": json.RawMessage(data),
+	})
+	if err != nil {
+		return err
+	}
+
+	var summary struct {
+		Added    []string          `json:"added"`
+		Updated  []string          `json:"updated"`
+		Removed  []string          `json:"removed"`
+		Groups   []string          `json:"groups"`
+		Settings map[string]string `json:"settings"`
+		Ignored  []string          `json:"ignored"`
+	}
+	if err := json.Unmarshal(resp.Item, &summary); err != nil {
+		return err
+	}
+	for _, path := range summary.Added {
+		fmt.Printf("add\t%s\n", path)
+	}
+	for _, path := range summary.Updated {
+		fmt.Printf("update\t%s\n", path)
+	}
+	for _, path := range summary.Removed {
+		fmt.Printf("remove\t%s\n", path)
+	}
+	for _, name := range summary.Groups {
+		fmt.Printf("group\t%s\n", name)
+	}
+	keys := make([]string, 0, len(summary.Settings))
+	for key := range summary.Settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Printf("set\t%s=%s\n", key, summary.Settings[key])
+	}
+	for _, key := range summary.Ignored {
+		fmt.Fprintf(os.Stderr, "Setting ignored: %s\n", key)
+	}
+	if *dryRun {
+		fmt.Fprintln(os.Stderr, "Dry run, nothing was changed")
+	}
+	return nil
+}
+
+// importForeign adds vaults saved by another front-end, or lists them for dry runs.
+func importForeign(c *client, source string, dirs []string, dryRun bool) error {
+	cipherDirs := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		dir, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		cipherDirs = append(cipherDirs, dir)
+	}
+	resp, err := c.call(http.MethodPost, "import/"+source, map[string]interface{}{
+		"cipherdirs": cipherDirs,
+		"dryrun":     dryRun,
+	})
+	if err != nil {
+		return err
+	}
+
+	var vaults []struct {
+		CipherDir  string `json:"cipherdir"`
+		MountPoint string `json:"mountpoint"`
+		State      string `json:"state"`
+		Reason     string `json:"reason"`
+		VaultID    int64  `json:"vault"`
+	}
+	if err := json.Unmarshal(resp.Items, &vaults); err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "STATE\tID\tPATH\tMOUNTPOINT\tREASON")
+	for _, v := range vaults {
+		id := ""
+		if v.VaultID != 0 {
+			id = strconv.FormatInt(v.VaultID, 10)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", v.State, id, v.CipherDir, v.MountPoint, v.Reason)
+	}
+	return w.Flush()
+}
+
+func optionsCommand(c *client, args []string) error {
+	// Set options
+	if len(args) > 0 {
+		form := make(map[string]interface{})
+		for _, arg := range args {
+			kv := strings.SplitN(arg, "=", 2)
+			if len(kv) != 2 || kv[0] == "" {
+				return &usageError{"usage: cloakctl options [key=value ...]"}
+			}
+			form[kv[0]] = optionValue(kv[1])
+		}
+		_, err := c.call(http.MethodPost, "options", form)
+		return err
+	}
+
+	// Show options
+	resp, err := c.call(http.MethodGet, "options", nil)
+	if err != nil {
+		return err
+	}
+	var item struct {
+		Version map[string]string      `json:"version"`
+		Options map[string]interface{} `json:"options"`
+	}
+	if err := json.Unmarshal(resp.Item, &item); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(item.Options))
+	for k := range item.Options {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Printf("%s=%v\n", k, item.Options[k])
+	}
+	fmt.Printf("version=%s\n", item.Version["version"])
+	return nil
+}
+
+func pathVarCommand(c *client, args []string) error {
+	fs := flag.NewFlagSet("pathvar", flag.ContinueOnError)
+	if err := parseFlags(fs, args, -1, "[<name> <path>]"); err != nil {
+		return err
+	}
+
+	switch fs.NArg() {
+	case 0:
+		resp, err := c.call(http.MethodGet, "options", nil)
+		if err != nil {
+			return err
+		}
+		var item struct {
+			Options struct {
+				PathVars map[string]string `json:"pathvars"`
+			} `json:"options"`
+		}
+		if err := json.Unmarshal(resp.Item, &item); err != nil {
+			return err
+		}
+		names := make([]string, 0, len(item.Options.PathVars))
+		for name := range item.Options.PathVars {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("%s=%s\n", name, item.Options.PathVars[name])
+		}
+		return nil
+	case 2:
+		path := fs.Arg(1)
+		if path != "" {
+			var err error
+			if path, err = filepath.Abs(path); err != nil {
+				return err
+			}
+		}
+		_, err := c.call(http.MethodPost, "options", map[string]interface{}{
+			"pathvars": map[string]string{fs.Arg(0): path},
+		})
+		return err
+	default:
+		return &usageError{"usage: cloakctl pathvar [<name> <path>]"}
+	}
+}
+
+// optionValue converts an option value given on the command line to a JSON boolean or number if it looks like one.
+func optionValue(v string) interface{} {
+	if v == "true" || v == "false" {
+		return v == "true"
+	}
+	if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return n
+	}
+	return v
+}
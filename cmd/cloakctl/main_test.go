@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/adrg/xdg"
+	"github.com/stretchr/testify/suite"
+)
+
+type cloakctlTestSuite struct {
+	suite.Suite
+	vaults   []vault
+	code     int // API error code responded
+	requests int
+	client   *client
+}
+
+func (s *cloakctlTestSuite) SetupTest() {
+	s.vaults = []vault{
+		{ID: 1, Path: "/srv/vaults/work"},
+		{ID: 2, Path: "/srv/vaults/photos", Name: "Holidays"},
+		{ID: 3, Path: "/srv/backup/photos"},
+		{ID: 4, Path: "/srv/vaults/tax", Name: "Tax 2025"},
+	}
+	s.code, s.requests = 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests++
+		s.Equal("/api/vaults", r.URL.Path)
+		s.Equal("Bearer secret", r.Header.Get("Authorization"))
+		items, _ := json.Marshal(s.vaults)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(response{Code: s.code, Msg: "Oops", Items: items})
+	}))
+	s.T().Cleanup(server.Close)
+	s.client = &client{baseUrl: server.URL + "/api", token: "secret", http: server.Client()}
+}
+
+func (s *cloakctlTestSuite) Test_01_ExitCode() {
+	s.Equal(exitOk, exitCode(nil))
+	s.Equal(exitApiErrorBase+20, exitCode(&apiError{Code: 20, Message: "Incorrect password"}))
+	s.Equal(exitApiErrorBase+7, exitCode(fmt.Errorf("unlocking: %w", &apiError{Code: 7})))
+	s.Equal(exitNoInstance, exitCode(&connectionError{errors.New("connection refused")}))
+	s.Equal(exitUsage, exitCode(&usageError{"usage: cloakctl lock <vault>"}))
+	s.Equal(exitUsage, exitCode(flag.ErrHelp))
+	s.Equal(exitFailure, exitCode(errors.New("boom")))
+
+	// Flags and positional arguments are checked before anything is sent
+	s.Equal(exitUsage, run([]string{"frobnicate"}))
+	s.Equal(exitUsage, run(nil))
+	fs := flag.NewFlagSet("lock", flag.ContinueOnError)
+	fs.Bool("force", false, "")
+	s.Equal(exitUsage, exitCode(parseFlags(fs, []string{"-nope"}, 1, "<vault>")))
+	s.Equal(exitUsage, exitCode(parseFlags(fs, []string{"-force"}, 1, "<vault>")))
+	s.Equal(exitOk, exitCode(parseFlags(fs, []string{"-force", "1"}, 1, "<vault>")))
+}
+
+func (s *cloakctlTestSuite) Test_02_ResolveVault() {
+	// IDs are taken as is
+	id, err := resolveVault(s.client, "42")
+	s.Require().NoError(err)
+	s.EqualValues(42, id)
+	s.Zero(s.requests)
+
+	for ref, expected := range map[string]int64{
+		"/srv/vaults/work":                1,
+		"/srv/vaults/work/":               1,
+		"/srv/vaults/work/gocryptfs.conf": 1,
+		"/srv/vaults/tax/../work":         1,
+		"work":                            1, // directory name of a vault without display name
+		"Holidays":                        2,
+		"/srv/backup/photos":              3,
+		"Tax 2025":                        4,
+	} {
+		id, err := resolveVault(s.client, ref)
+		s.Require().NoError(err, ref)
+		s.Equal(expected, id, ref)
+	}
+
+	// Paths take precedence over names
+	cwd, err := os.Getwd()
+	s.Require().NoError(err)
+	s.vaults = append(s.vaults, vault{ID: 5, Path: filepath.Join(cwd, "work")})
+	id, err = resolveVault(s.client, "work")
+	s.Require().NoError(err)
+	s.EqualValues(5, id)
+
+	// Vault 2 is listed as "Holidays", vault 3 as "photos"
+	_, err = resolveVault(s.client, "/srv/vaults/photos/notes.txt")
+	s.Equal(exitUsage, exitCode(err))
+	_, err = resolveVault(s.client, "nothing")
+	s.Equal(exitUsage, exitCode(err))
+	s.Contains(err.Error(), "no vault found")
+
+	// Ambiguous names
+	s.vaults = append(s.vaults, vault{ID: 6, Path: "/media/usb/Holidays"})
+	_, err = resolveVault(s.client, "Holidays")
+	s.Equal(exitUsage, exitCode(err))
+	s.Contains(err.Error(), "2 vaults are named Holidays")
+
+	ids, err := resolveVaults(s.client, []string{"1", "/srv/backup/photos", "Tax 2025"})
+	s.Require().NoError(err)
+	s.Equal([]int64{1, 3, 4}, ids)
+	_, err = resolveVaults(s.client, []string{"1", "Holidays"})
+	s.Equal(exitUsage, exitCode(err))
+
+	// Failures of the app are told as they are
+	s.code = 1
+	_, err = resolveVault(s.client, "work")
+	s.Equal(exitApiErrorBase+1, exitCode(err))
+}
+
+func (s *cloakctlTestSuite) Test_03_PasswordReader() {
+	// STDIN redirected from a file, e.g. `cloakctl passwd work < passwords.txt`
+	path := filepath.Join(s.T().TempDir(), "passwords.txt")
+	s.Require().NoError(os.WriteFile(path, []byte("old secret\r\n\n  spaced  \nlast"), 0600))
+	file, err := os.Open(path)
+	s.Require().NoError(err)
+	defer file.Close()
+
+	// One password per line, line breaks are trimmed while spaces are kept
+	reader := &passwordReader{file: file}
+	for _, expected := range []string{"old secret", "", "  spaced  ", "last"} {
+		password, err := reader.read("Password", true)
+		s.Require().NoError(err)
+		s.Equal(expected, password)
+	}
+	_, err = reader.read("New password", false)
+	s.ErrorContains(err, "failed to read new password from STDIN")
+
+	// A pipe works the same
+	r, w, err := os.Pipe()
+	s.Require().NoError(err)
+	defer r.Close()
+	_, err = w.WriteString("piped\n")
+	s.Require().NoError(err)
+	s.Require().NoError(w.Close())
+	password, err := (&passwordReader{file: r}).read("Password", false)
+	s.Require().NoError(err)
+	s.Equal("piped", password)
+}
+
+func (s *cloakctlTestSuite) Test_04_NoInstance() {
+	dir := s.T().TempDir()
+	s.T().Setenv("XDG_RUNTIME_DIR", dir)
+	s.T().Setenv("XDG_DATA_HOME", dir)
+	xdg.Reload()
+	s.T().Cleanup(xdg.Reload)
+
+	// Arguments are checked before looking for the app
+	s.Equal(exitUsage, run([]string{"unlock", "--bogus", "1"}))
+	s.Equal(exitUsage, run([]string{"unlock"}))
+	s.Equal(exitUsage, run([]string{"create", "-backend", "gocryptfs", "/srv/vaults/new"}))
+	s.Equal(exitNoInstance, run([]string{"lock", "1"}))
+	// No password is asked for
+	s.Equal(exitNoInstance, run([]string{"unlock", "1"}))
+	s.Equal(exitNoInstance, run([]string{"passwd", "work"}))
+}
+
+func Test_Cloakctl(t *testing.T) {
+	suite.Run(t, new(cloakctlTestSuite))
+}
@@ -88,7 +88,25 @@ func (c *Configurator) SetCallback(keyPath string, cb Callback) {
 }
 
 // Load loads configuration data from INI file.
+// Callbacks are called after the lock is released, so they are free to read or set config.
 func (c *Configurator) Load() error {
+	data, err := c.load()
+	for key, value := range data {
+		if cb := c.callback(key); cb != nil {
+			if err := cb(value); err != nil {
+				logger.Warn().
+					Err(err).
+					Str("key", key).
+					Str("value", value).
+					Msg("Failed to call callback when loading settings key")
+			}
+		}
+	}
+	return err
+}
+
+// load loads configuration data from INI file, and returns the loaded key-value pairs.
+func (c *Configurator) load() (map[string]string, error) {
 	c.rw.Lock()
 	defer c.rw.Unlock()
 
@@ -102,7 +120,7 @@ func (c *Configurator) Load() error {
 				Err(err).
 				Str("filePath", c.filePath).
 				Msg("Failed to save empty INI file")
-			return err
+			return nil, err
 		}
 	}
 
@@ -112,33 +130,30 @@ func (c *Configurator) Load() error {
 			Err(err).
 			Str("filePath", c.filePath).
 			Msg("Failed to load config from INI file")
-		return err
+		return nil, err
 	}
 
 	DefaultSectionPrefix := fmt.Sprintf("%s.", ini.DefaultSection)
 	c.ini = iniFile
-	data := c.loadSections(ini.DefaultSection, c.ini.Sections())
-	for k, v := range data {
-		key := k
-		value := v
-
+	loaded := make(map[string]string)
+	for key, value := range c.loadSections(ini.DefaultSection, c.ini.Sections()) {
 		if strings.HasPrefix(key, DefaultSectionPrefix) {
 			key = strings.TrimPrefix(key, DefaultSectionPrefix)
 		}
 
 		c.data[key] = value
-		if cb, ok := c.callbacks[key]; ok {
-			if err := cb(value); err != nil {
-				logger.Warn().
-					Err(err).
-					Str("key", key).
-					Str("value", value).
-					Msg("Failed to call callback when loading settings key")
-			}
-		}
+		loaded[key] = value
 	}
 
-	return nil
+	return loaded, nil
+}
+
+// callback returns the callback of given key, or nil if there is none.
+func (c *Configurator) callback(key string) Callback {
+	c.rw.RLock()
+	defer c.rw.RUnlock()
+
+	return c.callbacks[key]
 }
 
 func (c *Configurator) loadKeys(sectionPath string, section *ini.Section) map[string]string {
@@ -202,15 +217,30 @@ func (c *Configurator) persistKey(key, value string) error {
 }
 
 // Set sets a key-value pair.
+// The callback of `key` is called after the lock is released, like in `Load`.
 func (c *Configurator) Set(key, value string) error {
-	// Only update if value differs.
-	if cv, ok := c.data[key]; ok && cv == value {
-		return nil
+	changed, err := c.set(key, value)
+	if err != nil || !changed {
+		return err
+	}
+
+	if cb := c.callback(key); cb != nil {
+		return cb(value)
 	}
 
+	return nil
+}
+
+// set stores and persists a key-value pair, it tells whether the value changed.
+func (c *Configurator) set(key, value string) (bool, error) {
 	c.rw.Lock()
 	defer c.rw.Unlock()
 
+	// Only update if value differs.
+	if cv, ok := c.data[key]; ok && cv == value {
+		return false, nil
+	}
+
 	// TODO If persistKey failed, roll back changes.
 	c.data[key] = value
 	if err := c.persistKey(key, value); err != nil {
@@ -219,14 +249,10 @@ func (c *Configurator) Set(key, value string) error {
 			Str("key", key).
 			Str("value", value).
 			Msg("Failed to persist settings after setting new key-value")
-		return err
+		return false, err
 	}
 
-	if cb, ok := c.callbacks[key]; ok {
-		return cb(value)
-	}
-
-	return nil
+	return true, nil
 }
 
 // Get gets value gor given key.
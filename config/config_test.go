@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/suite"
@@ -95,6 +96,22 @@ func (s *configTestSuite) Test_03_LoadEmpty_Set() {
 	fmt.Print(string(iniContent))
 }
 
+func (s *configTestSuite) Test_04_CallbackReadsConfig() {
+	cfg, err := NewConfigurator(filepath.Join(s.T().TempDir(), "options.ini"))
+	s.Require().NoError(err)
+	s.Require().NoError(cfg.Load())
+
+	// Callbacks may read or set other keys without deadlocking
+	cfg.SetCallback("root_key", func(v string) error {
+		s.Require().EqualValues(v, cfg.Get("root_key"))
+		return cfg.Set("other_key", v+" too")
+	})
+	s.Require().NoError(cfg.Set("root_key", "root_value"))
+	s.Require().EqualValues("root_value too", cfg.Get("other_key"))
+	s.Require().NoError(cfg.Load())
+	s.Require().EqualValues("root_value too", cfg.Get("other_key"))
+}
+
 func TestConfigurator(t *testing.T) {
 	suite.Run(t, new(configTestSuite))
 }
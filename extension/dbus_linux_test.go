@@ -0,0 +1,58 @@
+//go:build linux
+
+package extension
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const privateBusConfig = `<!DOCTYPE busconfig PUBLIC "-//freedesktop//DTD D-Bus Bus Configuration 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/busconfig.dtd">
+<busconfig>
+  <type>session</type>
+  <listen>unix:dir=%s</listen>
+  <policy context="default">
+    <allow send_destination="*" eavesdrop="true"/>
+    <allow eavesdrop="true"/>
+    <allow own="*"/>
+  </policy>
+</busconfig>`
+
+// startPrivateBus starts a private dbus-daemon for testing, and returns its address.
+// The test is skipped if dbus-daemon is not available.
+func startPrivateBus(t *testing.T) string {
+	daemon, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon not found")
+	}
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "bus.conf")
+	if err := os.WriteFile(configPath, []byte(fmt.Sprintf(privateBusConfig, dir)), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	proc := exec.Command(daemon, "--config-file", configPath, "--nofork", "--nopidfile", "--print-address=1")
+	stdout, err := proc.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := proc.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		proc.Process.Kill()
+		proc.Wait()
+	})
+
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(address)
+}
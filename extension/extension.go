@@ -1,6 +1,7 @@
 package extension
 
 import (
+	"errors"
 	"fmt"
 	"github.com/adrg/xdg"
 	"github.com/rs/zerolog"
@@ -136,6 +137,51 @@ func IsFuseAvailable() bool {
 	return isFuseAvailable()
 }
 
+// FuseMount represents a mounted FUSE filesystem.
+type FuseMount struct {
+	MountPoint string // where the filesystem is mounted
+	FsType     string // e.g. `fuse.gocryptfs`
+	Source     string // the mount source, for gocryptfs it's the cipher directory
+}
+
+// ListFuseMounts lists all FUSE filesystems currently mounted.
+func ListFuseMounts() ([]FuseMount, error) {
+	return listFuseMounts()
+}
+
+// ErrMountBusy is returned by `Unmount` if files are still open under the mountpoint.
+var ErrMountBusy = errors.New("mountpoint is busy")
+
+// Unmount unmounts the FUSE filesystem mounted at given mountpoint.
+// A lazy unmount detaches the filesystem even if it is busy or its process is gone.
+func Unmount(mountPoint string, lazy bool) error {
+	return unmount(mountPoint, lazy)
+}
+
+// Holder is a process which holds files (or its working directory) under some path.
+type Holder struct {
+	PID  int    `json:"pid"`
+	Name string `json:"name"`
+}
+
+// ListHolders lists processes holding files under given path, it's useful to tell why a mountpoint is busy.
+// Processes we are not allowed to inspect are skipped.
+func ListHolders(path string) ([]Holder, error) {
+	return listHolders(path)
+}
+
+// ProcessReadBytes returns how many bytes the process of given PID has read so far,
+// it's useful to estimate progress of a process reading through files.
+func ProcessReadBytes(pid int) (int64, error) {
+	return processReadBytes(pid)
+}
+
+// AvailableMemory returns how much memory can be allocated without swapping, in bytes.
+// It's an estimation, e.g. to keep memory-hard operations like scrypt within reach.
+func AvailableMemory() (int64, error) {
+	return availableMemory()
+}
+
 // GetAppDataDirectory locates a directory in which we can store our data.
 // The directory might not exist yet.
 func GetAppDataDirectory() string {
@@ -148,6 +194,12 @@ func GetConfigDirectory() string {
 	return filepath.Join(xdg.ConfigHome, "Cloak")
 }
 
+// GetLogDirectory locates a directory in which we can store log files.
+// The directory might not exist yet.
+func GetLogDirectory() string {
+	return locateLogDirectory()
+}
+
 // EnsureDirectoryExists makes sure given directory path exists.
 // If the directory cannot be created, or it is an existing file, an error is returned.
 func EnsureDirectoryExists(path string) (string, error) {
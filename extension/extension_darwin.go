@@ -10,9 +10,13 @@ package extension
 */
 import "C"
 import (
+	"fmt"
 	"os"
+	"os/exec"
 	"os/user"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"unsafe"
 )
 
@@ -49,3 +53,46 @@ func locateLogDirectory() string {
 	}
 	return filepath.Join(currentUser.HomeDir, "Library", "Logs")
 }
+
+// TODO Read mounts via getfsstat(2) on macOS
+func listFuseMounts() ([]FuseMount, error) {
+	return nil, fmt.Errorf("listing FUSE mounts is not supported on this platform")
+}
+
+// unmount unmounts a FUSE filesystem using `umount`, a lazy unmount is forced on macOS.
+func unmount(mountPoint string, lazy bool) error {
+	args := []string{mountPoint}
+	if lazy {
+		args = []string{"-f", mountPoint}
+	}
+	if output, err := exec.Command("umount", args...).CombinedOutput(); err != nil {
+		msg := strings.TrimSpace(string(output))
+		if strings.Contains(msg, "busy") {
+			return fmt.Errorf("%w: %s", ErrMountBusy, msg)
+		}
+		if msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// TODO Find holders via `lsof` on macOS
+func listHolders(path string) ([]Holder, error) {
+	return nil, fmt.Errorf("listing processes holding files is not supported on this platform")
+}
+
+// availableMemory returns the physical memory size on macOS, which has no simple notion of available memory.
+func availableMemory() (int64, error) {
+	output, err := exec.Command("sysctl", "-n", "hw.memsize").Output()
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(output)), 10, 64)
+}
+
+// TODO Read rusage_info via proc_pid_rusage(2) on macOS
+func processReadBytes(pid int) (int64, error) {
+	return 0, fmt.Errorf("reading process IO counters is not supported on this platform")
+}
@@ -2,6 +2,8 @@
 
 package extension
 
+import "fmt"
+
 // TODO
 func openPath(path string) {}
 
@@ -14,3 +16,28 @@ func isFuseAvailable() bool {
 func locateLogDirectory() (string, error) {
 	return "", fmt.Errorf("platform not supported")
 }
+
+// TODO
+func listFuseMounts() ([]FuseMount, error) {
+	return nil, fmt.Errorf("platform not supported")
+}
+
+// TODO
+func unmount(mountPoint string, lazy bool) error {
+	return fmt.Errorf("platform not supported")
+}
+
+// TODO
+func listHolders(path string) ([]Holder, error) {
+	return nil, fmt.Errorf("platform not supported")
+}
+
+// TODO
+func processReadBytes(pid int) (int64, error) {
+	return 0, fmt.Errorf("platform not supported")
+}
+
+// TODO
+func availableMemory() (int64, error) {
+	return 0, fmt.Errorf("platform not supported")
+}
@@ -0,0 +1,24 @@
+package extension
+
+import "errors"
+
+// ErrSecretNotFound is returned by `LookupPassword` if no password is stored for given vault.
+var ErrSecretNotFound = errors.New("no password stored in keyring")
+
+// StorePassword stores the password of given vault in the system keyring, replacing any stored one.
+// Passwords are identified by vault ID, so they survive relocating vaults. `vaultPath` only labels the password.
+func StorePassword(vaultId int64, vaultPath string, password string) error {
+	return storePassword(vaultId, vaultPath, password)
+}
+
+// LookupPassword retrieves the stored password of given vault from the system keyring.
+// `ErrSecretNotFound` is returned if there is none.
+func LookupPassword(vaultId int64) (string, error) {
+	return lookupPassword(vaultId)
+}
+
+// ForgetPassword deletes the stored password of given vault from the system keyring.
+// It's not an error if there is none.
+func ForgetPassword(vaultId int64) error {
+	return forgetPassword(vaultId)
+}
@@ -0,0 +1,260 @@
+//go:build linux
+
+package extension
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Secret Service API, see https://specifications.freedesktop.org/secret-service/
+const (
+	secretsName                = "org.freedesktop.secrets"
+	secretsPath                = dbus.ObjectPath("/org/freedesktop/secrets")
+	secretsServiceInterface    = "org.freedesktop.Secret.Service"
+	secretsCollectionInterface = "org.freedesktop.Secret.Collection"
+	secretsItemInterface       = "org.freedesktop.Secret.Item"
+	secretsSessionInterface    = "org.freedesktop.Secret.Session"
+	secretsPromptInterface     = "org.freedesktop.Secret.Prompt"
+
+	// noPrompt is returned by the service when no user interaction is needed
+	noPrompt = dbus.ObjectPath("/")
+)
+
+// How long we wait for the user to respond to a keyring prompt, e.g. for unlocking the keyring
+const secretsPromptTimeout = time.Minute * 2
+
+var errPromptDismissed = errors.New("keyring prompt dismissed")
+
+// secret is the `Secret` struct of the Secret Service API.
+type secret struct {
+	Session     dbus.ObjectPath
+	Parameters  []byte
+	Value       []byte
+	ContentType string
+}
+
+// secretAttributes returns the attributes identifying the stored password of given vault.
+func secretAttributes(vaultId int64) map[string]string {
+	return map[string]string{
+		"application": "Cloak",
+		"vault_id":    strconv.FormatInt(vaultId, 10),
+	}
+}
+
+func storePassword(vaultId int64, vaultPath string, password string) error {
+	return withSecretService(func(s *secretService) error {
+		return s.store(secretAttributes(vaultId), vaultPath, password)
+	})
+}
+
+func lookupPassword(vaultId int64) (password string, err error) {
+	err = withSecretService(func(s *secretService) error {
+		password, err = s.lookup(secretAttributes(vaultId))
+		return err
+	})
+	return
+}
+
+func forgetPassword(vaultId int64) error {
+	return withSecretService(func(s *secretService) error {
+		return s.forget(secretAttributes(vaultId))
+	})
+}
+
+// withSecretService opens a Secret Service session on the session bus for the duration of `f`.
+func withSecretService(f func(s *secretService) error) error {
+	// Shared connection, it must not be closed
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return err
+	}
+	s, err := openSecretService(conn)
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	return f(s)
+}
+
+// secretService is a session with the Secret Service.
+// Secrets are transferred in plain, which is fine since the session bus is private to current user.
+type secretService struct {
+	conn    *dbus.Conn
+	session dbus.ObjectPath
+}
+
+func openSecretService(conn *dbus.Conn) (*secretService, error) {
+	var output dbus.Variant
+	var session dbus.ObjectPath
+	err := conn.Object(secretsName, secretsPath).
+		Call(secretsServiceInterface+".OpenSession", 0, "plain", dbus.MakeVariant("")).
+		Store(&output, &session)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Secret Service session: %w", err)
+	}
+	return &secretService{conn: conn, session: session}, nil
+}
+
+func (s *secretService) close() {
+	s.conn.Object(secretsName, s.session).Call(secretsSessionInterface+".Close", 0)
+}
+
+func (s *secretService) call(path dbus.ObjectPath, method string, args ...interface{}) *dbus.Call {
+	return s.conn.Object(secretsName, path).Call(method, 0, args...)
+}
+
+// prompt shows given prompt to the user and waits for its result.
+// It does nothing if there is no need to prompt.
+func (s *secretService) prompt(path dbus.ObjectPath) (dbus.Variant, error) {
+	if path == noPrompt || path == "" {
+		return dbus.Variant{}, nil
+	}
+	match := []dbus.MatchOption{
+		dbus.WithMatchObjectPath(path),
+		dbus.WithMatchInterface(secretsPromptInterface),
+		dbus.WithMatchMember("Completed"),
+	}
+	if err := s.conn.AddMatchSignal(match...); err != nil {
+		return dbus.Variant{}, err
+	}
+	defer s.conn.RemoveMatchSignal(match...)
+	signals := make(chan *dbus.Signal, 4)
+	s.conn.Signal(signals)
+	defer s.conn.RemoveSignal(signals)
+
+	if err := s.call(path, secretsPromptInterface+".Prompt", "").Err; err != nil {
+		return dbus.Variant{}, err
+	}
+	timeout := time.After(secretsPromptTimeout)
+	for {
+		select {
+		case signal := <-signals:
+			if signal.Path != path || signal.Name != secretsPromptInterface+".Completed" || len(signal.Body) < 2 {
+				continue
+			}
+			if dismissed, _ := signal.Body[0].(bool); dismissed {
+				return dbus.Variant{}, errPromptDismissed
+			}
+			result, _ := signal.Body[1].(dbus.Variant)
+			return result, nil
+		case <-timeout:
+			return dbus.Variant{}, errPromptDismissed
+		}
+	}
+}
+
+// unlock makes sure given collections or items are unlocked, prompting the user if necessary.
+func (s *secretService) unlock(paths []dbus.ObjectPath) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	var unlocked []dbus.ObjectPath
+	var prompt dbus.ObjectPath
+	if err := s.call(secretsPath, secretsServiceInterface+".Unlock", paths).Store(&unlocked, &prompt); err != nil {
+		return err
+	}
+	_, err := s.prompt(prompt)
+	return err
+}
+
+// collection locates the default collection, it gets created if there's none.
+func (s *secretService) collection() (dbus.ObjectPath, error) {
+	var path dbus.ObjectPath
+	if err := s.call(secretsPath, secretsServiceInterface+".ReadAlias", "default").Store(&path); err != nil {
+		return "", err
+	}
+	if path == noPrompt {
+		var prompt dbus.ObjectPath
+		properties := map[string]dbus.Variant{
+			secretsCollectionInterface + ".Label": dbus.MakeVariant("Default keyring"),
+		}
+		err := s.call(secretsPath, secretsServiceInterface+".CreateCollection", properties, "default").Store(&path, &prompt)
+		if err != nil {
+			return "", err
+		}
+		if path == noPrompt {
+			result, err := s.prompt(prompt)
+			if err != nil {
+				return "", err
+			}
+			if path, _ = result.Value().(dbus.ObjectPath); path == "" {
+				return "", fmt.Errorf("failed to create default keyring")
+			}
+		}
+	}
+	return path, s.unlock([]dbus.ObjectPath{path})
+}
+
+// search finds items with stored password matching `attributes`, they are unlocked if necessary.
+func (s *secretService) search(attributes map[string]string) ([]dbus.ObjectPath, error) {
+	var unlocked, locked []dbus.ObjectPath
+	err := s.call(secretsPath, secretsServiceInterface+".SearchItems", attributes).Store(&unlocked, &locked)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.unlock(locked); err != nil {
+		return nil, err
+	}
+	return append(unlocked, locked...), nil
+}
+
+func (s *secretService) store(attributes map[string]string, vaultPath string, password string) error {
+	collection, err := s.collection()
+	if err != nil {
+		return err
+	}
+	properties := map[string]dbus.Variant{
+		secretsItemInterface + ".Label":      dbus.MakeVariant(fmt.Sprintf("Cloak vault password for %s", vaultPath)),
+		secretsItemInterface + ".Attributes": dbus.MakeVariant(attributes),
+	}
+	value := secret{
+		Session:     s.session,
+		Parameters:  []byte{},
+		Value:       []byte(password),
+		ContentType: "text/plain; charset=utf8",
+	}
+	var item, prompt dbus.ObjectPath
+	err = s.call(collection, secretsCollectionInterface+".CreateItem", properties, value, true).Store(&item, &prompt)
+	if err != nil {
+		return err
+	}
+	_, err = s.prompt(prompt)
+	return err
+}
+
+func (s *secretService) lookup(attributes map[string]string) (string, error) {
+	items, err := s.search(attributes)
+	if err != nil {
+		return "", err
+	}
+	if len(items) == 0 {
+		return "", ErrSecretNotFound
+	}
+	var value secret
+	if err := s.call(items[0], secretsItemInterface+".GetSecret", s.session).Store(&value); err != nil {
+		return "", err
+	}
+	return string(value.Value), nil
+}
+
+func (s *secretService) forget(attributes map[string]string) error {
+	items, err := s.search(attributes)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		var prompt dbus.ObjectPath
+		if err := s.call(item, secretsItemInterface+".Delete").Store(&prompt); err != nil {
+			return err
+		}
+		if _, err := s.prompt(prompt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+//go:build linux
+
+package extension
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/stretchr/testify/suite"
+)
+
+// mockSecrets is a minimal in-memory Secret Service.
+// Its default collection starts out missing, and gets locked until a prompt is completed.
+type mockSecrets struct {
+	conn       *dbus.Conn
+	lock       sync.Mutex
+	collection dbus.ObjectPath
+	locked     bool
+	items      map[dbus.ObjectPath]mockItem
+	nextId     int
+	prompts    int
+}
+
+type mockItem struct {
+	attributes map[string]string
+	value      []byte
+}
+
+func (m *mockSecrets) path(kind string) dbus.ObjectPath {
+	m.nextId++
+	return dbus.ObjectPath(fmt.Sprintf("%s/%s/%d", secretsPath, kind, m.nextId))
+}
+
+func (m *mockSecrets) OpenSession(algorithm string, input dbus.Variant) (dbus.Variant, dbus.ObjectPath, *dbus.Error) {
+	if algorithm != "plain" {
+		return dbus.Variant{}, "", dbus.MakeFailedError(fmt.Errorf("algorithm %s not supported", algorithm))
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	session := m.path("session")
+	m.conn.Export(mockSession{}, session, secretsSessionInterface)
+	return dbus.MakeVariant(""), session, nil
+}
+
+func (m *mockSecrets) ReadAlias(name string) (dbus.ObjectPath, *dbus.Error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if name != "default" || m.collection == "" {
+		return noPrompt, nil
+	}
+	return m.collection, nil
+}
+
+func (m *mockSecrets) CreateCollection(properties map[string]dbus.Variant, alias string) (dbus.ObjectPath, dbus.ObjectPath, *dbus.Error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.collection = m.path("collection")
+	m.locked = true
+	m.conn.Export(mockCollection{m}, m.collection, secretsCollectionInterface)
+	return m.collection, noPrompt, nil
+}
+
+func (m *mockSecrets) Unlock(objects []dbus.ObjectPath) ([]dbus.ObjectPath, dbus.ObjectPath, *dbus.Error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if !m.locked {
+		return objects, noPrompt, nil
+	}
+	prompt := m.path("prompt")
+	m.conn.Export(mockPrompt{m, prompt, objects}, prompt, secretsPromptInterface)
+	return nil, prompt, nil
+}
+
+func (m *mockSecrets) SearchItems(attributes map[string]string) ([]dbus.ObjectPath, []dbus.ObjectPath, *dbus.Error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var found []dbus.ObjectPath
+	for path, item := range m.items {
+		matched := true
+		for k, v := range attributes {
+			matched = matched && item.attributes[k] == v
+		}
+		if matched {
+			found = append(found, path)
+		}
+	}
+	if m.locked {
+		return nil, found, nil
+	}
+	return found, nil, nil
+}
+
+type mockSession struct{}
+
+func (mockSession) Close() *dbus.Error {
+	return nil
+}
+
+type mockPrompt struct {
+	m       *mockSecrets
+	path    dbus.ObjectPath
+	objects []dbus.ObjectPath
+}
+
+func (p mockPrompt) Prompt(windowId string) *dbus.Error {
+	p.m.lock.Lock()
+	p.m.locked = false
+	p.m.prompts++
+	p.m.lock.Unlock()
+	go p.m.conn.Emit(p.path, secretsPromptInterface+".Completed", false, dbus.MakeVariant(p.objects))
+	return nil
+}
+
+type mockCollection struct {
+	m *mockSecrets
+}
+
+func (c mockCollection) CreateItem(properties map[string]dbus.Variant, value secret, replace bool) (dbus.ObjectPath, dbus.ObjectPath, *dbus.Error) {
+	c.m.lock.Lock()
+	defer c.m.lock.Unlock()
+	if c.m.locked {
+		return "", "", dbus.MakeFailedError(fmt.Errorf("collection is locked"))
+	}
+	attributes, ok := properties[secretsItemInterface+".Attributes"].Value().(map[string]string)
+	if !ok {
+		return "", "", dbus.MakeFailedError(fmt.Errorf("missing attributes"))
+	}
+	for path, item := range c.m.items {
+		if replace && fmt.Sprint(item.attributes) == fmt.Sprint(attributes) {
+			c.m.items[path] = mockItem{attributes: attributes, value: value.Value}
+			return path, noPrompt, nil
+		}
+	}
+	path := c.m.path("item")
+	c.m.items[path] = mockItem{attributes: attributes, value: value.Value}
+	c.m.conn.Export(mockItemObject{c.m, path}, path, secretsItemInterface)
+	return path, noPrompt, nil
+}
+
+type mockItemObject struct {
+	m    *mockSecrets
+	path dbus.ObjectPath
+}
+
+func (i mockItemObject) GetSecret(session dbus.ObjectPath) (secret, *dbus.Error) {
+	i.m.lock.Lock()
+	defer i.m.lock.Unlock()
+	item, ok := i.m.items[i.path]
+	if !ok || i.m.locked {
+		return secret{}, dbus.MakeFailedError(fmt.Errorf("item unavailable"))
+	}
+	return secret{Session: session, Parameters: []byte{}, Value: item.value, ContentType: "text/plain"}, nil
+}
+
+func (i mockItemObject) Delete() (dbus.ObjectPath, *dbus.Error) {
+	i.m.lock.Lock()
+	defer i.m.lock.Unlock()
+	delete(i.m.items, i.path)
+	i.m.conn.Export(nil, i.path, secretsItemInterface)
+	return noPrompt, nil
+}
+
+type keyringTestSuite struct {
+	suite.Suite
+	secrets *mockSecrets
+}
+
+func (s *keyringTestSuite) SetupSuite() {
+	address := startPrivateBus(s.T())
+	// Our keyring functions use the shared session bus connection, which gets connected on first use
+	s.T().Setenv("DBUS_SESSION_BUS_ADDRESS", address)
+
+	conn, err := dbus.Connect(address)
+	s.Require().NoError(err)
+	s.secrets = &mockSecrets{conn: conn, items: make(map[dbus.ObjectPath]mockItem)}
+	s.Require().NoError(conn.Export(s.secrets, secretsPath, secretsServiceInterface))
+	reply, err := conn.RequestName(secretsName, dbus.NameFlagDoNotQueue)
+	s.Require().NoError(err)
+	s.Require().EqualValues(dbus.RequestNameReplyPrimaryOwner, reply)
+}
+
+func (s *keyringTestSuite) TearDownSuite() {
+	s.secrets.conn.Close()
+}
+
+func (s *keyringTestSuite) Test_01_NotFound() {
+	_, err := LookupPassword(1)
+	s.Require().ErrorIs(err, ErrSecretNotFound)
+	s.Require().NoError(ForgetPassword(1))
+}
+
+func (s *keyringTestSuite) Test_02_Store() {
+	// The default collection gets created, then unlocked through a prompt
+	s.Require().NoError(StorePassword(1, "/vaults/a", "password a"))
+	s.Require().NoError(StorePassword(2, "/vaults/b", "password b"))
+	s.Require().EqualValues(1, s.secrets.prompts)
+	s.Require().Len(s.secrets.items, 2)
+
+	password, err := LookupPassword(1)
+	s.Require().NoError(err)
+	s.Require().EqualValues("password a", password)
+
+	// Replaced, the vault might have been relocated meanwhile
+	s.Require().NoError(StorePassword(1, "/vaults/moved", "new password"))
+	s.Require().Len(s.secrets.items, 2)
+	password, err = LookupPassword(1)
+	s.Require().NoError(err)
+	s.Require().EqualValues("new password", password)
+}
+
+func (s *keyringTestSuite) Test_03_Locked() {
+	// Stored passwords are unlocked on demand
+	s.secrets.lock.Lock()
+	s.secrets.locked = true
+	s.secrets.lock.Unlock()
+	password, err := LookupPassword(2)
+	s.Require().NoError(err)
+	s.Require().EqualValues("password b", password)
+	s.Require().EqualValues(2, s.secrets.prompts)
+}
+
+func (s *keyringTestSuite) Test_04_Forget() {
+	s.Require().NoError(ForgetPassword(1))
+	_, err := LookupPassword(1)
+	s.Require().ErrorIs(err, ErrSecretNotFound)
+	password, err := LookupPassword(2)
+	s.Require().NoError(err)
+	s.Require().EqualValues("password b", password)
+}
+
+func Test_Keyring(t *testing.T) {
+	suite.Run(t, new(keyringTestSuite))
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+
+package extension
+
+import "fmt"
+
+var errKeyringUnsupported = fmt.Errorf("keyring is not supported on this platform")
+
+// TODO Use Keychain on macOS
+func storePassword(vaultId int64, vaultPath string, password string) error {
+	return errKeyringUnsupported
+}
+
+func lookupPassword(vaultId int64) (string, error) {
+	return "", errKeyringUnsupported
+}
+
+func forgetPassword(vaultId int64) error {
+	return errKeyringUnsupported
+}
@@ -0,0 +1,191 @@
+//go:build linux
+
+package extension
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// listFuseMounts reads FUSE mounts from `/proc/self/mountinfo`.
+func listFuseMounts() ([]FuseMount, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseMountInfo(f)
+}
+
+// parseMountInfo parses content of a mountinfo file and returns FUSE mounts found in it.
+// See `man 5 proc` for the format, a line looks like:
+//
+//	36 35 98:0 / /tmp/1a2b3c rw,nosuid,nodev shared:1 - fuse.gocryptfs /home/me/vault rw,user_id=1000
+func parseMountInfo(r io.Reader) ([]FuseMount, error) {
+	var mounts []FuseMount
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// Optional fields are terminated by a single hyphen
+		sep := -1
+		for i := 6; i < len(fields); i++ {
+			if fields[i] == "-" {
+				sep = i
+				break
+			}
+		}
+		if len(fields) < 5 || sep < 0 || len(fields) < sep+3 {
+			return nil, fmt.Errorf("malformed mountinfo line: %q", scanner.Text())
+		}
+		fsType := fields[sep+1]
+		if fsType != "fuse" && !strings.HasPrefix(fsType, "fuse.") {
+			continue
+		}
+		mounts = append(mounts, FuseMount{
+			MountPoint: unescapeMountInfo(fields[4]),
+			FsType:     fsType,
+			Source:     unescapeMountInfo(fields[sep+2]),
+		})
+	}
+	return mounts, scanner.Err()
+}
+
+// unescapeMountInfo decodes octal escapes (e.g. `\040` for space) used in mountinfo fields.
+func unescapeMountInfo(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if c, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// unmount unmounts a FUSE filesystem using `fusermount`.
+func unmount(mountPoint string, lazy bool) error {
+	args := []string{"-u"}
+	if lazy {
+		args = append(args, "-z")
+	}
+	args = append(args, "--", mountPoint)
+	var errorOutput bytes.Buffer
+	proc := exec.Command("fusermount", args...)
+	proc.Stderr = &errorOutput
+	if err := proc.Run(); err != nil {
+		msg := strings.TrimSpace(errorOutput.String())
+		if strings.Contains(msg, "busy") {
+			return fmt.Errorf("%w: %s", ErrMountBusy, msg)
+		}
+		if msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// listHolders finds processes holding files under given path by scanning `/proc/*/fd` and `/proc/*/cwd`.
+func listHolders(path string) ([]Holder, error) {
+	return scanHolders("/proc", path)
+}
+
+// processReadBytes reads `rchar` from `/proc/<pid>/io`.
+func processReadBytes(pid int) (int64, error) {
+	return readProcIO("/proc", pid)
+}
+
+// availableMemory reads `MemAvailable` from `/proc/meminfo`.
+func availableMemory() (int64, error) {
+	return readMemAvailable("/proc")
+}
+
+// readMemAvailable reads available memory from procfs mounted at `procRoot`, the value is in KiB there.
+func readMemAvailable(procRoot string) (int64, error) {
+	content, err := os.ReadFile(filepath.Join(procRoot, "meminfo"))
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if value := strings.TrimPrefix(line, "MemAvailable:"); value != line {
+			kib, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimSpace(value), " kB"), 10, 64)
+			if err != nil {
+				return 0, err
+			}
+			return kib << 10, nil
+		}
+	}
+	return 0, fmt.Errorf("MemAvailable not found in %s/meminfo", procRoot)
+}
+
+// readProcIO reads the bytes read by process `pid` from procfs mounted at `procRoot`.
+func readProcIO(procRoot string, pid int) (int64, error) {
+	content, err := os.ReadFile(filepath.Join(procRoot, strconv.Itoa(pid), "io"))
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if value := strings.TrimPrefix(line, "rchar:"); value != line {
+			return strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		}
+	}
+	return 0, fmt.Errorf("rchar not found in %s/%d/io", procRoot, pid)
+}
+
+// scanHolders scans procfs mounted at `procRoot` for processes holding files under `path`.
+func scanHolders(procRoot string, path string) ([]Holder, error) {
+	entries, err := os.ReadDir(procRoot)
+	if err != nil {
+		return nil, err
+	}
+	path = filepath.Clean(path)
+	isUnder := func(target string) bool {
+		target = strings.TrimSuffix(target, " (deleted)")
+		return target == path || strings.HasPrefix(target, path+"/")
+	}
+
+	var holders []Holder
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || pid == os.Getpid() {
+			continue
+		}
+		procDir := filepath.Join(procRoot, entry.Name())
+
+		holding := false
+		if cwd, err := os.Readlink(filepath.Join(procDir, "cwd")); err == nil && isUnder(cwd) {
+			holding = true
+		}
+		if !holding {
+			// Permission denied for processes of other users, skip them
+			fds, _ := os.ReadDir(filepath.Join(procDir, "fd"))
+			for _, fd := range fds {
+				if target, err := os.Readlink(filepath.Join(procDir, "fd", fd.Name())); err == nil && isUnder(target) {
+					holding = true
+					break
+				}
+			}
+		}
+		if !holding {
+			continue
+		}
+
+		name, _ := os.ReadFile(filepath.Join(procDir, "comm"))
+		holders = append(holders, Holder{PID: pid, Name: strings.TrimSpace(string(name))})
+	}
+	return holders, nil
+}
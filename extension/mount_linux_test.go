@@ -0,0 +1,109 @@
+//go:build linux
+
+package extension
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type mountInfoTestSuite struct {
+	suite.Suite
+}
+
+func (s *mountInfoTestSuite) TestParseMountInfo() {
+	mountInfo := strings.Join([]string{
+		`22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw`,
+		`45 22 0:40 / /tmp/1a2b3c rw,nosuid,nodev,relatime shared:30 - fuse.gocryptfs /home/me/vault rw,user_id=1000,group_id=1000`,
+		`46 22 0:41 / /home/me/My\040Drive rw,nosuid,nodev - fuse.gocryptfs /home/me/My\040Vault rw,user_id=1000`,
+		`47 22 0:42 / /run/user/1000/doc rw,nosuid,nodev,relatime - fuse.portal portal rw,user_id=1000`,
+		`48 22 0:43 / /mnt/plain rw - fuse /dev/fuse rw`,
+	}, "\n")
+
+	mounts, err := parseMountInfo(strings.NewReader(mountInfo))
+	s.Require().NoError(err)
+	s.Equal([]FuseMount{
+		{MountPoint: "/tmp/1a2b3c", FsType: "fuse.gocryptfs", Source: "/home/me/vault"},
+		{MountPoint: "/home/me/My Drive", FsType: "fuse.gocryptfs", Source: "/home/me/My Vault"},
+		{MountPoint: "/run/user/1000/doc", FsType: "fuse.portal", Source: "portal"},
+		{MountPoint: "/mnt/plain", FsType: "fuse", Source: "/dev/fuse"},
+	}, mounts)
+}
+
+func (s *mountInfoTestSuite) TestParseMalformedMountInfo() {
+	_, err := parseMountInfo(strings.NewReader("45 22 0:40 / /tmp/1a2b3c rw"))
+	s.Error(err)
+}
+
+func (s *mountInfoTestSuite) TestScanHolders() {
+	procRoot := s.T().TempDir()
+	process := func(pid string, comm string, cwd string, fds ...string) {
+		procDir := filepath.Join(procRoot, pid)
+		s.Require().NoError(os.MkdirAll(filepath.Join(procDir, "fd"), 0700))
+		s.Require().NoError(os.WriteFile(filepath.Join(procDir, "comm"), []byte(comm+"\n"), 0600))
+		s.Require().NoError(os.Symlink(cwd, filepath.Join(procDir, "cwd")))
+		for i, fd := range fds {
+			s.Require().NoError(os.Symlink(fd, filepath.Join(procDir, "fd", strings.Repeat("1", i+1))))
+		}
+	}
+	process("100", "bash", "/tmp/1a2b3c/docs")
+	process("200", "vim", "/home/me", "/dev/pts/0", "/tmp/1a2b3c/notes.txt")
+	process("300", "rm", "/home/me", "/tmp/1a2b3c/gone.txt (deleted)")
+	process("400", "cat", "/tmp/1a2b3cd", "/tmp/1a2b3cd/other.txt")
+	process("500", "sleep", "/", "socket:[12345]")
+	s.Require().NoError(os.MkdirAll(filepath.Join(procRoot, "sys"), 0700))
+
+	holders, err := scanHolders(procRoot, "/tmp/1a2b3c/")
+	s.Require().NoError(err)
+	s.Equal([]Holder{
+		{PID: 100, Name: "bash"},
+		{PID: 200, Name: "vim"},
+		{PID: 300, Name: "rm"},
+	}, holders)
+}
+
+func (s *mountInfoTestSuite) TestReadMemAvailable() {
+	procRoot := s.T().TempDir()
+	s.Require().NoError(os.WriteFile(filepath.Join(procRoot, "meminfo"),
+		[]byte("MemTotal:       16315576 kB\nMemFree:         1209052 kB\nMemAvailable:    8157788 kB\n"), 0600))
+	available, err := readMemAvailable(procRoot)
+	s.Require().NoError(err)
+	s.EqualValues(8157788<<10, available)
+
+	s.Require().NoError(os.WriteFile(filepath.Join(procRoot, "meminfo"), []byte("MemTotal:       16315576 kB\n"), 0600))
+	_, err = readMemAvailable(procRoot)
+	s.Error(err)
+
+	available, err = AvailableMemory()
+	s.Require().NoError(err)
+	s.Greater(available, int64(0))
+}
+
+func (s *mountInfoTestSuite) TestReadProcIO() {
+	procRoot := s.T().TempDir()
+	s.Require().NoError(os.MkdirAll(filepath.Join(procRoot, "100"), 0700))
+	s.Require().NoError(os.WriteFile(filepath.Join(procRoot, "100", "io"),
+		[]byte("rchar: 123456\nwchar: 42\nsyscr: 10\nread_bytes: 4096\n"), 0600))
+	s.Require().NoError(os.MkdirAll(filepath.Join(procRoot, "200"), 0700))
+	s.Require().NoError(os.WriteFile(filepath.Join(procRoot, "200", "io"), []byte("wchar: 42\n"), 0600))
+
+	read, err := readProcIO(procRoot, 100)
+	s.Require().NoError(err)
+	s.EqualValues(123456, read)
+	_, err = readProcIO(procRoot, 200)
+	s.Error(err)
+	_, err = readProcIO(procRoot, 300)
+	s.Error(err)
+
+	read, err = ProcessReadBytes(os.Getpid())
+	s.Require().NoError(err)
+	s.Greater(read, int64(0))
+}
+
+func TestMountInfo(t *testing.T) {
+	suite.Run(t, new(mountInfoTestSuite))
+}
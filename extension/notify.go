@@ -0,0 +1,13 @@
+package extension
+
+// Urgency levels of desktop notifications
+const (
+	UrgencyLow      = 0
+	UrgencyNormal   = 1
+	UrgencyCritical = 2
+)
+
+// Notify shows a desktop notification with given summary and body, `urgency` is one of the urgency levels.
+func Notify(summary string, body string, urgency int) error {
+	return notify(summary, body, urgency)
+}
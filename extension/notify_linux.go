@@ -0,0 +1,41 @@
+//go:build linux
+
+package extension
+
+import "github.com/godbus/dbus/v5"
+
+// Desktop Notifications API, see https://specifications.freedesktop.org/notification-spec/
+const (
+	notificationsName      = "org.freedesktop.Notifications"
+	notificationsPath      = dbus.ObjectPath("/org/freedesktop/Notifications")
+	notificationsInterface = "org.freedesktop.Notifications"
+)
+
+func notify(summary string, body string, urgency int) error {
+	// Shared connection, it must not be closed
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return err
+	}
+	return sendNotification(conn, summary, body, urgency)
+}
+
+// sendNotification asks the notification server on given connection to show a notification.
+// The notification expires as the server sees fit.
+func sendNotification(conn *dbus.Conn, summary string, body string, urgency int) error {
+	hints := map[string]dbus.Variant{
+		"urgency":       dbus.MakeVariant(byte(urgency)),
+		"desktop-entry": dbus.MakeVariant("Cloak"),
+	}
+	return conn.Object(notificationsName, notificationsPath).Call(
+		notificationsInterface+".Notify", 0,
+		"Cloak",    // app_name
+		uint32(0),  // replaces_id, nothing to replace
+		"Cloak",    // app_icon, as in Cloak.desktop
+		summary,    // summary
+		body,       // body
+		[]string{}, // actions
+		hints,      // hints
+		int32(-1),  // expire_timeout, server default
+	).Err
+}
@@ -0,0 +1,90 @@
+//go:build linux
+
+package extension
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/stretchr/testify/suite"
+)
+
+// mockNotification is a notification received by mockNotifications.
+type mockNotification struct {
+	appName string
+	icon    string
+	summary string
+	body    string
+	urgency byte
+}
+
+// mockNotifications stands in for a notification daemon, it records notifications received.
+type mockNotifications struct {
+	lock          sync.Mutex
+	notifications []mockNotification
+}
+
+func (m *mockNotifications) Notify(
+	appName string, replacesId uint32, icon string, summary string, body string,
+	actions []string, hints map[string]dbus.Variant, expireTimeout int32,
+) (uint32, *dbus.Error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var urgency byte
+	if v, ok := hints["urgency"]; ok {
+		urgency, _ = v.Value().(byte)
+	}
+	m.notifications = append(m.notifications, mockNotification{appName, icon, summary, body, urgency})
+	return uint32(len(m.notifications)), nil
+}
+
+type notifyTestSuite struct {
+	suite.Suite
+	daemonConn    *dbus.Conn
+	conn          *dbus.Conn
+	notifications *mockNotifications
+}
+
+func (s *notifyTestSuite) SetupSuite() {
+	address := startPrivateBus(s.T())
+
+	var err error
+	s.daemonConn, err = dbus.Connect(address)
+	s.Require().NoError(err)
+	s.notifications = &mockNotifications{}
+	s.Require().NoError(s.daemonConn.Export(s.notifications, notificationsPath, notificationsInterface))
+	reply, err := s.daemonConn.RequestName(notificationsName, dbus.NameFlagDoNotQueue)
+	s.Require().NoError(err)
+	s.Require().EqualValues(dbus.RequestNameReplyPrimaryOwner, reply)
+
+	s.conn, err = dbus.Connect(address)
+	s.Require().NoError(err)
+}
+
+func (s *notifyTestSuite) TearDownSuite() {
+	s.conn.Close()
+	s.daemonConn.Close()
+}
+
+func (s *notifyTestSuite) Test_01_Notify() {
+	s.Require().NoError(sendNotification(s.conn, "Vault locked", "work was locked", UrgencyCritical))
+	s.Require().NoError(sendNotification(s.conn, "Vault unlocked", "", UrgencyLow))
+
+	s.notifications.lock.Lock()
+	defer s.notifications.lock.Unlock()
+	s.Require().Equal([]mockNotification{
+		{"Cloak", "Cloak", "Vault locked", "work was locked", UrgencyCritical},
+		{"Cloak", "Cloak", "Vault unlocked", "", UrgencyLow},
+	}, s.notifications.notifications)
+}
+
+func (s *notifyTestSuite) Test_02_NoDaemon() {
+	// Nobody serves notifications once the daemon is gone
+	s.Require().NoError(s.daemonConn.Close())
+	s.Require().Error(sendNotification(s.conn, "Vault locked", "", UrgencyNormal))
+}
+
+func Test_Notify(t *testing.T) {
+	suite.Run(t, new(notifyTestSuite))
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package extension
+
+import "fmt"
+
+// TODO Use UNUserNotificationCenter on macOS
+func notify(summary string, body string, urgency int) error {
+	return fmt.Errorf("desktop notifications are not supported on this platform")
+}
@@ -0,0 +1,129 @@
+package extension
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// RotatingFile is an io.WriteCloser appending to a file, which gets rotated once it grows beyond a size limit.
+// Rotated files are renamed to `path.1`, `path.2` and so on, the oldest ones beyond `backups` are removed.
+type RotatingFile struct {
+	path    string
+	maxSize int64
+	backups int
+
+	lock sync.Mutex
+	file *os.File
+	size int64
+}
+
+// OpenRotatingFile opens given file for appending, creating it if necessary.
+// The file is rotated once it grows beyond `maxSize` bytes, keeping at most `backups` rotated files.
+func OpenRotatingFile(path string, maxSize int64, backups int) (*RotatingFile, error) {
+	r := &RotatingFile{path: path, maxSize: maxSize, backups: backups}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// open opens the current file, picking up its size.
+func (r *RotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	r.file = file
+	r.size = info.Size()
+	return nil
+}
+
+// rotate closes the current file, shifts rotated files by one and opens a new file.
+func (r *RotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	if r.backups > 0 {
+		for i := r.backups - 1; i > 0; i-- {
+			from := fmt.Sprintf("%s.%d", r.path, i)
+			if err := os.Rename(from, fmt.Sprintf("%s.%d", r.path, i+1)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		if err := os.Rename(r.path, r.path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(r.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return r.open()
+}
+
+// Write appends `p` to the file, rotating the file first if `p` doesn't fit in it.
+// `p` is never split, so a single write larger than the size limit ends up in a file of its own.
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+	if r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Close closes the file, further writes fail.
+func (r *RotatingFile) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// RemoveRotatingFile removes given file along with its rotated files, no matter how many backups were kept.
+// It's not an error if there is none.
+func RemoveRotatingFile(path string) error {
+	rotated, err := filepath.Glob(path + ".*")
+	if err != nil {
+		return err
+	}
+	for _, file := range append(rotated, path) {
+		// Rotated files are suffixed with their numbers only
+		if suffix := strings.TrimPrefix(file, path+"."); file != path && !isDigits(suffix) {
+			continue
+		}
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// isDigits tells whether given string is non-empty and consists of ASCII digits only.
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,84 @@
+package extension
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type rotateTestSuite struct {
+	suite.Suite
+	path string
+}
+
+func (s *rotateTestSuite) SetupTest() {
+	s.path = filepath.Join(s.T().TempDir(), "gocryptfs.log")
+}
+
+// content returns content of given file, or an empty string if it doesn't exist.
+func (s *rotateTestSuite) content(path string) string {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return ""
+	}
+	s.Require().NoError(err)
+	return string(data)
+}
+
+func (s *rotateTestSuite) Test_01_Rotate() {
+	f, err := OpenRotatingFile(s.path, 10, 2)
+	s.Require().NoError(err)
+
+	for _, line := range []string{"aaaa\n", "bbbb\n", "cccc\n", "dddd\n", "eeee\n", "ffff\n", "gggg\n"} {
+		_, err := f.Write([]byte(line))
+		s.Require().NoError(err)
+	}
+	s.Require().NoError(f.Close())
+
+	s.Equal("gggg\n", s.content(s.path))
+	s.Equal("eeee\nffff\n", s.content(s.path+".1"))
+	s.Equal("cccc\ndddd\n", s.content(s.path+".2"))
+	s.Equal("", s.content(s.path+".3"))
+
+	_, err = f.Write([]byte("hhhh\n"))
+	s.ErrorIs(err, os.ErrClosed)
+}
+
+func (s *rotateTestSuite) Test_02_Append() {
+	s.Require().NoError(os.WriteFile(s.path, []byte("aaaa\n"), 0600))
+
+	f, err := OpenRotatingFile(s.path, 10, 0)
+	s.Require().NoError(err)
+	_, err = f.Write([]byte("bbbb\n"))
+	s.Require().NoError(err)
+	s.Equal("aaaa\nbbbb\n", s.content(s.path))
+
+	// Without backups, the file just starts over
+	_, err = f.Write([]byte("a write larger than the limit\n"))
+	s.Require().NoError(err)
+	s.Require().NoError(f.Close())
+	s.Equal("a write larger than the limit\n", s.content(s.path))
+	s.Equal("", s.content(s.path+".1"))
+}
+
+func (s *rotateTestSuite) Test_03_Remove() {
+	for _, path := range []string{s.path, s.path + ".1", s.path + ".12", s.path + ".old", s.path + "2"} {
+		s.Require().NoError(os.WriteFile(path, []byte("aaaa\n"), 0600))
+	}
+	s.Require().NoError(RemoveRotatingFile(s.path))
+	for _, path := range []string{s.path, s.path + ".1", s.path + ".12"} {
+		s.NoFileExists(path)
+	}
+	// Files of others are kept
+	s.FileExists(s.path + ".old")
+	s.FileExists(s.path + "2")
+
+	// Nothing to remove
+	s.Require().NoError(RemoveRotatingFile(s.path))
+}
+
+func Test_Rotate(t *testing.T) {
+	suite.Run(t, new(rotateTestSuite))
+}
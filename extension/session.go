@@ -0,0 +1,13 @@
+package extension
+
+// Session events which should lock vaults
+const (
+	SessionEventSleep = "sleep" // system is about to suspend or hibernate
+	SessionEventLock  = "lock"  // user session or screen got locked
+)
+
+// WatchSession watches for system suspend and screen lock, `handler` gets called with a session event.
+// It returns a function to stop watching.
+func WatchSession(handler func(event string)) (func(), error) {
+	return watchSession(handler)
+}
@@ -0,0 +1,193 @@
+//go:build linux
+
+package extension
+
+import (
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	logindName             = "org.freedesktop.login1"
+	logindPath             = dbus.ObjectPath("/org/freedesktop/login1")
+	logindManagerInterface = "org.freedesktop.login1.Manager"
+	logindSessionInterface = "org.freedesktop.login1.Session"
+)
+
+// Screensavers emitting `ActiveChanged(bool)` on the session bus
+var screenSaverInterfaces = []string{
+	"org.freedesktop.ScreenSaver",
+	"org.gnome.ScreenSaver",
+	"org.cinnamon.ScreenSaver",
+	"org.mate.ScreenSaver",
+}
+
+// watchSession listens for logind signals on the system bus, and screensaver signals on the session bus.
+func watchSession(handler func(event string)) (func(), error) {
+	systemConn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return nil, err
+	}
+	// Screensaver signals are optional, they are not available without a graphical session
+	sessionConn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		logger := GetLogger("extension")
+		logger.Warn().Err(err).
+			Msg("Failed to connect to D-Bus session bus, screensaver will not be watched")
+		sessionConn = nil
+	}
+
+	stop, err := watchSessionSignals(systemConn, sessionConn, handler)
+	if err != nil {
+		systemConn.Close()
+		if sessionConn != nil {
+			sessionConn.Close()
+		}
+		return nil, err
+	}
+	return func() {
+		stop()
+		systemConn.Close()
+		if sessionConn != nil {
+			sessionConn.Close()
+		}
+	}, nil
+}
+
+// watchSessionSignals subscribes to session signals on given connections.
+// `sessionConn` might be nil.
+func watchSessionSignals(systemConn *dbus.Conn, sessionConn *dbus.Conn, handler func(event string)) (func(), error) {
+	logger := GetLogger("extension")
+
+	// Only react to locking of our own session, if we're able to find it
+	sessionPath := locateLogindSession(systemConn)
+	lockMatch := []dbus.MatchOption{
+		dbus.WithMatchInterface(logindSessionInterface),
+		dbus.WithMatchMember("Lock"),
+	}
+	if sessionPath != "" {
+		lockMatch = append(lockMatch, dbus.WithMatchObjectPath(sessionPath))
+	}
+	matches := map[*dbus.Conn][][]dbus.MatchOption{
+		systemConn: {
+			{dbus.WithMatchInterface(logindManagerInterface), dbus.WithMatchMember("PrepareForSleep")},
+			lockMatch,
+		},
+	}
+	if sessionConn != nil {
+		for _, iface := range screenSaverInterfaces {
+			matches[sessionConn] = append(matches[sessionConn], []dbus.MatchOption{
+				dbus.WithMatchInterface(iface),
+				dbus.WithMatchMember("ActiveChanged"),
+			})
+		}
+	}
+
+	signals := make(chan *dbus.Signal, 16)
+	for conn, connMatches := range matches {
+		for _, match := range connMatches {
+			if err := conn.AddMatchSignal(match...); err != nil {
+				return nil, err
+			}
+		}
+		conn.Signal(signals)
+	}
+
+	// Delay suspending until we have locked the vaults
+	inhibitor := takeSleepInhibitor(systemConn)
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case signal := <-signals:
+				active, _ := signalBoolArg(signal)
+				switch {
+				case signal.Name == logindManagerInterface+".PrepareForSleep":
+					if !active { // Resumed, prepare for the next sleep
+						if inhibitor < 0 {
+							inhibitor = takeSleepInhibitor(systemConn)
+						}
+						continue
+					}
+					logger.Info().Msg("System is going to sleep")
+					handler(SessionEventSleep)
+					if inhibitor >= 0 {
+						syscall.Close(inhibitor)
+						inhibitor = -1
+					}
+				case signal.Name == logindSessionInterface+".Lock":
+					if sessionPath != "" && signal.Path != sessionPath {
+						continue
+					}
+					logger.Info().Str("session", string(signal.Path)).Msg("Session locked")
+					handler(SessionEventLock)
+				case strings.HasSuffix(signal.Name, ".ActiveChanged"):
+					if !active {
+						continue
+					}
+					logger.Info().Str("signal", signal.Name).Msg("Screensaver activated")
+					handler(SessionEventLock)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		for conn, connMatches := range matches {
+			conn.RemoveSignal(signals)
+			for _, match := range connMatches {
+				_ = conn.RemoveMatchSignal(match...)
+			}
+		}
+		if inhibitor >= 0 {
+			syscall.Close(inhibitor)
+		}
+	}, nil
+}
+
+// locateLogindSession finds the logind session object of current process.
+// An empty path is returned if it cannot be found.
+func locateLogindSession(conn *dbus.Conn) dbus.ObjectPath {
+	var path dbus.ObjectPath
+	manager := conn.Object(logindName, logindPath)
+	if sessionId := os.Getenv("XDG_SESSION_ID"); sessionId != "" {
+		if err := manager.Call(logindManagerInterface+".GetSession", 0, sessionId).Store(&path); err == nil {
+			return path
+		}
+	}
+	if err := manager.Call(logindManagerInterface+".GetSessionByPID", 0, uint32(os.Getpid())).Store(&path); err == nil {
+		return path
+	}
+	return ""
+}
+
+// takeSleepInhibitor takes a delay lock from logind, so system suspending waits for us.
+// It returns the file descriptor of the lock, or -1 if it cannot be taken.
+func takeSleepInhibitor(conn *dbus.Conn) int {
+	var fd dbus.UnixFD
+	err := conn.Object(logindName, logindPath).
+		Call(logindManagerInterface+".Inhibit", 0, "sleep", "Cloak", "Locking vaults", "delay").
+		Store(&fd)
+	if err != nil {
+		logger := GetLogger("extension")
+		logger.Debug().Err(err).Msg("Failed to take sleep inhibitor lock")
+		return -1
+	}
+	return int(fd)
+}
+
+// signalBoolArg returns the first argument of a signal, if it's a bool.
+func signalBoolArg(signal *dbus.Signal) (bool, bool) {
+	if len(signal.Body) == 0 {
+		return false, false
+	}
+	v, ok := signal.Body[0].(bool)
+	return v, ok
+}
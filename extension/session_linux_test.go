@@ -0,0 +1,77 @@
+//go:build linux
+
+package extension
+
+import (
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/stretchr/testify/suite"
+)
+
+type sessionTestSuite struct {
+	suite.Suite
+	events  chan string
+	emitter *dbus.Conn
+	stop    func()
+}
+
+func (s *sessionTestSuite) SetupSuite() {
+	address := startPrivateBus(s.T())
+
+	// The private bus plays both roles of system bus and session bus
+	systemConn, err := dbus.Connect(address)
+	s.Require().NoError(err)
+	sessionConn, err := dbus.Connect(address)
+	s.Require().NoError(err)
+	s.emitter, err = dbus.Connect(address)
+	s.Require().NoError(err)
+
+	s.events = make(chan string, 10)
+	s.stop, err = watchSessionSignals(systemConn, sessionConn, func(event string) {
+		s.events <- event
+	})
+	s.Require().NoError(err)
+}
+
+func (s *sessionTestSuite) TearDownSuite() {
+	s.stop()
+	s.emitter.Close()
+}
+
+// expectEvent checks the next received session event, an empty `expected` means no event at all.
+func (s *sessionTestSuite) expectEvent(expected string) {
+	select {
+	case event := <-s.events:
+		s.Require().EqualValues(expected, event)
+	case <-time.After(time.Millisecond * 500):
+		s.Require().Empty(expected, "no session event received")
+	}
+}
+
+func (s *sessionTestSuite) Test_01_Sleep() {
+	s.Require().NoError(s.emitter.Emit(logindPath, logindManagerInterface+".PrepareForSleep", false))
+	s.expectEvent("")
+	s.Require().NoError(s.emitter.Emit(logindPath, logindManagerInterface+".PrepareForSleep", true))
+	s.expectEvent(SessionEventSleep)
+}
+
+func (s *sessionTestSuite) Test_02_SessionLock() {
+	s.Require().NoError(s.emitter.Emit("/org/freedesktop/login1/session/_31", logindSessionInterface+".Lock"))
+	s.expectEvent(SessionEventLock)
+}
+
+func (s *sessionTestSuite) Test_03_ScreenSaver() {
+	s.Require().NoError(s.emitter.Emit("/org/gnome/ScreenSaver", "org.gnome.ScreenSaver.ActiveChanged", false))
+	s.expectEvent("")
+	s.Require().NoError(s.emitter.Emit("/org/gnome/ScreenSaver", "org.gnome.ScreenSaver.ActiveChanged", true))
+	s.expectEvent(SessionEventLock)
+	// Unrelated signals are ignored
+	s.Require().NoError(s.emitter.Emit("/org/example", "org.example.Foo.ActiveChanged", true))
+	s.expectEvent("")
+}
+
+func TestSessionWatcher(t *testing.T) {
+	suite.Run(t, new(sessionTestSuite))
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package extension
+
+import "fmt"
+
+// TODO Observe NSWorkspace sleep and screen lock notifications on macOS
+func watchSession(handler func(event string)) (func(), error) {
+	return nil, fmt.Errorf("watching session events is not supported on this platform")
+}
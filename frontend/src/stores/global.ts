@@ -67,17 +67,25 @@ export const useGlobalStore = defineStore('global', {
         version: {},
         options: {},
         _apiToken: '',
+        _linkedVault: null,
     } as {
       vaults: vault[],
       error: error,
       version: appVersion,
       options: appOptions,
       _apiToken: string,
+      _linkedVault: number | null,
     }),
     getters: {
       apiToken: state => {
         if (!state._apiToken) {
-          const preds = window.location.hash.slice(1).split('&').filter(kv => kv.startsWith('token='))
+          const params = window.location.hash.slice(1).split('&')
+          const preds = params.filter(kv => kv.startsWith('token='))
+          // The tray links to a vault with `vault=ID`, it gets selected once vaults are loaded
+          const vaultPreds = params.filter(kv => kv.startsWith('vault='))
+          if (vaultPreds.length > 0) {
+            state._linkedVault = Number(vaultPreds[0].split('=')[1])
+          }
           if (preds.length > 0) {
             state._apiToken = preds[0].split('=')[1]
             window.location.hash = '';
@@ -126,14 +134,15 @@ export const useGlobalStore = defineStore('global', {
           }).then(data => {
             this.vaults = data.items.map((v: vault) => ({
               id: v.id,
-              name: v.path.split('/').pop(),
+              name: v.name || v.path.split('/').pop(),
               path: v.path,
               mountpoint: v.mountpoint,
               autoreveal: v.autoreveal,
               readonly: v.readonly,
               state: v.state,
-              selected: false
+              selected: Number(v.id) === this._linkedVault
             }))
+            this._linkedVault = null
           }).catch(e => {
             this.error = {code: -1, msg: e.message}
           })
@@ -157,7 +166,7 @@ export const useGlobalStore = defineStore('global', {
             const v = data.item
             this.vaults.push({
               id: v.id,
-              name: v.path.split('/').pop(),
+              name: v.name || v.path.split('/').pop(),
               path: v.path,
               mountpoint: v.mountpoint,
               autoreveal: v.autoreveal,
@@ -180,7 +189,7 @@ export const useGlobalStore = defineStore('global', {
             const v = data.item
             this.vaults.push({
               id: v.id,
-              name: v.path.split('/').pop(),
+              name: v.name || v.path.split('/').pop(),
               path: v.path,
               mountpoint: v.mountpoint,
               autoreveal: v.autoreveal,
@@ -265,7 +274,7 @@ export const useGlobalStore = defineStore('global', {
             for (let v of this.vaults) {
               if (v.id === payload.vaultId) {
                 v.path = vault.path
-                v.name = vault.path.split('/').pop()!
+                v.name = vault.name || vault.path.split('/').pop()!
                 v.autoreveal = vault.autoreveal
                 v.readonly = vault.readonly
                 v.mountpoint = vault.mountpoint
@@ -0,0 +1,173 @@
+// Package gocryptfs reads gocryptfs vault configs natively, without running gocryptfs.
+package gocryptfs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Names of gocryptfs config files inside vault directories
+const (
+	ConfDefaultName = "gocryptfs.conf"          // for normal vaults, in the cipher directory
+	ConfReverseName = ".gocryptfs.reverse.conf" // for reverse vaults, in the plaintext directory
+)
+
+// Feature flags found in gocryptfs.conf
+const (
+	FlagPlaintextNames    = "PlaintextNames"
+	FlagDirIV             = "DirIV"
+	FlagEMENames          = "EMENames"
+	FlagGCMIV128          = "GCMIV128"
+	FlagLongNames         = "LongNames"
+	FlagLongNameMax       = "LongNameMax"
+	FlagAESSIV            = "AESSIV"
+	FlagRaw64             = "Raw64"
+	FlagHKDF              = "HKDF"
+	FlagFIDO2             = "FIDO2"
+	FlagXChaCha20Poly1305 = "XChaCha20Poly1305"
+)
+
+// Errors returned when loading configs, wrapped with details
+var (
+	ErrConfMissing    = errors.New("gocryptfs config not found")
+	ErrConfMalformed  = errors.New("gocryptfs config is malformed")
+	ErrConfReverse    = errors.New("found a reverse mode config (" + ConfReverseName + ") instead of " + ConfDefaultName)
+	ErrConfNotReverse = errors.New("found a normal config (" + ConfDefaultName + ") instead of " + ConfReverseName)
+)
+
+// ScryptKDF holds parameters for deriving the key which encrypts the master key from a password.
+type ScryptKDF struct {
+	Salt   []byte // base64 in JSON
+	N      int    // CPU/memory cost, a power of 2
+	R      int    // block size
+	P      int    // parallelization
+	KeyLen int    // length of the derived key
+}
+
+// LogN returns the logarithm of N, which is what `gocryptfs -scryptn` takes.
+func (s ScryptKDF) LogN() int {
+	logN := 0
+	for n := s.N; n > 1; n >>= 1 {
+		logN++
+	}
+	return logN
+}
+
+// Conf mirrors the content of a gocryptfs config file.
+// The master key is kept encrypted as is.
+type Conf struct {
+	Creator      string    // gocryptfs version which created this config
+	EncryptedKey []byte    // master key encrypted with the scrypt-derived key, base64 in JSON
+	ScryptObject ScryptKDF // parameters for deriving the key which encrypts `EncryptedKey`
+	Version      uint16    // config format version, gocryptfs uses 2 since v0.x
+	FeatureFlags []string  // e.g. `HKDF`, `Raw64`
+	LongNameMax  uint8     `json:",omitempty"` // only present with `LongNameMax` feature flag
+}
+
+// IsFeatureFlagSet tells whether given feature flag is set in the config.
+func (c *Conf) IsFeatureFlagSet(flag string) bool {
+	for _, f := range c.FeatureFlags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// validate checks fields which gocryptfs requires.
+func (c *Conf) validate() error {
+	switch {
+	case c.Version == 0:
+		return fmt.Errorf("%w: missing Version", ErrConfMalformed)
+	case len(c.EncryptedKey) == 0:
+		return fmt.Errorf("%w: missing EncryptedKey", ErrConfMalformed)
+	case len(c.ScryptObject.Salt) == 0:
+		return fmt.Errorf("%w: missing ScryptObject.Salt", ErrConfMalformed)
+	case c.ScryptObject.N < 2 || c.ScryptObject.N&(c.ScryptObject.N-1) != 0:
+		return fmt.Errorf("%w: ScryptObject.N %d is not a power of 2", ErrConfMalformed, c.ScryptObject.N)
+	case c.ScryptObject.R <= 0 || c.ScryptObject.P <= 0 || c.ScryptObject.KeyLen <= 0:
+		return fmt.Errorf("%w: ScryptObject.R, P and KeyLen must be positive", ErrConfMalformed)
+	case c.IsFeatureFlagSet(FlagLongNameMax) && c.LongNameMax == 0:
+		return fmt.Errorf("%w: missing LongNameMax", ErrConfMalformed)
+	}
+	return nil
+}
+
+// Load reads and parses the gocryptfs config file at given path.
+func Load(path string) (*Conf, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s", ErrConfMissing, path)
+		}
+		return nil, err
+	}
+	var conf Conf
+	if err := json.Unmarshal(content, &conf); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrConfMalformed, err)
+	}
+	if err := conf.validate(); err != nil {
+		return nil, err
+	}
+	return &conf, nil
+}
+
+// LoadVault loads the config of a vault located at `dir`, `reverse` tells whether it's a reverse vault.
+// If the config is missing while one of the other mode exists, `ErrConfReverse` or `ErrConfNotReverse` is returned.
+func LoadVault(dir string, reverse bool) (*Conf, error) {
+	name, otherName, mismatchErr := ConfDefaultName, ConfReverseName, ErrConfReverse
+	if reverse {
+		name, otherName, mismatchErr = ConfReverseName, ConfDefaultName, ErrConfNotReverse
+	}
+	conf, err := Load(filepath.Join(dir, name))
+	if errors.Is(err, ErrConfMissing) {
+		if _, statErr := os.Stat(filepath.Join(dir, otherName)); statErr == nil {
+			return nil, mismatchErr
+		}
+	}
+	return conf, err
+}
+
+// Identity returns a stable identity of the vault, which survives the vault being moved or renamed.
+// It's derived from the encrypted master key and the scrypt salt, so it changes along with the password.
+func (c *Conf) Identity() string {
+	h := sha256.New()
+	h.Write(c.EncryptedKey)
+	h.Write(c.ScryptObject.Salt)
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// Info is a summary of unencrypted metadata in a gocryptfs config.
+type Info struct {
+	Creator      string   `json:"creator"`
+	Version      uint16   `json:"version"`
+	FeatureFlags []string `json:"featureflags"`
+	ScryptN      int      `json:"scryptn"`
+	ScryptLogN   int      `json:"scryptlogn"`
+	ScryptR      int      `json:"scryptr"`
+	ScryptP      int      `json:"scryptp"`
+	LongNameMax  uint8    `json:"longnamemax,omitempty"`
+}
+
+// Info returns the unencrypted metadata of this config.
+func (c *Conf) Info() Info {
+	flags := c.FeatureFlags
+	if flags == nil {
+		flags = []string{}
+	}
+	return Info{
+		Creator:      c.Creator,
+		Version:      c.Version,
+		FeatureFlags: flags,
+		ScryptN:      c.ScryptObject.N,
+		ScryptLogN:   c.ScryptObject.LogN(),
+		ScryptR:      c.ScryptObject.R,
+		ScryptP:      c.ScryptObject.P,
+		LongNameMax:  c.LongNameMax,
+	}
+}
@@ -0,0 +1,112 @@
+package gocryptfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type confTestSuite struct {
+	suite.Suite
+}
+
+// vaultDir creates a temporary vault directory with given config files in it.
+func (s *confTestSuite) vaultDir(files map[string]string) string {
+	dir := s.T().TempDir()
+	for name, content := range files {
+		s.Require().NoError(os.WriteFile(filepath.Join(dir, name), []byte(content), 0600))
+	}
+	return dir
+}
+
+func (s *confTestSuite) Test_01_Load() {
+	conf, err := Load("./conf_test_sample.conf")
+	s.Require().NoError(err)
+	s.Require().EqualValues("gocryptfs v2.4.0", conf.Creator)
+	s.Require().EqualValues(2, conf.Version)
+	s.Require().Len(conf.EncryptedKey, 64)
+	s.Require().Len(conf.ScryptObject.Salt, 32)
+	s.Require().True(conf.IsFeatureFlagSet(FlagXChaCha20Poly1305))
+	s.Require().False(conf.IsFeatureFlagSet(FlagAESSIV))
+
+	s.Require().Equal(Info{
+		Creator:      "gocryptfs v2.4.0",
+		Version:      2,
+		FeatureFlags: []string{"HKDF", "XChaCha20Poly1305", "DirIV", "EMENames", "LongNames", "LongNameMax", "Raw64"},
+		ScryptN:      65536,
+		ScryptLogN:   16,
+		ScryptR:      8,
+		ScryptP:      1,
+		LongNameMax:  100,
+	}, conf.Info())
+}
+
+func (s *confTestSuite) Test_02_Load_Missing() {
+	_, err := Load("/some/non/existing/path/gocryptfs.conf")
+	s.Require().ErrorIs(err, ErrConfMissing)
+}
+
+func (s *confTestSuite) Test_03_Load_Malformed() {
+	sample, err := os.ReadFile("./conf_test_sample.conf")
+	s.Require().NoError(err)
+
+	for name, content := range map[string]string{
+		"not json":       `{"Creator": "gocryptfs v2.4.0",`,
+		"bad base64":     `{"Version": 2, "EncryptedKey": "not base64!"}`,
+		"no version":     `{"EncryptedKey": "AAAA", "ScryptObject": {"Salt": "AAAA", "N": 1024, "R": 8, "P": 1, "KeyLen": 32}}`,
+		"no key":         `{"Version": 2, "ScryptObject": {"Salt": "AAAA", "N": 1024, "R": 8, "P": 1, "KeyLen": 32}}`,
+		"bad N":          `{"Version": 2, "EncryptedKey": "AAAA", "ScryptObject": {"Salt": "AAAA", "N": 1000, "R": 8, "P": 1, "KeyLen": 32}}`,
+		"no LongNameMax": `{"Version": 2, "EncryptedKey": "AAAA", "ScryptObject": {"Salt": "AAAA", "N": 1024, "R": 8, "P": 1, "KeyLen": 32}, "FeatureFlags": ["LongNameMax"]}`,
+		"empty":          ``,
+	} {
+		dir := s.vaultDir(map[string]string{ConfDefaultName: content})
+		_, err := Load(filepath.Join(dir, ConfDefaultName))
+		s.Require().ErrorIs(err, ErrConfMalformed, name)
+	}
+
+	// The sample is fine
+	dir := s.vaultDir(map[string]string{ConfDefaultName: string(sample)})
+	_, err = Load(filepath.Join(dir, ConfDefaultName))
+	s.Require().NoError(err)
+}
+
+func (s *confTestSuite) Test_04_LoadVault() {
+	sample, err := os.ReadFile("./conf_test_sample.conf")
+	s.Require().NoError(err)
+
+	dir := s.vaultDir(map[string]string{ConfDefaultName: string(sample)})
+	_, err = LoadVault(dir, false)
+	s.Require().NoError(err)
+	_, err = LoadVault(dir, true)
+	s.Require().ErrorIs(err, ErrConfNotReverse)
+
+	dir = s.vaultDir(map[string]string{ConfReverseName: string(sample)})
+	_, err = LoadVault(dir, true)
+	s.Require().NoError(err)
+	_, err = LoadVault(dir, false)
+	s.Require().ErrorIs(err, ErrConfReverse)
+
+	dir = s.vaultDir(nil)
+	_, err = LoadVault(dir, false)
+	s.Require().ErrorIs(err, ErrConfMissing)
+}
+
+func (s *confTestSuite) Test_05_Identity() {
+	conf, err := Load("./conf_test_sample.conf")
+	s.Require().NoError(err)
+	identity := conf.Identity()
+	s.Require().Len(identity, 32)
+
+	// Stable across loads, but changes along with the wrapped master key
+	again, err := Load("./conf_test_sample.conf")
+	s.Require().NoError(err)
+	s.Require().Equal(identity, again.Identity())
+	again.ScryptObject.Salt[0] ^= 0xff
+	s.Require().NotEqual(identity, again.Identity())
+}
+
+func Test_Conf(t *testing.T) {
+	suite.Run(t, new(confTestSuite))
+}
@@ -0,0 +1,58 @@
+package gocryptfs
+
+import (
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ExitCodeFsckErrors is the exit code of `gocryptfs -fsck` when problems were found.
+const ExitCodeFsckErrors = 26
+
+// fsckSummaryPrefix starts the last line printed by `gocryptfs -fsck`.
+const fsckSummaryPrefix = "fsck summary:"
+
+// Problems reported by `gocryptfs -fsck`, one per line. Paths are Go-quoted and relative to the vault root.
+// The submatch names where the corrupt path is, `entry` is relative to `dir`.
+var fsckProblemPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^fsck: corrupt entry in dir (?P<dir>"(?:[^"\\]|\\.)*"): (?P<entry>"(?:[^"\\]|\\.)*")`),
+	regexp.MustCompile(`^fsck: error reading xattr "(?:[^"\\]|\\.)*" from (?P<path>"(?:[^"\\]|\\.)*")`),
+	regexp.MustCompile(`^fsck: .*? (?:on|of) (?P<path>"(?:[^"\\]|\\.)*")`),
+	regexp.MustCompile(`^fsck: .*? (?P<path>"(?:[^"\\]|\\.)*")`),
+}
+
+// FsckLine is a parsed line of `gocryptfs -fsck` output.
+type FsckLine struct {
+	Corrupt string // path of the corrupt file or directory, empty if the line reports no problem
+	Summary string // the final summary, empty if the line is not
+}
+
+// ParseFsckLine parses a single line of `gocryptfs -fsck` output.
+func ParseFsckLine(line string) FsckLine {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, fsckSummaryPrefix) {
+		return FsckLine{Summary: strings.TrimSpace(strings.TrimPrefix(line, fsckSummaryPrefix))}
+	}
+	for _, pattern := range fsckProblemPatterns {
+		match := pattern.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		var dir, entry string
+		for i, name := range pattern.SubexpNames() {
+			value, err := strconv.Unquote(match[i])
+			if err != nil {
+				continue
+			}
+			switch name {
+			case "dir":
+				dir = value
+			case "entry", "path":
+				entry = value
+			}
+		}
+		return FsckLine{Corrupt: path.Join("/", dir, entry)}
+	}
+	return FsckLine{}
+}
@@ -0,0 +1,47 @@
+package gocryptfs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type fsckTestSuite struct {
+	suite.Suite
+}
+
+func (s *fsckTestSuite) Test_01_Corrupt() {
+	for line, expected := range map[string]string{
+		`fsck: error reading file "docs/a.txt" (inum 1234): 5=input/output error`:   "/docs/a.txt",
+		`fsck: error opening file "b \"quoted\".txt": permission denied`:            `/b "quoted".txt`,
+		`fsck: error reading dir "photos": 5=input/output error`:                    "/photos",
+		`fsck: corrupt entry in dir "photos/2020": "gocryptfs.longname.XXX"`:        "/photos/2020/gocryptfs.longname.XXX",
+		`fsck: corrupt entry in dir "": "bad"`:                                      "/bad",
+		`fsck: error reading symlink "link": input/output error`:                    "/link",
+		`fsck: error listing xattrs on "c.txt": operation not supported`:            "/c.txt",
+		`fsck: corrupt xattr name on "d.txt": "user.xxx"`:                           "/d.txt",
+		`fsck: error reading xattr "user.comment" from "e.txt": input/output error`: "/e.txt",
+	} {
+		parsed := ParseFsckLine(line)
+		s.Require().EqualValues(expected, parsed.Corrupt, line)
+		s.Require().Empty(parsed.Summary, line)
+	}
+}
+
+func (s *fsckTestSuite) Test_02_Other() {
+	for _, line := range []string{
+		"",
+		"Password: ",
+		"Decrypting master key",
+		"fsck: found 0 files",
+	} {
+		s.Require().EqualValues(FsckLine{}, ParseFsckLine(line), line)
+	}
+
+	s.Require().EqualValues(FsckLine{Summary: "no problems found"}, ParseFsckLine("fsck summary: no problems found\n"))
+	s.Require().EqualValues(FsckLine{Summary: "2 corrupt files"}, ParseFsckLine("fsck summary: 2 corrupt files"))
+}
+
+func Test_Fsck(t *testing.T) {
+	suite.Run(t, new(fsckTestSuite))
+}
@@ -0,0 +1,62 @@
+package gocryptfs
+
+import (
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Range of scrypt cost (as logarithm of N) accepted by gocryptfs `-scryptn`
+const (
+	ScryptMinLogN     = 10
+	ScryptMaxLogN     = 28
+	ScryptDefaultLogN = 16
+)
+
+// Parameters gocryptfs always uses besides N
+const (
+	scryptR      = 8
+	scryptP      = 1
+	scryptKeyLen = 32
+)
+
+// BenchmarkScrypt times a single scrypt derivation with N = 2^logN, using the parameters gocryptfs uses.
+func BenchmarkScrypt(logN int) (time.Duration, error) {
+	salt := make([]byte, scryptKeyLen)
+	start := time.Now()
+	if _, err := scrypt.Key([]byte("benchmark"), salt, 1<<logN, scryptR, scryptP, scryptKeyLen); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
+
+// SuggestScryptLogN suggests the largest scrypt cost whose derivation stays within `target`,
+// given that one derivation with N = 2^logN took `measured`.
+// Time grows linearly with N, so each step up doubles it. Memory grows the same way,
+// the derivation never needs more than `maxMemory` bytes unless it's 0.
+// The result is clamped to what gocryptfs accepts.
+func SuggestScryptLogN(target time.Duration, logN int, measured time.Duration, maxMemory int64) int {
+	suggested := logN
+	if measured <= 0 {
+		suggested = ScryptMaxLogN
+	} else {
+		for ; measured*2 <= target && suggested < ScryptMaxLogN; suggested++ {
+			measured *= 2
+		}
+		for ; measured > target && suggested > ScryptMinLogN; suggested-- {
+			measured /= 2
+		}
+	}
+	for maxMemory > 0 && ScryptMemory(suggested) > maxMemory && suggested > ScryptMinLogN {
+		suggested--
+	}
+	if suggested < ScryptMinLogN {
+		return ScryptMinLogN
+	}
+	return suggested
+}
+
+// ScryptMemory returns how much memory a scrypt derivation with N = 2^logN needs, in bytes.
+func ScryptMemory(logN int) int64 {
+	return 128 * scryptR * (int64(1) << logN)
+}
@@ -0,0 +1,49 @@
+package gocryptfs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type kdfTestSuite struct {
+	suite.Suite
+}
+
+func (s *kdfTestSuite) Test_01_Suggest() {
+	ms := time.Millisecond
+	for _, c := range []struct {
+		target    time.Duration
+		logN      int
+		measured  time.Duration
+		maxMemory int64
+		expected  int
+	}{
+		{target: 1000 * ms, logN: 14, measured: 50 * ms, expected: 18},                     // 50 -> 800ms
+		{target: 800 * ms, logN: 14, measured: 50 * ms, expected: 18},                      // exactly on target
+		{target: 100 * ms, logN: 16, measured: 200 * ms, expected: 15},                     // scale down
+		{target: 10 * ms, logN: 12, measured: 100 * ms, expected: 10},                      // never below the minimum
+		{target: time.Hour, logN: 14, measured: 1 * ms, expected: 28},                      // never above the maximum
+		{target: 1000 * ms, logN: 14, measured: 0, expected: 28},                           // too fast to measure
+		{target: 1000 * ms, logN: 14, measured: 1000 * ms, expected: 14},                   // already on target
+		{target: time.Hour, logN: 14, measured: 1 * ms, maxMemory: 4 << 30, expected: 22},  // 4GiB fits N = 2^22
+		{target: 1000 * ms, logN: 14, measured: 0, maxMemory: 64 << 20, expected: 16},      // too fast, capped by memory
+		{target: 1000 * ms, logN: 14, measured: 50 * ms, maxMemory: 1 << 40, expected: 18}, // plenty of memory
+		{target: 1000 * ms, logN: 14, measured: 50 * ms, maxMemory: 1 << 10, expected: 10}, // never below the minimum
+	} {
+		s.Require().EqualValues(c.expected, SuggestScryptLogN(c.target, c.logN, c.measured, c.maxMemory), "%+v", c)
+	}
+}
+
+func (s *kdfTestSuite) Test_02_Benchmark() {
+	duration, err := BenchmarkScrypt(ScryptMinLogN)
+	s.Require().NoError(err)
+	s.Require().Greater(duration, time.Duration(0))
+	s.Require().EqualValues(1<<20, ScryptMemory(ScryptMinLogN))
+	s.Require().EqualValues(64<<20, ScryptMemory(ScryptDefaultLogN))
+}
+
+func Test_KDF(t *testing.T) {
+	suite.Run(t, new(kdfTestSuite))
+}
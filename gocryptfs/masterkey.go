@@ -0,0 +1,94 @@
+package gocryptfs
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Errors returned when decrypting master keys
+var (
+	ErrWrongPassword   = errors.New("password incorrect")
+	ErrUnsupportedConf = errors.New("gocryptfs config uses an unsupported feature")
+)
+
+const (
+	// gocryptfs encrypts the master key like a file content block using GCM,
+	// with 128-bit nonces since v1.3 (along with the `HKDF` feature flag) and 96-bit nonces before
+	keyNonceSize       = 16
+	keyNonceSizeLegacy = 12
+	// HKDF info string gocryptfs uses for deriving the GCM key, when the `HKDF` feature flag is set
+	hkdfInfoGCMContent = "AES-GCM file content encryption"
+)
+
+// DecryptMasterKey derives a key from `password` using scrypt, and decrypts the master key with it.
+// `ErrWrongPassword` is returned if the password is incorrect.
+func (c *Conf) DecryptMasterKey(password string) ([]byte, error) {
+	if c.IsFeatureFlagSet(FlagFIDO2) {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedConf, FlagFIDO2)
+	}
+
+	kdf := c.ScryptObject
+	scryptHash, err := scrypt.Key([]byte(password), kdf.Salt, kdf.N, kdf.R, kdf.P, kdf.KeyLen)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrConfMalformed, err)
+	}
+	defer wipe(scryptHash)
+
+	gcm, err := c.keyCipher(scryptHash)
+	if err != nil {
+		return nil, err
+	}
+
+	// The encrypted key is laid out as: nonce | ciphertext | tag
+	nonceSize := gcm.NonceSize()
+	if len(c.EncryptedKey) < nonceSize+gcm.Overhead() {
+		return nil, fmt.Errorf("%w: EncryptedKey is too short", ErrConfMalformed)
+	}
+	nonce := c.EncryptedKey[:nonceSize]
+	ciphertext := c.EncryptedKey[nonceSize:]
+	// Additional data is the block number (0) in big endian, followed by an empty file ID
+	aData := make([]byte, 8)
+	masterKey, err := gcm.Open(nil, nonce, ciphertext, aData)
+	if err != nil {
+		return nil, ErrWrongPassword
+	}
+	return masterKey, nil
+}
+
+// keyCipher creates the AEAD cipher which encrypts the master key.
+func (c *Conf) keyCipher(scryptHash []byte) (cipher.AEAD, error) {
+	key, nonceSize := scryptHash, keyNonceSizeLegacy
+	if c.IsFeatureFlagSet(FlagHKDF) {
+		nonceSize = keyNonceSize
+		key = make([]byte, len(scryptHash))
+		defer wipe(key)
+		if _, err := io.ReadFull(hkdf.New(sha256.New, scryptHash, nil, []byte(hkdfInfoGCMContent)), key); err != nil {
+			return nil, err
+		}
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrConfMalformed, err)
+	}
+	return cipher.NewGCMWithNonceSize(block, nonceSize)
+}
+
+// FormatMasterKey formats a master key as hex, which is accepted by `gocryptfs -masterkey`.
+func FormatMasterKey(masterKey []byte) string {
+	return hex.EncodeToString(masterKey)
+}
+
+// wipe overwrites sensitive data with zeros.
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,58 @@
+package gocryptfs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type masterKeyTestSuite struct {
+	suite.Suite
+}
+
+func (s *masterKeyTestSuite) Test_01_Decrypt() {
+	for confPath, expected := range map[string]string{
+		// Created by `gocryptfs -init -scryptn 10` of gocryptfs v2.4.0, HKDF-derived key with 128-bit nonce.
+		// The key was dumped by `gocryptfs-xray -dumpmasterkey` of the same version.
+		"./conf_test_masterkey.conf": "c435157858e9321bfd4730821bb13126e3fc02db0538e83f5056253ba0643b76",
+		// Created by gocryptfs v1.1 (the `v1.1-aessiv` example filesystem of gocryptfs tests),
+		// plain scrypt-derived key with 96-bit nonce. The key is the one gocryptfs tests mount it with.
+		"./conf_test_masterkey_legacy.conf": "eaf371c3f9a553368819f22b7bccd7c2a738cf617261c65814c28a039428992b",
+	} {
+		conf, err := Load(confPath)
+		s.Require().NoError(err)
+		masterKey, err := conf.DecryptMasterKey("test")
+		s.Require().NoError(err, confPath)
+		s.Require().EqualValues(expected, FormatMasterKey(masterKey), confPath)
+	}
+}
+
+func (s *masterKeyTestSuite) Test_02_Decrypt_WrongPassword() {
+	conf, err := Load("./conf_test_masterkey.conf")
+	s.Require().NoError(err)
+	_, err = conf.DecryptMasterKey("wrong")
+	s.Require().ErrorIs(err, ErrWrongPassword)
+
+	// The HKDF flag decides how the key gets derived, so flipping it must fail as well
+	conf.FeatureFlags = []string{FlagGCMIV128, FlagDirIV, FlagEMENames, FlagLongNames, FlagRaw64}
+	_, err = conf.DecryptMasterKey("test")
+	s.Require().ErrorIs(err, ErrWrongPassword)
+}
+
+func (s *masterKeyTestSuite) Test_03_Decrypt_Unsupported() {
+	conf, err := Load("./conf_test_masterkey.conf")
+	s.Require().NoError(err)
+	conf.FeatureFlags = append(conf.FeatureFlags, FlagFIDO2)
+	_, err = conf.DecryptMasterKey("test")
+	s.Require().ErrorIs(err, ErrUnsupportedConf)
+
+	conf, err = Load("./conf_test_masterkey.conf")
+	s.Require().NoError(err)
+	conf.EncryptedKey = conf.EncryptedKey[:20]
+	_, err = conf.DecryptMasterKey("test")
+	s.Require().ErrorIs(err, ErrConfMalformed)
+}
+
+func Test_MasterKey(t *testing.T) {
+	suite.Run(t, new(masterKeyTestSuite))
+}
@@ -0,0 +1,96 @@
+// Package importer reads vaults saved by other gocryptfs front-ends, so they can be added to Cloak.
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/adrg/xdg"
+)
+
+// Front-ends vaults can be imported from
+const (
+	SourceSiriKali = "sirikali" // SiriKali, https://mhogomchungu.github.io/sirikali/
+	SourceVaults   = "vaults"   // GNOME "Vaults" app, https://github.com/mpobaschnig/Vaults
+)
+
+// Definition represents a vault saved by another front-end.
+// Only settings Cloak has a counterpart of are kept.
+type Definition struct {
+	Source      string `json:"source"`
+	Name        string `json:"name,omitempty"`
+	CipherDir   string `json:"cipherdir"`            // the vault directory, the plaintext directory for reverse vaults
+	MountPoint  string `json:"mountpoint,omitempty"` // empty to pick one when unlocking
+	ReadOnly    bool   `json:"readonly"`
+	AutoOpen    bool   `json:"autoopen"`              // open the mountpoint in file manager after unlocking
+	Reverse     bool   `json:"reverse"`               // gocryptfs reverse mode
+	IdleTimeout int64  `json:"idletimeout,omitempty"` // seconds
+	LockSession *bool  `json:"locksession,omitempty"` // lock when the session gets locked, nil if the front-end doesn't tell
+	Backend     string `json:"backend,omitempty"`     // e.g. `gocryptfs` or `cryfs`, empty if the front-end doesn't tell
+	ConfigFile  string `json:"configfile,omitempty"`  // config file outside of the vault directory, if any
+}
+
+// Load reads vault definitions of given front-end from its standard locations.
+// No definitions are returned if the front-end was never used.
+func Load(source string) ([]Definition, error) {
+	switch source {
+	case SourceSiriKali:
+		return LoadSiriKali(locate(
+			filepath.Join(xdg.ConfigHome, "SiriKali"),
+			filepath.Join(xdg.Home, ".var", "app", "io.github.mhogomchungu.sirikali", "config", "SiriKali"),
+		))
+	case SourceVaults:
+		return LoadVaults(locate(
+			filepath.Join(xdg.ConfigHome, "Vaults"),
+			filepath.Join(xdg.Home, ".var", "app", "io.github.mpobaschnig.Vaults", "config", "Vaults"),
+		))
+	default:
+		return nil, fmt.Errorf("unknown source %q", source)
+	}
+}
+
+// locate returns the first existing directory of given ones, native installations go before Flatpak ones.
+func locate(dirs ...string) string {
+	for _, dir := range dirs {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir
+		}
+	}
+	return dirs[0]
+}
+
+// flexBool is a boolean which might be saved as a string, e.g. `"true"`.
+type flexBool bool
+
+func (b *flexBool) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case bool:
+		*b = flexBool(v)
+	case string:
+		parsed, _ := strconv.ParseBool(strings.TrimSpace(v))
+		*b = flexBool(parsed)
+	default:
+		*b = false
+	}
+	return nil
+}
+
+// expandHome replaces a leading `~` with the home directory, and cleans the path.
+func expandHome(path string) string {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return ""
+	}
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		path = filepath.Join(xdg.Home, path[1:])
+	}
+	return filepath.Clean(path)
+}
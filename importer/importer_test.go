@@ -0,0 +1,126 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type importerTestSuite struct {
+	suite.Suite
+}
+
+// siriKaliDir creates a temporary SiriKali config directory with the sample favourite and settings.
+func (s *importerTestSuite) siriKaliDir(extraFavorites map[string]string) string {
+	dir := s.T().TempDir()
+	s.Require().NoError(os.Mkdir(filepath.Join(dir, "favorites"), 0700))
+
+	favorite, err := os.ReadFile("./sirikali_test_sample.json")
+	s.Require().NoError(err)
+	s.Require().NoError(os.WriteFile(filepath.Join(dir, "favorites", "a.json"), favorite, 0600))
+	for name, content := range extraFavorites {
+		s.Require().NoError(os.WriteFile(filepath.Join(dir, "favorites", name), []byte(content), 0600))
+	}
+
+	settings, err := os.ReadFile("./sirikali_test_sample.conf")
+	s.Require().NoError(err)
+	s.Require().NoError(os.WriteFile(filepath.Join(dir, "SiriKali.conf"), settings, 0600))
+	return dir
+}
+
+func (s *importerTestSuite) Test_01_SiriKali() {
+	dir := s.siriKaliDir(map[string]string{
+		"b.json": `{"volumePath": "/home/alice/Backup", "reverseMode": "true", "readOnlyMode": "false", "configFilePath": "/home/alice/backup.conf", "idleTimeOut": ""}`,
+		"c.json": `{"volumePath": ""}`,
+	})
+	definitions, err := LoadSiriKali(dir)
+	s.Require().NoError(err)
+	s.Require().Equal([]Definition{
+		{
+			Source:      SourceSiriKali,
+			CipherDir:   "/home/alice/Encrypted/Documents",
+			MountPoint:  "/home/alice/.SiriKali/Documents",
+			ReadOnly:    true,
+			AutoOpen:    true,
+			IdleTimeout: 15 * 60,
+		},
+		{
+			Source:     SourceSiriKali,
+			CipherDir:  "/home/alice/Backup",
+			AutoOpen:   true,
+			Reverse:    true,
+			ConfigFile: "/home/alice/backup.conf",
+		},
+	}, definitions)
+}
+
+func (s *importerTestSuite) Test_02_SiriKali_Malformed() {
+	_, err := LoadSiriKali(s.siriKaliDir(map[string]string{"b.json": `{"volumePath": `}))
+	s.Require().Error(err)
+
+	// Never used
+	definitions, err := LoadSiriKali(filepath.Join(s.T().TempDir(), "SiriKali"))
+	s.Require().NoError(err)
+	s.Require().Empty(definitions)
+}
+
+// The sample is serialized by the config struct of the "Vaults" app, with toml 0.8 `to_string_pretty` as the app does.
+// Photos lacks `session_lock` & custom binary options, like vaults saved by earlier versions of the app.
+func (s *importerTestSuite) Test_03_Vaults() {
+	dir := s.T().TempDir()
+	sample, err := os.ReadFile("./vaults_test_sample.toml")
+	s.Require().NoError(err)
+	s.Require().NoError(os.WriteFile(filepath.Join(dir, "user_config.toml"), sample, 0600))
+
+	definitions, err := LoadVaults(dir)
+	s.Require().NoError(err)
+	locked, unlocked := true, false
+	s.Require().Equal([]Definition{
+		{
+			Source:     SourceVaults,
+			Name:       "Photos",
+			CipherDir:  "/home/alice/Vaults/Photos_encrypted",
+			MountPoint: "/home/alice/Vaults/Photos",
+			Backend:    "cryfs",
+		},
+		{
+			Source:      SourceVaults,
+			Name:        `Tax "2025"`,
+			CipherDir:   "/home/alice/Vaults/Tax_encrypted",
+			MountPoint:  "/home/alice/Vaults/Tax",
+			LockSession: &unlocked,
+			Backend:     "gocryptfs",
+		},
+		{
+			Source:      SourceVaults,
+			Name:        "Work",
+			CipherDir:   "/home/alice/Vaults/Work_encrypted",
+			MountPoint:  "/home/alice/Vaults/Work",
+			LockSession: &locked,
+			Backend:     "gocryptfs",
+		},
+	}, definitions)
+
+	// Never used
+	definitions, err = LoadVaults(s.T().TempDir())
+	s.Require().NoError(err)
+	s.Require().Empty(definitions)
+}
+
+func (s *importerTestSuite) Test_04_Vaults_Malformed() {
+	for _, sample := range []string{
+		"[Work\nbackend = \"Gocryptfs\"",
+		"[Work]\nbackend \"Gocryptfs\"",
+		"[Work]\nbackend = \"Gocryptfs",
+		"[[Work]]",
+	} {
+		_, err := ParseVaultsConfig([]byte(sample))
+		s.Require().Error(err, sample)
+	}
+}
+
+func Test_Importer(t *testing.T) {
+	suite.Run(t, new(importerTestSuite))
+}
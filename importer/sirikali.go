@@ -0,0 +1,77 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// siriKaliFavorite mirrors a favourite saved by SiriKali, one JSON file each in its `favorites` directory.
+type siriKaliFavorite struct {
+	VolumePath     string   `json:"volumePath"`
+	MountPointPath string   `json:"mountPointPath"`
+	ConfigFilePath string   `json:"configFilePath"`
+	IdleTimeOut    string   `json:"idleTimeOut"` // minutes
+	ReverseMode    flexBool `json:"reverseMode"`
+	ReadOnlyMode   flexBool `json:"readOnlyMode"`
+}
+
+// LoadSiriKali reads favourites from given SiriKali config directory, e.g. `~/.config/SiriKali`.
+// Whether mountpoints are opened after unlocking is a global setting of SiriKali, which applies to all favourites.
+// SiriKali supports several backends, but favourites don't tell which one a vault uses.
+func LoadSiriKali(configDir string) ([]Definition, error) {
+	paths, err := filepath.Glob(filepath.Join(configDir, "favorites", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	autoOpen := false
+	if settings, err := ini.Load(filepath.Join(configDir, "SiriKali.conf")); err == nil {
+		autoOpen, _ = settings.Section("General").Key("AutoOpenFolderOnMount").Bool()
+	}
+
+	var definitions []Definition
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		definition, err := ParseSiriKaliFavorite(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if definition.CipherDir == "" {
+			continue
+		}
+		definition.AutoOpen = autoOpen
+		definitions = append(definitions, definition)
+	}
+	return definitions, nil
+}
+
+// ParseSiriKaliFavorite parses a single SiriKali favourite.
+func ParseSiriKaliFavorite(data []byte) (Definition, error) {
+	var favorite siriKaliFavorite
+	if err := json.Unmarshal(data, &favorite); err != nil {
+		return Definition{}, err
+	}
+	definition := Definition{
+		Source:     SourceSiriKali,
+		CipherDir:  expandHome(favorite.VolumePath),
+		MountPoint: expandHome(favorite.MountPointPath),
+		ReadOnly:   bool(favorite.ReadOnlyMode),
+		Reverse:    bool(favorite.ReverseMode),
+		ConfigFile: expandHome(favorite.ConfigFilePath),
+	}
+	if minutes, err := strconv.ParseInt(strings.TrimSpace(favorite.IdleTimeOut), 10, 64); err == nil && minutes > 0 {
+		definition.IdleTimeout = minutes * 60
+	}
+	return definition, nil
+}
@@ -0,0 +1,69 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// LoadVaults reads vaults from given config directory of the GNOME "Vaults" app, e.g. `~/.config/Vaults`.
+func LoadVaults(configDir string) ([]Definition, error) {
+	data, err := os.ReadFile(filepath.Join(configDir, "user_config.toml"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return ParseVaultsConfig(data)
+}
+
+// vaultsConfig mirrors a vault saved by the "Vaults" app, one table each in its `user_config.toml`.
+// Vaults saved by earlier versions of the app lack `session_lock`.
+type vaultsConfig struct {
+	Backend                string `toml:"backend"` // "Gocryptfs" or "Cryfs"
+	EncryptedDataDirectory string `toml:"encrypted_data_directory"`
+	MountDirectory         string `toml:"mount_directory"`
+	SessionLock            *bool  `toml:"session_lock"`
+}
+
+// ParseVaultsConfig parses `user_config.toml` of the "Vaults" app, which has a table for each vault named after it:
+//
+//	[Work]
+//	backend = "Gocryptfs"
+//	encrypted_data_directory = "/home/me/Vaults/Work_encrypted"
+//	mount_directory = "/home/me/Vaults/Work"
+//	session_lock = true
+//
+// Vaults are returned ordered by name.
+func ParseVaultsConfig(data []byte) ([]Definition, error) {
+	var vaults map[string]vaultsConfig
+	if _, err := toml.Decode(string(data), &vaults); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(vaults))
+	for name := range vaults {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var definitions []Definition
+	for _, name := range names {
+		vault := vaults[name]
+		if strings.TrimSpace(vault.EncryptedDataDirectory) == "" {
+			continue
+		}
+		definitions = append(definitions, Definition{
+			Source:      SourceVaults,
+			Name:        name,
+			CipherDir:   expandHome(vault.EncryptedDataDirectory),
+			MountPoint:  expandHome(vault.MountDirectory),
+			Backend:     strings.ToLower(vault.Backend),
+			LockSession: vault.SessionLock,
+		})
+	}
+	return definitions, nil
+}
@@ -0,0 +1,97 @@
+package instance
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/adrg/xdg"
+)
+
+// Info describes a running Cloak app instance, so local clients (e.g. `cloakctl`) can reach its API.
+// It contains the API access token, so the file is only readable by current user.
+type Info struct {
+	Address string `json:"address"` // host:port the API server listens on
+	Token   string `json:"token"`   // Bearer token for API access
+	PID     int    `json:"pid"`     // process ID of the app
+}
+
+// FilePath returns the path of the instance file.
+// It prefers XDG runtime directory, and falls back to XDG data directory if the former does not exist.
+func FilePath() string {
+	baseDir := xdg.RuntimeDir
+	if info, err := os.Stat(baseDir); err != nil || !info.IsDir() {
+		baseDir = xdg.DataHome
+	}
+	return filepath.Join(baseDir, "Cloak", "instance.json")
+}
+
+// Write persists given instance info, replacing any existing one.
+func Write(info Info) error {
+	path := FilePath()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first so readers never see a partially written file
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// Read loads info of the running instance.
+// An error is returned if no instance file exists, or the recorded process is gone.
+func Read() (info Info, err error) {
+	var data []byte
+	if data, err = os.ReadFile(FilePath()); err != nil {
+		return
+	}
+	if err = json.Unmarshal(data, &info); err != nil {
+		return
+	}
+	if info.Address == "" || info.Token == "" {
+		err = fmt.Errorf("malformed instance file %s", FilePath())
+		return
+	}
+	if !isProcessAlive(info.PID) {
+		err = fmt.Errorf("recorded Cloak process %d is not running", info.PID)
+	}
+	return
+}
+
+// Remove deletes the instance file if it still belongs to current process.
+func Remove() error {
+	data, err := os.ReadFile(FilePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var info Info
+	if err := json.Unmarshal(data, &info); err == nil && info.PID != os.Getpid() {
+		return nil
+	}
+	return os.Remove(FilePath())
+}
+
+// isProcessAlive checks whether a process with given PID exists.
+func isProcessAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	// Signal 0 performs error checking only, nothing is actually sent
+	err = proc.Signal(syscall.Signal(0))
+	return err == nil || err == syscall.EPERM
+}
@@ -96,6 +96,12 @@ func buildForTarget(c context.Context) (output string, err error) {
 		return
 	}
 
+	// Command line client
+	ctlExecutable := "cloakctl"
+	if err = sh.RunWith(env, `go`, `build`, `-o`, ctlExecutable, `./cmd/cloakctl`); err != nil {
+		return
+	}
+
 	var executableDir string
 	switch env["GOOS"] {
 	case "darwin":
@@ -110,11 +116,11 @@ func buildForTarget(c context.Context) (output string, err error) {
 
 		// Here's a list of files to be bundled
 		files := map[string]string{
-			executable:       filepath.Join(executableDir, executable),
-			"Info.plist":     filepath.Join(executableDir, `..`, `Info.plist`),
-			"gocryptfs":      filepath.Join(executableDir, "gocryptfs"),
-			"gocryptfs-xray": filepath.Join(executableDir, "gocryptfs-xray"),
-			"Cloak.icns":     filepath.Join(resourceDir, "Cloak.icns"),
+			executable:    filepath.Join(executableDir, executable),
+			ctlExecutable: filepath.Join(executableDir, ctlExecutable),
+			"Info.plist":  filepath.Join(executableDir, `..`, `Info.plist`),
+			"gocryptfs":   filepath.Join(executableDir, "gocryptfs"),
+			"Cloak.icns":  filepath.Join(resourceDir, "Cloak.icns"),
 		}
 		for filename := range files {
 			if err := sh.Copy(files[filename], filename); err != nil {
@@ -136,8 +142,8 @@ func buildForTarget(c context.Context) (output string, err error) {
 		}
 
 		files := map[string]string{
-			"gocryptfs":      filepath.Join(executableDir, "gocryptfs"),
-			"gocryptfs-xray": filepath.Join(executableDir, "gocryptfs-xray"),
+			ctlExecutable: filepath.Join(executableDir, ctlExecutable),
+			"gocryptfs":   filepath.Join(executableDir, "gocryptfs"),
 		}
 		for filename := range files {
 			if err := sh.Copy(files[filename], filename); err != nil {
@@ -301,6 +307,7 @@ func Clean(c context.Context) error {
 	default:
 		return fmt.Errorf("Unsupported OS: %s", goOs)
 	}
+	sh.Rm("cloakctl")
 	sh.Rm("gocryptfs")
 	sh.Rm("gocryptfs-xray")
 	return nil
@@ -348,8 +355,7 @@ func DownloadExternalTools(c context.Context) error {
 
 	// Here's a list of external tools to be downloaded, they are going to be bundled
 	tools := map[string]string{
-		"gocryptfs":      "https://github.com/Cloaklet/resources/releases/download/%s/gocryptfs-%s-%s-%s",
-		"gocryptfs-xray": "https://github.com/Cloaklet/resources/releases/download/%s/gocryptfs-xray-%s-%s-%s",
+		"gocryptfs": "https://github.com/Cloaklet/resources/releases/download/%s/gocryptfs-%s-%s-%s",
 	}
 	switch goOs {
 	case "darwin", "linux":
@@ -371,7 +377,7 @@ func DownloadExternalTools(c context.Context) error {
 	}
 }
 
-// PackErrorsIntoLocales [for DEVs] inject all missing error codes into UI locales.
+// PackErrorsIntoLocales [for DEVs] inject all missing error codes into UI & app locales.
 func PackErrorsIntoLocales() error {
 	errors := []*server.ApiError{
 		server.ErrOk,
@@ -395,6 +401,30 @@ func PackErrorsIntoLocales() error {
 		server.ErrVaultUpdateConfFailed,
 		server.ErrMissingGocryptfsXrayBinary,
 		server.ErrMountpointMkdirFailed,
+		server.ErrVaultBusy,
+		server.ErrInvalidCreateOptions,
+		server.ErrUnsupportedFeature,
+		server.ErrMalformedVaultConf,
+		server.ErrVaultConfMismatch,
+		server.ErrInvalidScryptN,
+		server.ErrPasswordNotStored,
+		server.ErrKeyringUnavailable,
+		server.ErrFsckRunning,
+		server.ErrFsckNotRunning,
+		server.ErrJobNotExist,
+		server.ErrGroupNotExist,
+		server.ErrGroupNameTaken,
+		server.ErrInvalidMountOptions,
+		server.ErrVaultMissing,
+		server.ErrVaultNotFound,
+		server.ErrVaultAmbiguous,
+		server.ErrInvalidPathVariable,
+		server.ErrInvalidImport,
+		server.ErrBackendUnavailable,
+		server.ErrUnknownBackend,
+		server.ErrUnsupportedByBackend,
+		server.ErrVaultOperationRunning,
+		server.ErrCantUnmount,
 	}
 	localesDir := filepath.Join("frontend", "src", "locales")
 	localeFiles, err := ioutil.ReadDir(localesDir)
@@ -404,23 +434,52 @@ func PackErrorsIntoLocales() error {
 
 	for _, file := range localeFiles {
 		logger.Debug().Str("file", file.Name()).Msg("Processing locale file")
-		jsonBytes, err := ioutil.ReadFile(filepath.Join(localesDir, file.Name()))
+		err := packErrorsIntoLocaleFile(filepath.Join(localesDir, file.Name()), errors, func(string) map[string]bool {
+			return map[string]bool{"": file.Name() == "en.json"}
+		})
 		if err != nil {
 			return err
 		}
-		json := string(jsonBytes)
+	}
+
+	// Locales of the app itself, e.g. for notifications, hold all languages in one file
+	logger.Debug().Msg("Processing app locales")
+	return packErrorsIntoLocaleFile(filepath.Join("i18n", "locales.json"), errors, func(json string) map[string]bool {
+		prefixes := make(map[string]bool)
+		gjson.Parse(json).ForEach(func(key, _ gjson.Result) bool {
+			prefixes[key.String()+"."] = key.String() == "en"
+			return true
+		})
+		return prefixes
+	})
+}
 
-		if errorSubKey := gjson.Get(json, "errors"); !errorSubKey.Exists() {
+// packErrorsIntoLocaleFile injects missing error codes into given locale file, under `errors` of each prefix.
+// `prefixes` tells the key prefixes of locales in the file, and whether each locale is English,
+// which gets error messages as is. Others get empty strings to be translated.
+func packErrorsIntoLocaleFile(path string, errors []*server.ApiError, prefixes func(json string) map[string]bool) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	jsonBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	json := string(jsonBytes)
+
+	for prefix, english := range prefixes(json) {
+		if errorSubKey := gjson.Get(json, prefix+"errors"); !errorSubKey.Exists() {
 			// Initialize .errors object
-			if json, err = sjson.Set(json, "errors", map[string]string{}); err != nil {
+			if json, err = sjson.Set(json, prefix+"errors", map[string]string{}); err != nil {
 				return err
 			}
 		}
 		for _, error := range errors {
-			errorKey := fmt.Sprintf("errors.api_%d", error.Code)
+			errorKey := fmt.Sprintf("%serrors.api_%d", prefix, error.Code)
 			if errorValue := gjson.Get(json, errorKey); !errorValue.Exists() {
 				errorString := ""
-				if file.Name() == "en.json" {
+				if english {
 					errorString = error.Message
 				}
 				if json, err = sjson.Set(json, errorKey, errorString); err != nil {
@@ -429,14 +488,14 @@ func PackErrorsIntoLocales() error {
 				logger.Debug().Int("errorCode", error.Code).Send()
 			}
 		}
-		var jsonOut bytes.Buffer
-		if err = json2.Indent(&jsonOut, []byte(json), "", "  "); err != nil {
-			return err
-		}
-		if err = ioutil.WriteFile(filepath.Join(localesDir, file.Name()), jsonOut.Bytes(), file.Mode()); err != nil {
-			return err
-		}
-		logger.Debug().Msg("Done.")
 	}
+	var jsonOut bytes.Buffer
+	if err = json2.Indent(&jsonOut, []byte(json), "", "  "); err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(path, jsonOut.Bytes(), info.Mode()); err != nil {
+		return err
+	}
+	logger.Debug().Msg("Done.")
 	return nil
 }
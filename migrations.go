@@ -51,5 +51,131 @@ UPDATE vaults SET mountpoint = "" WHERE mountpoint IS NULL;`)
 				return err
 			},
 		},
+		&migrator.Migration{
+			Name: "Add idletimeout & maxlifetime column",
+			Func: func(tx *sql.Tx) error {
+				for _, column := range []string{"idletimeout", "maxlifetime"} {
+					_, err := tx.Exec(fmt.Sprintf(`ALTER TABLE vaults ADD COLUMN %s INTEGER DEFAULT 0;`, column))
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		&migrator.Migration{
+			Name: "Add locksuspend column",
+			Func: func(tx *sql.Tx) error {
+				_, err := tx.Exec(`ALTER TABLE vaults ADD COLUMN locksuspend BOOLEAN DEFAULT true;`)
+				return err
+			},
+		},
+		&migrator.Migration{
+			Name: "Add reverse, exclude & excludewildcard column",
+			Func: func(tx *sql.Tx) error {
+				_, err := tx.Exec(`ALTER TABLE vaults ADD COLUMN reverse BOOLEAN DEFAULT false;
+ALTER TABLE vaults ADD COLUMN exclude TEXT DEFAULT '[]';
+ALTER TABLE vaults ADD COLUMN excludewildcard TEXT DEFAULT '[]';`)
+				return err
+			},
+		},
+		&migrator.Migration{
+			Name: "Add rememberpassword column",
+			Func: func(tx *sql.Tx) error {
+				_, err := tx.Exec(`ALTER TABLE vaults ADD COLUMN rememberpassword BOOLEAN DEFAULT false;`)
+				return err
+			},
+		},
+		&migrator.Migration{
+			Name: "Create fsck_results table",
+			Func: func(tx *sql.Tx) error {
+				_, err := tx.Exec(`CREATE TABLE IF NOT EXISTS fsck_results (
+    id INTEGER PRIMARY KEY,
+    vaultid INTEGER NOT NULL,
+    state TEXT NOT NULL,
+    corrupt TEXT DEFAULT '[]',
+    message TEXT DEFAULT '',
+    startedat INTEGER DEFAULT 0,
+    finishedat INTEGER DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS fsck_results_vaultid ON fsck_results (vaultid);`)
+				return err
+			},
+		},
+		&migrator.Migration{
+			Name: "Create events table",
+			Func: func(tx *sql.Tx) error {
+				_, err := tx.Exec(`CREATE TABLE IF NOT EXISTS events (
+    id INTEGER PRIMARY KEY,
+    vaultid INTEGER NOT NULL,
+    operation TEXT NOT NULL,
+    outcome TEXT NOT NULL,
+    code INTEGER DEFAULT 0,
+    rc INTEGER DEFAULT 0,
+    timestamp INTEGER DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS events_vaultid ON events (vaultid);
+CREATE INDEX IF NOT EXISTS events_timestamp ON events (timestamp);`)
+				return err
+			},
+		},
+		&migrator.Migration{
+			Name: "Create groups, vault_groups & vault_tags table",
+			Func: func(tx *sql.Tx) error {
+				_, err := tx.Exec(`CREATE TABLE IF NOT EXISTS groups (
+    id INTEGER PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE
+);
+CREATE TABLE IF NOT EXISTS vault_groups (
+    groupid INTEGER NOT NULL,
+    vaultid INTEGER NOT NULL,
+    PRIMARY KEY (groupid, vaultid)
+);
+CREATE INDEX IF NOT EXISTS vault_groups_vaultid ON vault_groups (vaultid);
+CREATE TABLE IF NOT EXISTS vault_tags (
+    vaultid INTEGER NOT NULL,
+    tag TEXT NOT NULL,
+    PRIMARY KEY (vaultid, tag)
+);`)
+				return err
+			},
+		},
+		&migrator.Migration{
+			Name: "Add debugmount column",
+			Func: func(tx *sql.Tx) error {
+				_, err := tx.Exec(`ALTER TABLE vaults ADD COLUMN debugmount BOOLEAN DEFAULT false;`)
+				return err
+			},
+		},
+		&migrator.Migration{
+			Name: "Add mountoptions column",
+			Func: func(tx *sql.Tx) error {
+				_, err := tx.Exec(`ALTER TABLE vaults ADD COLUMN mountoptions TEXT DEFAULT '[]';`)
+				return err
+			},
+		},
+		&migrator.Migration{
+			Name: "Add name, notes & icon columns",
+			Func: func(tx *sql.Tx) error {
+				_, err := tx.Exec(`ALTER TABLE vaults ADD COLUMN name TEXT DEFAULT '';
+ALTER TABLE vaults ADD COLUMN notes TEXT DEFAULT '';
+ALTER TABLE vaults ADD COLUMN icon TEXT DEFAULT '';`)
+				return err
+			},
+		},
+		&migrator.Migration{
+			Name: "Add identity column",
+			Func: func(tx *sql.Tx) error {
+				_, err := tx.Exec(`ALTER TABLE vaults ADD COLUMN identity TEXT DEFAULT '';`)
+				return err
+			},
+		},
+		&migrator.Migration{
+			Name: "Add backend column",
+			Func: func(tx *sql.Tx) error {
+				_, err := tx.Exec(`ALTER TABLE vaults ADD COLUMN backend TEXT DEFAULT 'gocryptfs';`)
+				return err
+			},
+		},
 	}
 }
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// AuditEvent records an operation performed on a vault, for auditing purposes.
+// Secrets like passwords and master keys are never part of it.
+type AuditEvent struct {
+	ID        int64  `db:"column:id;" json:"id"`
+	VaultID   int64  `db:"column:vaultid;" json:"vault"`
+	Operation string `db:"column:operation;" json:"operation"` // e.g. unlock, lock, password_change
+	Outcome   string `db:"column:outcome;" json:"outcome"`     // succeeded/failed/cancelled
+	Code      int    `db:"column:code;" json:"code"`           // ApiError code, 0 if succeeded
+	RC        int    `db:"column:rc;" json:"rc"`               // exit code of gocryptfs, 0 if it did not run or exit
+	Timestamp int64  `db:"column:timestamp;" json:"timestamp"` // unix timestamp in seconds
+}
+
+// AuditFilter narrows down audit events to list, zero values match everything.
+type AuditFilter struct {
+	VaultID   int64
+	Operation string
+	Outcome   string
+	Since     int64 // unix timestamp in seconds, inclusive
+	Until     int64 // unix timestamp in seconds, exclusive
+	Limit     int   // 0 for no limit
+	Offset    int
+}
+
+// where builds the WHERE clause of given filter along with its arguments.
+func (f AuditFilter) where() (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	if f.VaultID != 0 {
+		conditions = append(conditions, "vaultid = ?")
+		args = append(args, f.VaultID)
+	}
+	if f.Operation != "" {
+		conditions = append(conditions, "operation = ?")
+		args = append(args, f.Operation)
+	}
+	if f.Outcome != "" {
+		conditions = append(conditions, "outcome = ?")
+		args = append(args, f.Outcome)
+	}
+	if f.Since != 0 {
+		conditions = append(conditions, "timestamp >= ?")
+		args = append(args, f.Since)
+	}
+	if f.Until != 0 {
+		conditions = append(conditions, "timestamp < ?")
+		args = append(args, f.Until)
+	}
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// CreateAuditEvent stores an audit event, its ID gets filled.
+func (r *VaultRepo) CreateAuditEvent(event *AuditEvent, tx Transactional) error {
+	if tx == nil {
+		tx = r.db
+	}
+	res, err := tx.Exec(
+		`INSERT INTO events (vaultid, operation, outcome, code, rc, timestamp) VALUES (?, ?, ?, ?, ?, ?);`,
+		event.VaultID, event.Operation, event.Outcome, event.Code, event.RC, event.Timestamp,
+	)
+	if err != nil {
+		return err
+	}
+	event.ID, err = res.LastInsertId()
+	return err
+}
+
+// ListAuditEvents lists audit events matching given filter, the latest first.
+// The total number of matching events regardless of `Limit` and `Offset` is returned too.
+func (r *VaultRepo) ListAuditEvents(filter AuditFilter, tx Transactional) (events []AuditEvent, total int, err error) {
+	if tx == nil {
+		tx = r.db
+	}
+	where, args := filter.where()
+	if err = tx.QueryRow(`SELECT COUNT(*) FROM events`+where+`;`, args...).Scan(&total); err != nil {
+		return
+	}
+
+	query := `SELECT * FROM events` + where + ` ORDER BY id DESC`
+	if filter.Limit > 0 {
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, filter.Limit, filter.Offset)
+	}
+	var rows *sql.Rows
+	if rows, err = tx.Query(query+`;`, args...); err != nil {
+		return
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var event AuditEvent
+		if err = rows.Scan(r.FieldPointers(&event)...); err != nil {
+			return
+		}
+		events = append(events, event)
+	}
+	err = rows.Err()
+	return
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"database/sql"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type auditTestSuite struct {
+	suite.Suite
+	mock sqlmock.Sqlmock
+	repo *VaultRepo
+}
+
+func (s *auditTestSuite) SetupSuite() {
+	var db *sql.DB
+	var err error
+	db, s.mock, err = sqlmock.New()
+	s.Require().NoError(err)
+	s.repo = NewVaultRepo(db)
+}
+
+func (s *auditTestSuite) TearDownSuite() {
+	s.repo.db.Close()
+}
+
+func (s *auditTestSuite) AfterTest(_, _ string) {
+	s.Require().NoError(s.mock.ExpectationsWereMet())
+}
+
+func (s *auditTestSuite) Test_01_CreateAuditEvent() {
+	event := AuditEvent{
+		VaultID:   1,
+		Operation: "unlock",
+		Outcome:   "failed",
+		Code:      10,
+		RC:        12,
+		Timestamp: 1700000000,
+	}
+	s.mock.ExpectExec(`INSERT INTO events(.+)`).
+		WithArgs(int64(1), "unlock", "failed", 10, 12, int64(1700000000)).
+		WillReturnResult(sqlmock.NewResult(5, 1))
+	s.Require().NoError(s.repo.CreateAuditEvent(&event, nil))
+	s.Require().EqualValues(5, event.ID)
+}
+
+func (s *auditTestSuite) Test_02_ListAuditEvents() {
+	columns := []string{"id", "vaultid", "operation", "outcome", "code", "rc", "timestamp"}
+
+	// No filter, no limit
+	s.mock.ExpectQuery(`SELECT COUNT\(\*\) FROM events;`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	s.mock.ExpectQuery(`SELECT \* FROM events ORDER BY id DESC;`).
+		WillReturnRows(
+			sqlmock.NewRows(columns).
+				AddRow(2, 1, "lock", "succeeded", 0, 0, 1700000060).
+				AddRow(1, 1, "unlock", "succeeded", 0, 0, 1700000000),
+		)
+	events, total, err := s.repo.ListAuditEvents(AuditFilter{}, nil)
+	s.Require().NoError(err)
+	s.Require().Equal(2, total)
+	s.Require().Equal([]AuditEvent{
+		{ID: 2, VaultID: 1, Operation: "lock", Outcome: "succeeded", Timestamp: 1700000060},
+		{ID: 1, VaultID: 1, Operation: "unlock", Outcome: "succeeded", Timestamp: 1700000000},
+	}, events)
+
+	// Filtered and paginated
+	filter := AuditFilter{
+		VaultID:   1,
+		Operation: "unlock",
+		Outcome:   "failed",
+		Since:     1700000000,
+		Until:     1700000100,
+		Limit:     10,
+		Offset:    20,
+	}
+	where := `WHERE vaultid = \? AND operation = \? AND outcome = \? AND timestamp >= \? AND timestamp < \?`
+	s.mock.ExpectQuery(`SELECT COUNT\(\*\) FROM events `+where+`;`).
+		WithArgs(int64(1), "unlock", "failed", int64(1700000000), int64(1700000100)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(21))
+	s.mock.ExpectQuery(`SELECT \* FROM events `+where+` ORDER BY id DESC LIMIT \? OFFSET \?;`).
+		WithArgs(int64(1), "unlock", "failed", int64(1700000000), int64(1700000100), 10, 20).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(3, 1, "unlock", "failed", 10, 12, 1700000030))
+	events, total, err = s.repo.ListAuditEvents(filter, nil)
+	s.Require().NoError(err)
+	s.Require().Equal(21, total)
+	s.Require().Equal([]AuditEvent{
+		{ID: 3, VaultID: 1, Operation: "unlock", Outcome: "failed", Code: 10, RC: 12, Timestamp: 1700000030},
+	}, events)
+}
+
+func Test_AuditEvent(t *testing.T) {
+	suite.Run(t, new(auditTestSuite))
+}
@@ -64,6 +64,7 @@ type Field struct {
 }
 
 // Fields returns all defined columns of given struct pointer `m`
+// Struct fields without a `column` tag are skipped, e.g. fields computed after loading.
 //
 // Notice: `m` must be a pointer, otherwise this function panics.
 func (r *BaseRepo) Fields(m interface{}) []*Field {
@@ -72,7 +73,7 @@ func (r *BaseRepo) Fields(m interface{}) []*Field {
 	}
 	s := reflect.ValueOf(m).Elem()
 	typeOfM := s.Type()
-	fields := make([]*Field, s.NumField())
+	fields := make([]*Field, 0, s.NumField())
 
 	for i := 0; i < s.NumField(); i++ {
 		for _, value := range strings.Split(typeOfM.Field(i).Tag.Get("db"), ";") {
@@ -80,10 +81,10 @@ func (r *BaseRepo) Fields(m interface{}) []*Field {
 			if len(v) < 2 || strings.TrimSpace(v[0]) != "column" {
 				continue
 			}
-			fields[i] = &Field{
+			fields = append(fields, &Field{
 				Name:    strings.TrimSpace(strings.Join(v[1:], ":")),
 				Pointer: s.Field(i).Addr().Interface(),
-			}
+			})
 		}
 	}
 
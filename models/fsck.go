@@ -0,0 +1,47 @@
+package models
+
+// FsckResult is the outcome of checking a vault with `gocryptfs -fsck`.
+type FsckResult struct {
+	ID         int64      `db:"column:id;" json:"id"`
+	VaultID    int64      `db:"column:vaultid;" json:"vault"`
+	State      string     `db:"column:state;" json:"state"`           // succeeded/failed/cancelled
+	Corrupt    StringList `db:"column:corrupt;" json:"corrupt"`       // corrupt paths found, relative to the vault root
+	Message    string     `db:"column:message;" json:"message"`       // gocryptfs summary, or why the check failed
+	StartedAt  int64      `db:"column:startedat;" json:"startedat"`   // unix timestamp in seconds
+	FinishedAt int64      `db:"column:finishedat;" json:"finishedat"` // unix timestamp in seconds
+}
+
+// CreateFsckResult stores a finished fsck result, its ID gets filled.
+func (r *VaultRepo) CreateFsckResult(result *FsckResult, tx Transactional) error {
+	if tx == nil {
+		tx = r.db
+	}
+	res, err := tx.Exec(
+		`INSERT INTO fsck_results (vaultid, state, corrupt, message, startedat, finishedat) VALUES (?, ?, ?, ?, ?, ?);`,
+		result.VaultID, result.State, result.Corrupt, result.Message, result.StartedAt, result.FinishedAt,
+	)
+	if err != nil {
+		return err
+	}
+	result.ID, err = res.LastInsertId()
+	return err
+}
+
+// LatestFsckResult gets the latest fsck result of given vault, `sql.ErrNoRows` is returned if it was never checked.
+func (r *VaultRepo) LatestFsckResult(vaultId int64, tx Transactional) (result FsckResult, err error) {
+	if tx == nil {
+		tx = r.db
+	}
+	err = tx.QueryRow(`SELECT * FROM fsck_results WHERE vaultid = ? ORDER BY id DESC LIMIT 1;`, vaultId).
+		Scan(r.FieldPointers(&result)...)
+	return
+}
+
+// DeleteFsckResults deletes all fsck results of given vault.
+func (r *VaultRepo) DeleteFsckResults(vaultId int64, tx Transactional) error {
+	if tx == nil {
+		tx = r.db
+	}
+	_, err := tx.Exec(`DELETE FROM fsck_results WHERE vaultid = ?;`, vaultId)
+	return err
+}
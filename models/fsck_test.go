@@ -0,0 +1,74 @@
+package models
+
+import (
+	"database/sql"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type fsckTestSuite struct {
+	suite.Suite
+	mock sqlmock.Sqlmock
+	repo *VaultRepo
+}
+
+func (s *fsckTestSuite) SetupSuite() {
+	var db *sql.DB
+	var err error
+	db, s.mock, err = sqlmock.New()
+	s.Require().NoError(err)
+	s.repo = NewVaultRepo(db)
+}
+
+func (s *fsckTestSuite) TearDownSuite() {
+	s.repo.db.Close()
+}
+
+func (s *fsckTestSuite) AfterTest(_, _ string) {
+	s.Require().NoError(s.mock.ExpectationsWereMet())
+}
+
+func (s *fsckTestSuite) Test_01_FsckResult() {
+	// Create
+	result := FsckResult{
+		VaultID:    1,
+		State:      "failed",
+		Corrupt:    StringList{"/a.txt"},
+		Message:    "1 corrupt files",
+		StartedAt:  1700000000,
+		FinishedAt: 1700000060,
+	}
+	s.mock.ExpectExec(`INSERT INTO fsck_results(.+)`).
+		WithArgs(int64(1), "failed", sqlmock.AnyArg(), "1 corrupt files", int64(1700000000), int64(1700000060)).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+	s.Require().NoError(s.repo.CreateFsckResult(&result, nil))
+	s.Require().EqualValues(3, result.ID)
+
+	// Latest
+	s.mock.ExpectQuery(`SELECT \* FROM fsck_results WHERE vaultid = \?(.+)`).
+		WithArgs(int64(1)).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "vaultid", "state", "corrupt", "message", "startedat", "finishedat"}).
+				AddRow(3, 1, "failed", `["/a.txt"]`, "1 corrupt files", 1700000000, 1700000060),
+		)
+	latest, err := s.repo.LatestFsckResult(1, nil)
+	s.Require().NoError(err)
+	s.Require().EqualValues(result, latest)
+
+	s.mock.ExpectQuery(`SELECT \* FROM fsck_results WHERE vaultid = \?(.+)`).
+		WithArgs(int64(2)).
+		WillReturnError(sql.ErrNoRows)
+	_, err = s.repo.LatestFsckResult(2, nil)
+	s.Require().ErrorIs(err, sql.ErrNoRows)
+
+	// Delete
+	s.mock.ExpectExec(`DELETE FROM fsck_results WHERE vaultid = \?(.+)`).
+		WithArgs(int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.Require().NoError(s.repo.DeleteFsckResults(1, nil))
+}
+
+func Test_FsckResult(t *testing.T) {
+	suite.Run(t, new(fsckTestSuite))
+}
@@ -0,0 +1,180 @@
+package models
+
+import "database/sql"
+
+// Group is a named set of vaults, e.g. vaults of a project, which can be unlocked or locked together.
+// A vault might belong to several groups.
+type Group struct {
+	ID   int64  `db:"column:id;" json:"id"`
+	Name string `db:"column:name;" json:"name"`
+}
+
+// Membership is a vault belonging to a group.
+type Membership struct {
+	GroupID int64 `db:"column:groupid;" json:"group"`
+	VaultID int64 `db:"column:vaultid;" json:"vault"`
+}
+
+// VaultTag is a free-form label attached to a vault.
+type VaultTag struct {
+	VaultID int64  `db:"column:vaultid;" json:"vault"`
+	Tag     string `db:"column:tag;" json:"tag"`
+}
+
+// CreateGroup creates a new group of given name.
+func (r *VaultRepo) CreateGroup(name string, tx Transactional) (group Group, err error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var res sql.Result
+	if res, err = tx.Exec(`INSERT INTO groups (name) VALUES (?);`, name); err != nil {
+		return
+	}
+	if group.ID, err = res.LastInsertId(); err != nil {
+		return
+	}
+	group.Name = name
+	return
+}
+
+// GetGroup gets a group by its ID.
+func (r *VaultRepo) GetGroup(id int64, tx Transactional) (group Group, err error) {
+	if tx == nil {
+		tx = r.db
+	}
+	err = tx.QueryRow(`SELECT * FROM groups WHERE id = ?;`, id).Scan(r.FieldPointers(&group)...)
+	return
+}
+
+// ListGroups lists all groups, ordered by name.
+func (r *VaultRepo) ListGroups(tx Transactional) (groups []Group, err error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var rows *sql.Rows
+	if rows, err = tx.Query(`SELECT * FROM groups ORDER BY name ASC, id ASC;`); err != nil {
+		return
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var group Group
+		if err = rows.Scan(r.FieldPointers(&group)...); err != nil {
+			return
+		}
+		groups = append(groups, group)
+	}
+	err = rows.Err()
+	return
+}
+
+// UpdateGroup updates name of given group.
+func (r *VaultRepo) UpdateGroup(group *Group, tx Transactional) error {
+	if tx == nil {
+		tx = r.db
+	}
+	_, err := tx.Exec(`UPDATE groups SET name = ? WHERE id = ?;`, group.Name, group.ID)
+	return err
+}
+
+// DeleteGroup deletes given group along with its memberships, vaults are untouched.
+func (r *VaultRepo) DeleteGroup(id int64, tx Transactional) error {
+	if tx == nil {
+		tx = r.db
+	}
+	if _, err := tx.Exec(`DELETE FROM vault_groups WHERE groupid = ?;`, id); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`DELETE FROM groups WHERE id = ?;`, id)
+	return err
+}
+
+// ListMemberships lists vaults of all groups, ordered by group then vault.
+func (r *VaultRepo) ListMemberships(tx Transactional) (memberships []Membership, err error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var rows *sql.Rows
+	if rows, err = tx.Query(`SELECT groupid, vaultid FROM vault_groups ORDER BY groupid ASC, vaultid ASC;`); err != nil {
+		return
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var membership Membership
+		if err = rows.Scan(r.FieldPointers(&membership)...); err != nil {
+			return
+		}
+		memberships = append(memberships, membership)
+	}
+	err = rows.Err()
+	return
+}
+
+// SetGroupVaults replaces vaults of given group with `vaultIds`.
+func (r *VaultRepo) SetGroupVaults(groupId int64, vaultIds []int64, tx Transactional) error {
+	if tx == nil {
+		tx = r.db
+	}
+	if _, err := tx.Exec(`DELETE FROM vault_groups WHERE groupid = ?;`, groupId); err != nil {
+		return err
+	}
+	for _, vaultId := range vaultIds {
+		if _, err := tx.Exec(
+			`INSERT OR IGNORE INTO vault_groups (groupid, vaultid) VALUES (?, ?);`, groupId, vaultId,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListTags lists tags of all vaults, ordered by vault then tag.
+func (r *VaultRepo) ListTags(tx Transactional) (tags []VaultTag, err error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var rows *sql.Rows
+	if rows, err = tx.Query(`SELECT vaultid, tag FROM vault_tags ORDER BY vaultid ASC, tag ASC;`); err != nil {
+		return
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var tag VaultTag
+		if err = rows.Scan(r.FieldPointers(&tag)...); err != nil {
+			return
+		}
+		tags = append(tags, tag)
+	}
+	err = rows.Err()
+	return
+}
+
+// SetVaultTags replaces tags of given vault with `tags`.
+func (r *VaultRepo) SetVaultTags(vaultId int64, tags []string, tx Transactional) error {
+	if tx == nil {
+		tx = r.db
+	}
+	if _, err := tx.Exec(`DELETE FROM vault_tags WHERE vaultid = ?;`, vaultId); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO vault_tags (vaultid, tag) VALUES (?, ?);`, vaultId, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteVaultGrouping removes given vault from all groups, and deletes its tags.
+func (r *VaultRepo) DeleteVaultGrouping(vaultId int64, tx Transactional) error {
+	if tx == nil {
+		tx = r.db
+	}
+	if _, err := tx.Exec(`DELETE FROM vault_groups WHERE vaultid = ?;`, vaultId); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`DELETE FROM vault_tags WHERE vaultid = ?;`, vaultId)
+	return err
+}
@@ -0,0 +1,119 @@
+package models
+
+import (
+	"database/sql"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type groupTestSuite struct {
+	suite.Suite
+	mock sqlmock.Sqlmock
+	repo *VaultRepo
+}
+
+func (s *groupTestSuite) SetupSuite() {
+	var db *sql.DB
+	var err error
+	db, s.mock, err = sqlmock.New()
+	s.Require().NoError(err)
+	s.repo = NewVaultRepo(db)
+}
+
+func (s *groupTestSuite) TearDownSuite() {
+	s.repo.db.Close()
+}
+
+func (s *groupTestSuite) AfterTest(_, _ string) {
+	s.Require().NoError(s.mock.ExpectationsWereMet())
+}
+
+func (s *groupTestSuite) Test_01_Group() {
+	// Create
+	s.mock.ExpectExec(`INSERT INTO groups \(name\)(.+)`).
+		WithArgs("work").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	group, err := s.repo.CreateGroup("work", nil)
+	s.Require().NoError(err)
+	s.Require().Equal(Group{ID: 1, Name: "work"}, group)
+
+	// Get
+	s.mock.ExpectQuery(`SELECT \* FROM groups WHERE id = \?(.+)`).
+		WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "work"))
+	got, err := s.repo.GetGroup(1, nil)
+	s.Require().NoError(err)
+	s.Require().Equal(group, got)
+
+	// Update
+	group.Name = "project"
+	s.mock.ExpectExec(`UPDATE groups SET name = \? WHERE id = \?(.+)`).
+		WithArgs("project", int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.Require().NoError(s.repo.UpdateGroup(&group, nil))
+
+	// List
+	s.mock.ExpectQuery(`SELECT \* FROM groups ORDER BY name(.+)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(2, "home").AddRow(1, "project"))
+	groups, err := s.repo.ListGroups(nil)
+	s.Require().NoError(err)
+	s.Require().Equal([]Group{{ID: 2, Name: "home"}, {ID: 1, Name: "project"}}, groups)
+
+	// Delete, along with memberships
+	s.mock.ExpectExec(`DELETE FROM vault_groups WHERE groupid = \?(.+)`).
+		WithArgs(int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	s.mock.ExpectExec(`DELETE FROM groups WHERE id = \?(.+)`).
+		WithArgs(int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.Require().NoError(s.repo.DeleteGroup(1, nil))
+}
+
+func (s *groupTestSuite) Test_02_Memberships() {
+	s.mock.ExpectExec(`DELETE FROM vault_groups WHERE groupid = \?(.+)`).
+		WithArgs(int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mock.ExpectExec(`INSERT OR IGNORE INTO vault_groups(.+)`).
+		WithArgs(int64(1), int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mock.ExpectExec(`INSERT OR IGNORE INTO vault_groups(.+)`).
+		WithArgs(int64(1), int64(3)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.Require().NoError(s.repo.SetGroupVaults(1, []int64{2, 3}, nil))
+
+	s.mock.ExpectQuery(`SELECT groupid, vaultid FROM vault_groups(.+)`).
+		WillReturnRows(sqlmock.NewRows([]string{"groupid", "vaultid"}).AddRow(1, 2).AddRow(1, 3))
+	memberships, err := s.repo.ListMemberships(nil)
+	s.Require().NoError(err)
+	s.Require().Equal([]Membership{{GroupID: 1, VaultID: 2}, {GroupID: 1, VaultID: 3}}, memberships)
+}
+
+func (s *groupTestSuite) Test_03_Tags() {
+	s.mock.ExpectExec(`DELETE FROM vault_tags WHERE vaultid = \?(.+)`).
+		WithArgs(int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	s.mock.ExpectExec(`INSERT OR IGNORE INTO vault_tags(.+)`).
+		WithArgs(int64(2), "backup").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.Require().NoError(s.repo.SetVaultTags(2, []string{"backup"}, nil))
+
+	s.mock.ExpectQuery(`SELECT vaultid, tag FROM vault_tags(.+)`).
+		WillReturnRows(sqlmock.NewRows([]string{"vaultid", "tag"}).AddRow(2, "backup"))
+	tags, err := s.repo.ListTags(nil)
+	s.Require().NoError(err)
+	s.Require().Equal([]VaultTag{{VaultID: 2, Tag: "backup"}}, tags)
+
+	// Removing a vault from everything
+	s.mock.ExpectExec(`DELETE FROM vault_groups WHERE vaultid = \?(.+)`).
+		WithArgs(int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mock.ExpectExec(`DELETE FROM vault_tags WHERE vaultid = \?(.+)`).
+		WithArgs(int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.Require().NoError(s.repo.DeleteVaultGrouping(2, nil))
+}
+
+func Test_Group(t *testing.T) {
+	suite.Run(t, new(groupTestSuite))
+}
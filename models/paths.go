@@ -0,0 +1,54 @@
+package models
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// PathVariables maps variable names to directories, e.g. `HOME` or a sync root like `Sync`.
+// Vault paths might refer to them as `${NAME}`, so the same vault list works on several machines.
+type PathVariables map[string]string
+
+var (
+	pathVariablePattern     = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+	pathVariableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// IsValidPathVariableName tells whether given name can be used as a path variable.
+func IsValidPathVariableName(name string) bool {
+	return pathVariableNamePattern.MatchString(name)
+}
+
+// Expand replaces variables in given path with their values, unknown variables are left as is.
+func (v PathVariables) Expand(path string) string {
+	if !strings.Contains(path, "${") {
+		return path
+	}
+	expanded := pathVariablePattern.ReplaceAllStringFunc(path, func(variable string) string {
+		if value, ok := v[variable[2:len(variable)-1]]; ok && value != "" {
+			return value
+		}
+		return variable
+	})
+	return filepath.Clean(expanded)
+}
+
+// Contract replaces the leading directory of given path with the variable referring to it.
+// The variable with the longest matching directory wins, the path is returned as is if none matches.
+func (v PathVariables) Contract(path string) string {
+	best, bestLen := "", 0
+	for name, dir := range v {
+		if dir == "" {
+			continue
+		}
+		dir = filepath.Clean(dir)
+		if (path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))) && len(dir) > bestLen {
+			best, bestLen = name, len(dir)
+		}
+	}
+	if best == "" {
+		return path
+	}
+	return "${" + best + "}" + path[bestLen:]
+}
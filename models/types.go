@@ -0,0 +1,50 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// StringList is a list of strings stored as a JSON array in a single TEXT column.
+type StringList []string
+
+// Scan implements `sql.Scanner`.
+func (l *StringList) Scan(src interface{}) error {
+	var content []byte
+	switch v := src.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case string:
+		content = []byte(v)
+	case []byte:
+		content = v
+	default:
+		return fmt.Errorf("cannot scan %T into StringList", src)
+	}
+	if len(content) == 0 {
+		*l = nil
+		return nil
+	}
+	return json.Unmarshal(content, (*[]string)(l))
+}
+
+// Value implements `driver.Valuer`.
+func (l StringList) Value() (driver.Value, error) {
+	content, err := json.Marshal(l.slice())
+	return string(content), err
+}
+
+// MarshalJSON implements `json.Marshaler`, an empty list is represented as `[]` instead of `null`.
+func (l StringList) MarshalJSON() ([]byte, error) {
+	return json.Marshal(l.slice())
+}
+
+// slice returns the list as a plain slice, which is never nil.
+func (l StringList) slice() []string {
+	if l == nil {
+		return []string{}
+	}
+	return l
+}
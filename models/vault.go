@@ -1,24 +1,83 @@
 package models
 
-import "database/sql"
+import (
+	"database/sql"
+	"path/filepath"
+)
 
 // Vault represents a vault, including its mountpoint/readonly settings.
 type Vault struct {
-	ID         int64  `db:"column:id;" json:"id"`
-	Path       string `db:"column:path;" json:"path"`
-	MountPoint string `db:"column:mountpoint;" json:"mountpoint"`
-	AutoReveal bool   `db:"column:autoreveal;" json:"autoreveal"`
-	ReadOnly   bool   `db:"column:readonly;" json:"readonly"`
+	ID          int64  `db:"column:id;" json:"id"`
+	Path        string `db:"column:path;" json:"path"`
+	MountPoint  string `db:"column:mountpoint;" json:"mountpoint"`
+	AutoReveal  bool   `db:"column:autoreveal;" json:"autoreveal"`
+	ReadOnly    bool   `db:"column:readonly;" json:"readonly"`
+	IdleTimeout int64  `db:"column:idletimeout;" json:"idletimeout"` // seconds, auto-lock when idle for this long, 0 to disable
+	MaxLifetime int64  `db:"column:maxlifetime;" json:"maxlifetime"` // seconds, auto-lock after unlocked for this long, 0 to disable
+	LockSuspend bool   `db:"column:locksuspend;" json:"locksuspend"` // lock on system suspend / screen lock, if enabled globally
+	Reverse     bool   `db:"column:reverse;" json:"reverse"`         // reverse mode: `Path` is plaintext, mounted as an encrypted view
+
+	Exclude         StringList `db:"column:exclude;" json:"exclude"`                 // reverse mode only, paths excluded from the encrypted view
+	ExcludeWildcard StringList `db:"column:excludewildcard;" json:"excludewildcard"` // reverse mode only, gitignore-like patterns excluded
+
+	RememberPassword bool `db:"column:rememberpassword;" json:"rememberpassword"` // store password in system keyring on unlock
+	DebugMount       bool `db:"column:debugmount;" json:"debugmount"`             // run gocryptfs with `-debug`, logging into a separate file
+
+	MountOptions StringList `db:"column:mountoptions;" json:"mountoptions"` // extra gocryptfs flags for unlocking, e.g. `-allow_other` or `-fsname=work`
+
+	Name  string `db:"column:name;" json:"name"`   // display name, the directory name is shown if it's empty
+	Notes string `db:"column:notes;" json:"notes"` // free-form notes
+	Icon  string `db:"column:icon;" json:"icon"`   // colour or icon key for the UI, e.g. `red` or `briefcase`
+
+	Identity string `db:"column:identity;" json:"identity"` // stable identity from the gocryptfs config, to find the vault after it moved
+
+	Backend string `db:"column:backend;" json:"backend"` // tool encrypting the vault, e.g. `gocryptfs` or `cryfs`, resolved by the server
+
+	PathTemplate string `json:"pathtemplate,omitempty"` // `Path` as stored, if it refers to path variables
+}
+
+// Label returns the name shown for this vault, which is its display name or its directory name.
+func (v Vault) Label() string {
+	if v.Name != "" {
+		return v.Name
+	}
+	return filepath.Base(v.Path)
 }
 
 // VaultRepo manages vaults.
 type VaultRepo struct {
 	*BaseRepo
+	vars func() PathVariables // path variables in vault paths, nil if not supported
 }
 
 // NewVaultRepo creates a new VaultRepo instance
 func NewVaultRepo(db *sql.DB) *VaultRepo {
-	return &VaultRepo{&BaseRepo{db}}
+	return &VaultRepo{BaseRepo: &BaseRepo{db}}
+}
+
+// SetPathVariables sets the function returning current path variables.
+// Variables in loaded vault paths are expanded, `PathTemplate` keeps the stored path then.
+func (r *VaultRepo) SetPathVariables(vars func() PathVariables) {
+	r.vars = vars
+}
+
+// expandPath expands path variables in the path of given loaded vault.
+func (r *VaultRepo) expandPath(vault *Vault) {
+	if r.vars == nil {
+		return
+	}
+	if expanded := r.vars().Expand(vault.Path); expanded != vault.Path {
+		vault.PathTemplate = vault.Path
+		vault.Path = expanded
+	}
+}
+
+// storedPath returns the path to store for given vault, which is its template if the path didn't change since loading.
+func (r *VaultRepo) storedPath(vault *Vault) string {
+	if vault.PathTemplate != "" && r.vars != nil && r.vars().Expand(vault.PathTemplate) == vault.Path {
+		return vault.PathTemplate
+	}
+	return vault.Path
 }
 
 // Create creates a new vault record
@@ -27,6 +86,7 @@ func (r *VaultRepo) Create(values map[string]interface{}, tx Transactional) (vau
 		tx = r.db
 	}
 	vault.Path = values["path"].(string)
+	vault.LockSuspend = true
 	if v, ok := values["mountpoint"].(string); ok && (v != "") {
 		vault.MountPoint = v
 	}
@@ -36,17 +96,62 @@ func (r *VaultRepo) Create(values map[string]interface{}, tx Transactional) (vau
 	if v, ok := values["readonly"].(bool); ok {
 		vault.ReadOnly = v
 	}
+	if v, ok := values["idletimeout"].(int64); ok {
+		vault.IdleTimeout = v
+	}
+	if v, ok := values["maxlifetime"].(int64); ok {
+		vault.MaxLifetime = v
+	}
+	if v, ok := values["locksuspend"].(bool); ok {
+		vault.LockSuspend = v
+	}
+	if v, ok := values["reverse"].(bool); ok {
+		vault.Reverse = v
+	}
+	if v, ok := values["exclude"].([]string); ok {
+		vault.Exclude = v
+	}
+	if v, ok := values["excludewildcard"].([]string); ok {
+		vault.ExcludeWildcard = v
+	}
+	if v, ok := values["rememberpassword"].(bool); ok {
+		vault.RememberPassword = v
+	}
+	if v, ok := values["debugmount"].(bool); ok {
+		vault.DebugMount = v
+	}
+	if v, ok := values["mountoptions"].([]string); ok {
+		vault.MountOptions = v
+	}
+	if v, ok := values["name"].(string); ok {
+		vault.Name = v
+	}
+	if v, ok := values["notes"].(string); ok {
+		vault.Notes = v
+	}
+	if v, ok := values["icon"].(string); ok {
+		vault.Icon = v
+	}
+	if v, ok := values["identity"].(string); ok {
+		vault.Identity = v
+	}
+	if v, ok := values["backend"].(string); ok {
+		vault.Backend = v
+	}
 
 	var result sql.Result
 	result, err = tx.Exec(
-		`INSERT INTO vaults (path, mountpoint, autoreveal, readonly) VALUES (?, ?, ?, ?);`,
-		vault.Path, vault.MountPoint, vault.AutoReveal, vault.ReadOnly,
+		`INSERT INTO vaults (path, mountpoint, autoreveal, readonly, idletimeout, maxlifetime, locksuspend, reverse, exclude, excludewildcard, rememberpassword, debugmount, mountoptions, name, notes, icon, identity, backend) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`,
+		vault.Path, vault.MountPoint, vault.AutoReveal, vault.ReadOnly, vault.IdleTimeout, vault.MaxLifetime,
+		vault.LockSuspend, vault.Reverse, vault.Exclude, vault.ExcludeWildcard, vault.RememberPassword, vault.DebugMount,
+		vault.MountOptions, vault.Name, vault.Notes, vault.Icon, vault.Identity, vault.Backend,
 	)
 	if err != nil {
 		return
 	}
 
 	vault.ID, err = result.LastInsertId()
+	r.expandPath(&vault)
 	return
 }
 
@@ -56,6 +161,7 @@ func (r *VaultRepo) Get(id int64, tx Transactional) (vault Vault, err error) {
 		tx = r.db
 	}
 	err = tx.QueryRow(`SELECT * FROM vaults WHERE id = ?;`, id).Scan(r.FieldPointers(&vault)...)
+	r.expandPath(&vault)
 	return
 }
 
@@ -65,8 +171,10 @@ func (r *VaultRepo) Update(v *Vault, tx Transactional) error {
 		tx = r.db
 	}
 	_, err := tx.Exec(
-		`UPDATE vaults SET path = ?, mountpoint = ?, autoreveal = ?, readonly = ? WHERE id = ?;`,
-		v.Path, v.MountPoint, v.AutoReveal, v.ReadOnly, v.ID,
+		`UPDATE vaults SET path = ?, mountpoint = ?, autoreveal = ?, readonly = ?, idletimeout = ?, maxlifetime = ?, locksuspend = ?, reverse = ?, exclude = ?, excludewildcard = ?, rememberpassword = ?, debugmount = ?, mountoptions = ?, name = ?, notes = ?, icon = ?, identity = ?, backend = ? WHERE id = ?;`,
+		r.storedPath(v), v.MountPoint, v.AutoReveal, v.ReadOnly, v.IdleTimeout, v.MaxLifetime, v.LockSuspend,
+		v.Reverse, v.Exclude, v.ExcludeWildcard, v.RememberPassword, v.DebugMount, v.MountOptions,
+		v.Name, v.Notes, v.Icon, v.Identity, v.Backend, v.ID,
 	)
 	return err
 }
@@ -111,6 +219,7 @@ func (r *VaultRepo) List(tx Transactional) (vaults []Vault, err error) {
 		if err != nil {
 			return
 		}
+		r.expandPath(&vault)
 		vaults = append(vaults, vault)
 	}
 	return
@@ -36,11 +36,12 @@ func (s *vaultTestSuite) Test_01_Vault() {
 
 	// Create
 	s.mock.ExpectExec(`INSERT INTO vaults(.+)`).
-		WithArgs(path, "", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs(path, "", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), true, false, sqlmock.AnyArg(), sqlmock.AnyArg(), false, false, sqlmock.AnyArg(), "", "", "", "", "gocryptfs").
 		WillReturnResult(sqlmock.NewResult(1, 0))
 	v, err := s.repo.Create(map[string]interface{}{
 		"path":       path,
 		"mountpoint": mountpoint,
+		"backend":    "gocryptfs",
 	}, nil)
 	s.Require().NoError(err)
 	s.Require().IsType(Vault{}, v)
@@ -50,7 +51,7 @@ func (s *vaultTestSuite) Test_01_Vault() {
 	newPath := "/test_new"
 	v.Path = newPath
 	s.mock.ExpectExec(`UPDATE vaults SET (.+)`).
-		WithArgs(newPath, "", sqlmock.AnyArg(), sqlmock.AnyArg(), v.ID).
+		WithArgs(newPath, "", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), true, false, sqlmock.AnyArg(), sqlmock.AnyArg(), false, false, sqlmock.AnyArg(), "", "", "", "", "gocryptfs", v.ID).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 	err = s.repo.Update(&v, nil)
 	s.Require().NoError(err)
@@ -59,11 +60,12 @@ func (s *vaultTestSuite) Test_01_Vault() {
 
 	// List
 	s.mock.ExpectExec(`INSERT INTO vaults(.+)`).
-		WithArgs(path, "/123", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs(path, "/123", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), true, false, sqlmock.AnyArg(), sqlmock.AnyArg(), false, false, sqlmock.AnyArg(), "", "", "", "", "cryfs").
 		WillReturnResult(sqlmock.NewResult(2, 0))
 	v2, err := s.repo.Create(map[string]interface{}{
 		"path":       path,
 		"mountpoint": "/123",
+		"backend":    "cryfs",
 	}, nil)
 	s.Require().NoError(err)
 	s.Require().IsType(Vault{}, v2)
@@ -72,26 +74,39 @@ func (s *vaultTestSuite) Test_01_Vault() {
 
 	s.mock.ExpectQuery(`SELECT \* FROM vaults(.+)`).
 		WillReturnRows(
-			sqlmock.NewRows([]string{"id", "path", "mountpoint", "autoreveal", "readonly"}).
-				AddRow(1, newPath, "", false, false).
-				AddRow(2, path, "", false, false),
+			sqlmock.NewRows([]string{"id", "path", "mountpoint", "autoreveal", "readonly", "idletimeout", "maxlifetime", "locksuspend", "reverse", "exclude", "excludewildcard", "rememberpassword", "debugmount", "mountoptions", "name", "notes", "icon", "identity", "backend"}).
+				AddRow(1, newPath, "", false, false, 0, 0, true, false, "[]", "[]", false, false, "[]", "", "", "", "", "gocryptfs").
+				AddRow(2, path, "", false, false, 0, 0, true, true, `["/tmp"]`, `["*.log"]`, true, true, `["-allow_other","-fsname=work"]`, "Work", "Tax papers", "red", "1234abcd", "cryfs"),
 		)
 	vaults, err := s.repo.List(nil)
 	s.Require().NoError(err)
 	s.Require().Len(vaults, 2)
+	s.Require().Empty(vaults[0].Exclude)
+	s.Require().True(vaults[1].Reverse)
+	s.Require().EqualValues(StringList{"/tmp"}, vaults[1].Exclude)
+	s.Require().EqualValues(StringList{"*.log"}, vaults[1].ExcludeWildcard)
+	s.Require().True(vaults[1].RememberPassword)
+	s.Require().True(vaults[1].DebugMount)
+	s.Require().EqualValues(StringList{"-allow_other", "-fsname=work"}, vaults[1].MountOptions)
+	s.Require().Equal("Work", vaults[1].Label())
+	s.Require().Equal("Tax papers", vaults[1].Notes)
+	s.Require().Equal("red", vaults[1].Icon)
+	s.Require().Equal("test_new", vaults[0].Label())
+	s.Require().Equal("cryfs", vaults[1].Backend)
 
 	// Get
 	s.mock.ExpectQuery(`SELECT \* FROM vaults WHERE id = \?(.+)`).
 		WithArgs(v.ID).
 		WillReturnRows(
-			sqlmock.NewRows([]string{"id", "path", "mountpoint", "autoreveal", "readonly"}).
-				AddRow(1, newPath, "", false, false),
+			sqlmock.NewRows([]string{"id", "path", "mountpoint", "autoreveal", "readonly", "idletimeout", "maxlifetime", "locksuspend", "reverse", "exclude", "excludewildcard", "rememberpassword", "debugmount", "mountoptions", "name", "notes", "icon", "identity", "backend"}).
+				AddRow(1, newPath, "", false, false, 0, 3600, true, false, nil, "[]", false, false, nil, "", "", "", "", "gocryptfs"),
 		)
 	vault, err := s.repo.Get(v.ID, nil)
 	s.Require().NoError(err)
 	s.Require().IsType(v, vault)
 	s.Require().EqualValues(v.ID, vault.ID)
 	s.Require().EqualValues(v.Path, vault.Path)
+	s.Require().EqualValues(3600, vault.MaxLifetime)
 
 	// Delete
 	s.mock.ExpectExec(`DELETE FROM vaults WHERE id = \?(.+)`).
@@ -102,6 +117,63 @@ func (s *vaultTestSuite) Test_01_Vault() {
 	s.Require().EqualValues(0, v.ID)
 }
 
+func (s *vaultTestSuite) Test_02_StringList() {
+	var l StringList
+	s.Require().NoError(l.Scan(nil))
+	s.Require().Nil(l)
+	s.Require().NoError(l.Scan([]byte(`["a", "b c"]`)))
+	s.Require().EqualValues(StringList{"a", "b c"}, l)
+	s.Require().Error(l.Scan(42))
+
+	value, err := StringList(nil).Value()
+	s.Require().NoError(err)
+	s.Require().EqualValues("[]", value)
+	value, err = l.Value()
+	s.Require().NoError(err)
+	s.Require().EqualValues(`["a","b c"]`, value)
+}
+
+func (s *vaultTestSuite) Test_03_PathVariables() {
+	vars := PathVariables{"HOME": "/home/me", "Sync": "/home/me/Sync", "Empty": ""}
+	s.Equal("/home/me/Sync/work", vars.Expand("${Sync}/work"))
+	s.Equal("/home/me/Vaults", vars.Expand("${HOME}/Vaults"))
+	s.Equal("${Unknown}/work", vars.Expand("${Unknown}/work"))
+	s.Equal("${Empty}/work", vars.Expand("${Empty}/work"))
+	s.Equal("/srv/work", vars.Expand("/srv/work"))
+
+	s.Equal("${Sync}/work", vars.Contract("/home/me/Sync/work"))
+	s.Equal("${HOME}/Vaults", vars.Contract("/home/me/Vaults"))
+	s.Equal("${HOME}", vars.Contract("/home/me"))
+	s.Equal("/home/meow/work", vars.Contract("/home/meow/work"))
+
+	s.True(IsValidPathVariableName("Sync_2"))
+	s.False(IsValidPathVariableName("2Sync"))
+	s.False(IsValidPathVariableName("Sync root"))
+
+	// Paths get expanded on loading, and stored as templates unless they changed
+	s.repo.SetPathVariables(func() PathVariables { return vars })
+	defer s.repo.SetPathVariables(nil)
+	columns := []string{"id", "path", "mountpoint", "autoreveal", "readonly", "idletimeout", "maxlifetime", "locksuspend", "reverse", "exclude", "excludewildcard", "rememberpassword", "debugmount", "mountoptions", "name", "notes", "icon", "identity", "backend"}
+	s.mock.ExpectQuery(`SELECT \* FROM vaults WHERE id = \?(.+)`).
+		WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(1, "${Sync}/work", "", false, false, 0, 0, true, false, "[]", "[]", false, false, "[]", "", "", "", "", "gocryptfs"))
+	vault, err := s.repo.Get(1, nil)
+	s.Require().NoError(err)
+	s.Equal("/home/me/Sync/work", vault.Path)
+	s.Equal("${Sync}/work", vault.PathTemplate)
+
+	s.mock.ExpectExec(`UPDATE vaults SET (.+)`).
+		WithArgs("${Sync}/work", "", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), true, false, sqlmock.AnyArg(), sqlmock.AnyArg(), false, false, sqlmock.AnyArg(), "", "", "", "", "gocryptfs", int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.Require().NoError(s.repo.Update(&vault, nil))
+
+	vault.Path = "/media/usb/work"
+	s.mock.ExpectExec(`UPDATE vaults SET (.+)`).
+		WithArgs("/media/usb/work", "", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), true, false, sqlmock.AnyArg(), sqlmock.AnyArg(), false, false, sqlmock.AnyArg(), "", "", "", "", "gocryptfs", int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.Require().NoError(s.repo.Update(&vault, nil))
+}
+
 func Test_VaultRepo(t *testing.T) {
 	suite.Run(t, new(vaultTestSuite))
 }
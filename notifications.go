@@ -0,0 +1,77 @@
+package main
+
+import (
+	"Cloak/extension"
+	"Cloak/i18n"
+	"Cloak/server"
+	"fmt"
+)
+
+// notificationsEnabled tells whether desktop notifications are switched on, they are unless set to "false".
+func (a *App) notificationsEnabled() bool {
+	return a.config.Get("notifications") != "false"
+}
+
+// watchNotifications sends desktop notifications for vault events users might otherwise miss,
+// i.e. gocryptfs exiting unexpectedly, vaults locked automatically and vaults failed to unlock.
+func (a *App) watchNotifications() {
+	events, _ := a.apiServer.Subscribe()
+	go func() {
+		for event := range events {
+			if !a.notificationsEnabled() {
+				continue
+			}
+			summary, body, urgency, ok := a.notification(event)
+			if !ok {
+				continue
+			}
+			if err := extension.Notify(summary, body, urgency); err != nil {
+				logger.Warn().Err(err).
+					Str("event", event.Type).
+					Int64("vaultId", event.VaultID).
+					Msg("Failed to send desktop notification")
+			}
+		}
+	}()
+}
+
+// notification returns localized text & urgency of the notification for given event.
+// It reports false if the event doesn't need a notification.
+func (a *App) notification(event server.Event) (summary string, body string, urgency int, ok bool) {
+	translator := i18n.GetLocalizer()
+	name := a.vaultName(event.VaultID)
+
+	switch event.Type {
+	case server.EventVaultExited:
+		summary = fmt.Sprintf(translator.T("notification.exited"), name)
+		body = fmt.Sprintf(translator.T("notification.exited_body"), event.RC)
+		return summary, body, extension.UrgencyCritical, true
+	case server.EventVaultAutoLocked:
+		reason := ""
+		if data, isMap := event.Data.(map[string]string); isMap {
+			reason = data["reason"]
+		}
+		summary = fmt.Sprintf(translator.T("notification.autolocked"), name)
+		body = translator.T("notification.reason." + reason)
+		return summary, body, extension.UrgencyNormal, true
+	case server.EventVaultUnlockFailed:
+		summary = fmt.Sprintf(translator.T("notification.unlock_failed"), name)
+		if apiError, isApiError := event.Data.(*server.ApiError); isApiError {
+			// Messages of errors might hold details in English only
+			if body = translator.T(fmt.Sprintf("errors.api_%d", apiError.Code)); body == "" {
+				body = apiError.Message
+			}
+		}
+		return summary, body, extension.UrgencyNormal, true
+	}
+	return "", "", 0, false
+}
+
+// vaultName returns a human friendly name of given vault, for vaults gone already its ID is used.
+func (a *App) vaultName(vaultId int64) string {
+	vault, err := a.repo.Get(vaultId, nil)
+	if err != nil {
+		return fmt.Sprintf("#%d", vaultId)
+	}
+	return vault.Label()
+}
@@ -0,0 +1,183 @@
+package server
+
+import (
+	"Cloak/models"
+	"context"
+	"encoding/csv"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Operations recorded in the audit log
+const (
+	AuditUnlock          = "unlock"
+	AuditLock            = "lock"
+	AuditAutoLock        = "autolock" // locked on suspend, screen lock, max lifetime etc.
+	AuditPasswordChange  = "password_change"
+	AuditPasswordReset   = "password_reset" // reset via master key
+	AuditKDFUpgrade      = "kdf_upgrade"
+	AuditMasterkeyReveal = "masterkey_reveal"
+)
+
+const (
+	auditDefaultLimit = 50
+	auditMaxLimit     = 1000
+)
+
+// Audit records the outcome of an operation on given vault into the audit log.
+// Only the error code and gocryptfs exit code of `err` are recorded, never its message.
+// Requests rejected before running, e.g. for unknown or busy vaults, are recorded as failed too,
+// while locking a locked vault or unlocking an unlocked one is not recorded at all, as nothing happened.
+func (m *VaultManager) Audit(vaultId int64, operation string, err error) {
+	if isAuditNoop(operation, err) {
+		return
+	}
+	event := models.AuditEvent{
+		VaultID:   vaultId,
+		Operation: operation,
+		Outcome:   JobSucceeded,
+		Timestamp: time.Now().Unix(),
+	}
+	switch {
+	case err == nil:
+	case errors.Is(err, context.Canceled):
+		event.Outcome = JobCancelled
+	default:
+		event.Outcome = JobFailed
+		event.Code = toApiError(err).Code
+		if container, ok := err.(*DataContainer); ok {
+			event.RC = container.RC
+		}
+	}
+	if err := m.repo.CreateAuditEvent(&event, nil); err != nil {
+		logger.Error().Err(err).
+			Int64("vaultId", vaultId).
+			Str("operation", operation).
+			Msg("Failed to record audit event")
+	}
+}
+
+// isAuditNoop tells if `err` means that the operation had nothing to do.
+func isAuditNoop(operation string, err error) bool {
+	if err == nil {
+		return false
+	}
+	switch toApiError(err).Code {
+	case ErrVaultAlreadyLocked.Code:
+		return operation == AuditLock || operation == AuditAutoLock
+	case ErrVaultAlreadyUnlocked.Code:
+		return operation == AuditUnlock
+	}
+	return false
+}
+
+// ListAuditEvents lists the audit log, the latest first.
+// Events can be filtered by `vault`, `op`, `outcome` and time range `since`/`until` (unix timestamps or RFC 3339).
+// Results are paginated by `page` (1-based) and `limit`.
+// With `format=csv` or `format=json` the log is exported as a file instead, unpaginated unless asked to.
+func (s *ApiServer) ListAuditEvents(c echo.Context) error {
+	var filter models.AuditFilter
+	var err error
+	if value := c.QueryParam("vault"); value != "" {
+		if filter.VaultID, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return ErrMalformedInput
+		}
+	}
+	filter.Operation = c.QueryParam("op")
+	filter.Outcome = c.QueryParam("outcome")
+	if filter.Since, err = parseAuditTime(c.QueryParam("since")); err != nil {
+		return ErrMalformedInput
+	}
+	if filter.Until, err = parseAuditTime(c.QueryParam("until")); err != nil {
+		return ErrMalformedInput
+	}
+
+	format := c.QueryParam("format")
+	if format != "" && format != "csv" && format != "json" {
+		return ErrMalformedInput
+	}
+	page, limit := 1, auditDefaultLimit
+	if format != "" && c.QueryParam("page") == "" && c.QueryParam("limit") == "" {
+		limit = 0
+	}
+	if value := c.QueryParam("page"); value != "" {
+		if page, err = strconv.Atoi(value); err != nil || page < 1 {
+			return ErrMalformedInput
+		}
+	}
+	if value := c.QueryParam("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > auditMaxLimit {
+			return ErrMalformedInput
+		}
+	}
+	filter.Limit = limit
+	filter.Offset = (page - 1) * limit
+
+	events, total, err := s.repo.ListAuditEvents(filter, nil)
+	if err != nil {
+		return err
+	}
+	if events == nil {
+		events = []models.AuditEvent{}
+	}
+
+	switch format {
+	case "csv":
+		return writeAuditCSV(c, events)
+	case "json":
+		c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="cloak-audit.json"`)
+		return c.JSON(http.StatusOK, events)
+	}
+	return ErrOk.WrapItem(echo.Map{
+		"events": events,
+		"total":  total,
+		"page":   page,
+		"limit":  limit,
+	})
+}
+
+// parseAuditTime parses a time given as either unix timestamp in seconds or RFC 3339, an empty string means 0.
+func parseAuditTime(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if timestamp, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return timestamp, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return 0, err
+	}
+	return t.Unix(), nil
+}
+
+// writeAuditCSV responds audit events as a CSV file, with a header row.
+func writeAuditCSV(c echo.Context, events []models.AuditEvent) error {
+	resp := c.Response()
+	resp.Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+	resp.Header().Set(echo.HeaderContentDisposition, `attachment; filename="cloak-audit.csv"`)
+	resp.WriteHeader(http.StatusOK)
+
+	w := csv.NewWriter(resp)
+	_ = w.Write([]string{"id", "vault", "operation", "outcome", "code", "rc", "time"})
+	for _, event := range events {
+		_ = w.Write([]string{
+			strconv.FormatInt(event.ID, 10),
+			strconv.FormatInt(event.VaultID, 10),
+			event.Operation,
+			event.Outcome,
+			strconv.Itoa(event.Code),
+			strconv.Itoa(event.RC),
+			time.Unix(event.Timestamp, 0).UTC().Format(time.RFC3339),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		// Headers are sent already, nothing more to tell the client
+		logger.Warn().Err(err).Msg("Failed to write audit log as CSV")
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"Cloak/models"
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/suite"
+)
+
+type auditTestSuite struct {
+	suite.Suite
+	mock sqlmock.Sqlmock
+	m    *VaultManager
+}
+
+func (s *auditTestSuite) SetupTest() {
+	db, mock, err := sqlmock.New()
+	s.Require().NoError(err)
+	s.T().Cleanup(func() { db.Close() })
+	s.mock = mock
+	s.m = &VaultManager{repo: models.NewVaultRepo(db)}
+}
+
+func (s *auditTestSuite) AfterTest(_, _ string) {
+	s.Require().NoError(s.mock.ExpectationsWereMet())
+}
+
+// expect expects an audit event to be recorded.
+func (s *auditTestSuite) expect(operation string, outcome string, code int) {
+	s.mock.ExpectExec(`INSERT INTO events(.+)`).
+		WithArgs(1, operation, outcome, code, 0, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+}
+
+func (s *auditTestSuite) Test_01_Outcome() {
+	s.expect(AuditUnlock, JobSucceeded, 0)
+	s.m.Audit(1, AuditUnlock, nil)
+	s.expect(AuditPasswordChange, JobCancelled, 0)
+	s.m.Audit(1, AuditPasswordChange, context.Canceled)
+	s.expect(AuditUnlock, JobFailed, ErrWrongPassword.Code)
+	s.m.Audit(1, AuditUnlock, ErrWrongPassword)
+}
+
+func (s *auditTestSuite) Test_02_Rejected() {
+	// Requests rejected before running are failures all the same
+	s.expect(AuditMasterkeyReveal, JobFailed, ErrVaultNotExist.Code)
+	s.m.Audit(1, AuditMasterkeyReveal, ErrVaultNotExist)
+	s.expect(AuditKDFUpgrade, JobFailed, ErrVaultOperationRunning.Code)
+	s.m.Audit(1, AuditKDFUpgrade, ErrVaultOperationRunning.Reformat("fsck"))
+	s.expect(AuditPasswordReset, JobFailed, ErrVaultAlreadyUnlocked.Code)
+	s.m.Audit(1, AuditPasswordReset, ErrVaultAlreadyUnlocked.WrapState("unlocked"))
+	s.expect(AuditLock, JobFailed, ErrCantUnmount.Code)
+	s.m.Audit(1, AuditLock, ErrCantUnmount.Reformat("gocryptfs"))
+}
+
+func (s *auditTestSuite) Test_03_Noop() {
+	// Nothing is recorded
+	s.m.Audit(1, AuditLock, ErrVaultAlreadyLocked)
+	s.m.Audit(1, AuditAutoLock, ErrVaultAlreadyLocked.WrapState("locked"))
+	s.m.Audit(1, AuditUnlock, ErrVaultAlreadyUnlocked)
+}
+
+func Test_Audit(t *testing.T) {
+	suite.Run(t, new(auditTestSuite))
+}
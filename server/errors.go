@@ -28,6 +28,8 @@ type DataContainer struct {
 	Item  interface{} `json:"item,omitempty"`
 	Items interface{} `json:"items,omitempty"`
 	State string      `json:"state,omitempty"`
+	RC    int         `json:"rc,omitempty"`  // exit code of the backend process, if it failed
+	Log   []string    `json:"log,omitempty"` // last lines of backend process output, if it failed unexpectedly
 }
 
 // WrapList wraps a list of items into an ApiError
@@ -54,6 +56,26 @@ func (a *ApiError) WrapState(state string) *DataContainer {
 	}
 }
 
+// WrapRC wraps exit code of a failed backend process into an ApiError
+func (a *ApiError) WrapRC(rc int) *DataContainer {
+	return &DataContainer{
+		ApiError: a,
+		RC:       rc,
+	}
+}
+
+// WithRC sets exit code of a failed backend process
+func (d *DataContainer) WithRC(rc int) *DataContainer {
+	d.RC = rc
+	return d
+}
+
+// WithLog sets output of a failed backend process
+func (d *DataContainer) WithLog(lines []string) *DataContainer {
+	d.Log = lines
+	return d
+}
+
 // Here is a complete list of API errors
 var (
 	ErrOk                         = &ApiError{Code: 0, Message: "Ok"}
@@ -75,7 +97,31 @@ var (
 	ErrVaultPasswordEmpty         = &ApiError{Code: 16, Message: "Password for the new vault is empty"}
 	ErrVaultInitConfFailed        = &ApiError{Code: 17, Message: "Could not create gocryptfs.conf for the new vault"}
 	ErrVaultUpdateConfFailed      = &ApiError{Code: 18, Message: "Gocryptfs could not write the updated gocryptfs.conf"}
-	ErrMissingGocryptfsXrayBinary = &ApiError{Code: 19, Message: "Cannot locate gocryptfs-xray binary"}
+	ErrMissingGocryptfsXrayBinary = &ApiError{Code: 19, Message: "Cannot locate gocryptfs-xray binary"} // Deprecated: masterkey is revealed natively now
 	ErrMountpointMkdirFailed      = &ApiError{Code: 20, Message: "Failed to create mountpoint directory"}
 	ErrUnauthorized               = &ApiError{Code: 21, Message: "Unauthorized"}
+	ErrVaultBusy                  = &ApiError{Code: 22, Message: "Vault is busy, files are still open by: %s"}
+	ErrInvalidCreateOptions       = &ApiError{Code: 23, Message: "Invalid options for the new vault: %s"}
+	ErrUnsupportedFeature         = &ApiError{Code: 24, Message: "This feature is not supported by gocryptfs: %s"}
+	ErrMalformedVaultConf         = &ApiError{Code: 25, Message: "Vault config is malformed: %v"}
+	ErrVaultConfMismatch          = &ApiError{Code: 26, Message: "Vault config does not match the vault type: %v"}
+	ErrInvalidScryptN             = &ApiError{Code: 27, Message: "Invalid scrypt cost: %s"}
+	ErrPasswordNotStored          = &ApiError{Code: 28, Message: "No password is stored for this vault"}
+	ErrKeyringUnavailable         = &ApiError{Code: 29, Message: "System keyring is not available: %v"}
+	ErrFsckRunning                = &ApiError{Code: 30, Message: "This vault is being checked already"}
+	ErrFsckNotRunning             = &ApiError{Code: 31, Message: "This vault is not being checked"}
+	ErrJobNotExist                = &ApiError{Code: 32, Message: "Given job ID does not exist"}
+	ErrGroupNotExist              = &ApiError{Code: 33, Message: "Given group ID does not exist"}
+	ErrGroupNameTaken             = &ApiError{Code: 34, Message: "A group with this name exists already"}
+	ErrInvalidMountOptions        = &ApiError{Code: 35, Message: "Invalid mount options: %s"}
+	ErrVaultMissing               = &ApiError{Code: 36, Message: "Vault directory is missing, it might have been moved"}
+	ErrVaultNotFound              = &ApiError{Code: 37, Message: "Vault not found under given directories"}
+	ErrVaultAmbiguous             = &ApiError{Code: 38, Message: "Several matching vaults found under given directories"}
+	ErrInvalidPathVariable        = &ApiError{Code: 39, Message: "Invalid path variable: %s"}
+	ErrInvalidImport              = &ApiError{Code: 40, Message: "Invalid import document: %s"}
+	ErrBackendUnavailable         = &ApiError{Code: 41, Message: "Cannot locate %s binary"}
+	ErrUnknownBackend             = &ApiError{Code: 42, Message: "Unknown vault backend: %s"}
+	ErrUnsupportedByBackend       = &ApiError{Code: 43, Message: "This feature is not supported by %s vaults: %s"}
+	ErrVaultOperationRunning      = &ApiError{Code: 44, Message: "Another operation is running on this vault: %s"}
+	ErrCantUnmount                = &ApiError{Code: 45, Message: "Vault could not be locked, %s is still busy"}
 )
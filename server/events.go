@@ -0,0 +1,100 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// Types of events published by VaultManager
+const (
+	EventVaultAdded          = "vault_added"           // a vault was added or created
+	EventVaultRemoved        = "vault_removed"         // a vault was removed from Cloak
+	EventVaultUnlocking      = "vault_unlocking"       // gocryptfs process started to unlock a vault
+	EventVaultUnlocked       = "vault_unlocked"        // a vault got unlocked
+	EventVaultLocked         = "vault_locked"          // a vault got locked, or failed to unlock
+	EventVaultUnlockFailed   = "vault_unlock_failed"   // a vault failed to unlock other than by a typed wrong password, `Data` is the ApiError, `RC` tells the exit code if gocryptfs ran
+	EventVaultAutoLocked     = "vault_autolocked"      // a vault is being locked automatically, `Data` tells the reason
+	EventVaultExited         = "vault_exited"          // gocryptfs exited unexpectedly, `RC` tells the exit code
+	EventVaultOptionsChanged = "vault_options_changed" // options of a vault were updated
+	EventConfigChanged       = "config_changed"        // app config was changed
+	EventFsckProgress        = "fsck_progress"         // a vault check made progress or found corrupt files, `Data` is the FsckStatus
+	EventFsckFinished        = "fsck_finished"         // a vault check finished, `Data` is the FsckStatus
+	EventJobUpdated          = "job_updated"           // a job was queued, made progress or finished, `Data` is the Job
+	EventGroupsChanged       = "groups_changed"        // a group was created, updated or deleted, or tags of a vault changed
+)
+
+// Event represents something happened to vaults or the app.
+type Event struct {
+	Type    string      `json:"type"`
+	VaultID int64       `json:"vault,omitempty"`
+	RC      int         `json:"rc,omitempty"`
+	Data    interface{} `json:"data,omitempty"`
+	Time    time.Time   `json:"time"`
+}
+
+// EventBus delivers published events to any number of subscribers.
+// Slow subscribers miss events instead of blocking publishers.
+type EventBus struct {
+	lock        sync.Mutex
+	subscribers map[chan Event]struct{}
+	closed      bool
+}
+
+// NewEventBus creates a new EventBus instance.
+func NewEventBus() *EventBus {
+	return &EventBus{subscribers: make(map[chan Event]struct{})}
+}
+
+// Subscribe returns a channel on which events are delivered, and a function to cancel the subscription.
+// The channel is closed when subscription gets cancelled or the bus gets closed.
+func (b *EventBus) Subscribe() (<-chan Event, func()) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	ch := make(chan Event, 16)
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	b.subscribers[ch] = struct{}{}
+	return ch, func() {
+		b.lock.Lock()
+		defer b.lock.Unlock()
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Publish sends given event to all subscribers.
+func (b *EventBus) Publish(e Event) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	for ch := range b.subscribers {
+		select {
+		case ch <- e:
+		default:
+			logger.Warn().
+				Str("type", e.Type).
+				Int64("vaultId", e.VaultID).
+				Msg("Event subscriber is too slow, event dropped")
+		}
+	}
+}
+
+// Close closes all subscriptions, nothing gets delivered after this.
+func (b *EventBus) Close() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for ch := range b.subscribers {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+	b.closed = true
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/suite"
+)
+
+type eventsTestSuite struct {
+	suite.Suite
+	bus *EventBus
+}
+
+func (s *eventsTestSuite) SetupTest() {
+	s.bus = NewEventBus()
+}
+
+func (s *eventsTestSuite) Test_01_Publish() {
+	first, unsubscribe := s.bus.Subscribe()
+	second, _ := s.bus.Subscribe()
+
+	// Every subscriber gets every event, timestamped if not yet
+	s.bus.Publish(Event{Type: EventVaultUnlocked, VaultID: 1})
+	for _, ch := range []<-chan Event{first, second} {
+		event := <-ch
+		s.Equal(EventVaultUnlocked, event.Type)
+		s.EqualValues(1, event.VaultID)
+		s.WithinDuration(time.Now(), event.Time, time.Second)
+	}
+
+	// Cancelled subscriptions get nothing further
+	unsubscribe()
+	unsubscribe()
+	s.bus.Publish(Event{Type: EventVaultLocked, VaultID: 1})
+	_, ok := <-first
+	s.False(ok)
+	s.Equal(EventVaultLocked, (<-second).Type)
+}
+
+func (s *eventsTestSuite) Test_02_SlowSubscriber() {
+	slow, _ := s.bus.Subscribe()
+
+	// Publishers are never blocked, slow subscribers miss events instead
+	published := make(chan struct{})
+	go func() {
+		for i := int64(1); i <= int64(cap(slow))+4; i++ {
+			s.bus.Publish(Event{Type: EventVaultAdded, VaultID: i})
+		}
+		close(published)
+	}()
+	select {
+	case <-published:
+	case <-time.After(time.Second * 5):
+		s.FailNow("publisher got blocked")
+	}
+	s.Require().Len(slow, cap(slow))
+	for i := int64(1); i <= int64(cap(slow)); i++ {
+		s.Equal(i, (<-slow).VaultID)
+	}
+
+	// Events are delivered again once the subscriber catches up
+	s.bus.Publish(Event{Type: EventVaultRemoved, VaultID: 1})
+	s.Equal(EventVaultRemoved, (<-slow).Type)
+}
+
+func (s *eventsTestSuite) Test_03_Close() {
+	events, unsubscribe := s.bus.Subscribe()
+	s.bus.Close()
+	_, ok := <-events
+	s.False(ok)
+	unsubscribe()
+
+	// Nothing is delivered after closing
+	s.bus.Publish(Event{Type: EventVaultAdded})
+	late, _ := s.bus.Subscribe()
+	_, ok = <-late
+	s.False(ok)
+}
+
+func (s *eventsTestSuite) Test_04_Stream() {
+	m, _ := newTestManager(s.T())
+	m.events = s.bus
+	api := &ApiServer{VaultManager: m}
+	e := echo.New()
+	e.GET("/api/events", api.StreamEvents)
+	server := httptest.NewServer(e)
+	defer server.Close()
+
+	// Subscribed once headers are responded
+	resp, err := http.Get(server.URL + "/api/events")
+	s.Require().NoError(err)
+	defer resp.Body.Close()
+	s.Equal(http.StatusOK, resp.StatusCode)
+	s.Equal("text/event-stream", resp.Header.Get(echo.HeaderContentType))
+	s.Equal("no-cache", resp.Header.Get(echo.HeaderCacheControl))
+
+	reader := bufio.NewReader(resp.Body)
+	// readEvent reads a message of the stream, as its lines
+	readEvent := func() []string {
+		var lines []string
+		for {
+			line, err := reader.ReadString('\n')
+			s.Require().NoError(err)
+			if line == "\n" {
+				return lines
+			}
+			lines = append(lines, strings.TrimSuffix(line, "\n"))
+		}
+	}
+	for _, event := range []Event{
+		{Type: EventVaultExited, VaultID: 3, RC: 12},
+		{Type: EventConfigChanged, Data: map[string]string{"locale": "zh-Hans"}},
+	} {
+		s.bus.Publish(event)
+		lines := readEvent()
+		s.Require().Len(lines, 2)
+		s.Equal("event: "+event.Type, lines[0])
+		data, ok := strings.CutPrefix(lines[1], "data: ")
+		s.Require().True(ok, lines[1])
+		var received map[string]interface{}
+		s.Require().NoError(json.Unmarshal([]byte(data), &received))
+		s.Equal(event.Type, received["type"])
+		s.Contains(received, "time")
+		if event.VaultID != 0 {
+			s.EqualValues(event.VaultID, received["vault"])
+			s.EqualValues(event.RC, received["rc"])
+		} else {
+			s.NotContains(received, "vault")
+			s.Equal(map[string]interface{}{"locale": "zh-Hans"}, received["data"])
+		}
+	}
+
+	// The stream ends as the server shuts down
+	s.bus.Close()
+	_, err = reader.ReadString('\n')
+	s.Error(err)
+}
+
+func (s *eventsTestSuite) Test_05_ConfigChanged() {
+	m, _ := newTestManager(s.T())
+	m.events = s.bus
+	api := &ApiServer{VaultManager: m}
+	events, _ := s.bus.Subscribe()
+
+	// Changes are requested only, clients are told after the app applied them
+	s.Equal(ErrOk, call(api.SetOptions, "", `{"notifications": false}`))
+	changes := <-m.configCh
+	s.Equal(map[string]string{"notifications": "false"}, changes)
+	s.Empty(events)
+	m.ConfigChanged(changes)
+	event := <-events
+	s.Equal(EventConfigChanged, event.Type)
+	s.Equal(changes, event.Data)
+}
+
+func Test_Events(t *testing.T) {
+	suite.Run(t, new(eventsTestSuite))
+}
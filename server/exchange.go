@@ -0,0 +1,461 @@
+package server
+
+import (
+	"Cloak/backend"
+	"Cloak/models"
+	"Cloak/version"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// ExportVersion is the version of exported documents, it's bumped when the format changes incompatibly.
+const ExportVersion = 1
+
+// importableSettings are app settings taken from imported documents, along with their validators.
+// Path variables, i.e. keys in the `[pathvars]` section, are taken as well.
+var importableSettings = map[string]func(string) bool{
+	"locale": func(v string) bool {
+		return v != ""
+	},
+	"loglevel": func(v string) bool {
+		_, err := zerolog.ParseLevel(strings.ToLower(v))
+		return err == nil
+	},
+	"lockonsuspend": isBoolSetting,
+	"notifications": isBoolSetting,
+}
+
+func isBoolSetting(v string) bool {
+	_, err := strconv.ParseBool(v)
+	return err == nil
+}
+
+// errDryRun rolls back the transaction of a dry run import.
+var errDryRun = errors.New("dry run")
+
+// ExportDocument represents the vault list and app settings, for moving them to another computer.
+type ExportDocument struct {
+	Version  int               `json:"version"`
+	App      string            `json:"app"` // version of the app which exported it
+	Exported time.Time         `json:"exported"`
+	Vaults   []ExportedVault   `json:"vaults"`
+	Groups   []ExportedGroup   `json:"groups"`
+	Settings map[string]string `json:"settings"` // app config key/values
+}
+
+// ExportedVault is a vault along with its tags.
+// Its path refers to path variables as stored, and its ID only identifies it within the document.
+type ExportedVault struct {
+	models.Vault
+	Tags []string `json:"tags,omitempty"`
+}
+
+// ExportedGroup is a group along with document IDs of its vaults.
+type ExportedGroup struct {
+	Name   string  `json:"name"`
+	Vaults []int64 `json:"vaults"`
+}
+
+// ImportSummary tells what an import changed, or would change for dry runs.
+type ImportSummary struct {
+	DryRun   bool              `json:"dryrun"`
+	Added    []string          `json:"added"`    // paths of vaults added
+	Updated  []string          `json:"updated"`  // paths of known vaults whose options got replaced
+	Removed  []string          `json:"removed"`  // paths of vaults removed, in replace mode only
+	Groups   []string          `json:"groups"`   // names of groups created, changed or removed
+	Settings map[string]string `json:"settings"` // app settings changed
+	Ignored  []string          `json:"ignored"`  // settings not taken, i.e. unknown keys or malformed values
+}
+
+// Export returns all vaults along with their options, tags & groups, plus app settings.
+func (m *VaultManager) Export() (ExportDocument, error) {
+	doc := ExportDocument{
+		Version:  ExportVersion,
+		App:      version.Version,
+		Exported: time.Now().UTC(),
+		Vaults:   []ExportedVault{},
+		Groups:   []ExportedGroup{},
+		Settings: m.config.All(),
+	}
+	vaults, err := m.repo.List(nil)
+	if err != nil {
+		return doc, err
+	}
+	_, vaultTags, err := m.vaultGrouping()
+	if err != nil {
+		return doc, err
+	}
+	groups, err := m.ListGroups()
+	if err != nil {
+		return doc, err
+	}
+
+	for _, vault := range vaults {
+		// Paths referring to path variables work on other computers, as long as the variables are set there
+		if vault.PathTemplate != "" {
+			vault.Path, vault.PathTemplate = vault.PathTemplate, ""
+		}
+		doc.Vaults = append(doc.Vaults, ExportedVault{Vault: vault, Tags: vaultTags[vault.ID]})
+	}
+	for _, group := range groups {
+		doc.Groups = append(doc.Groups, ExportedGroup{Name: group.Name, Vaults: group.Vaults})
+	}
+	return doc, nil
+}
+
+// Import takes vaults, groups & app settings from an exported document, all or nothing.
+// Known vaults, i.e. those at the same path, get their options, tags & groups replaced, other vaults are added.
+// Groups of the same name are merged. With `replace`, vaults & groups missing from the document are removed.
+// Nothing is changed with `dryRun`, the summary tells what would change.
+func (m *VaultManager) Import(doc ExportDocument, replace bool, dryRun bool) (ImportSummary, error) {
+	summary := ImportSummary{
+		DryRun:   dryRun,
+		Added:    []string{},
+		Updated:  []string{},
+		Removed:  []string{},
+		Groups:   []string{},
+		Settings: make(map[string]string),
+		Ignored:  []string{},
+	}
+	if doc.Version < 1 || doc.Version > ExportVersion {
+		return summary, ErrInvalidImport.Reformat(fmt.Sprintf("unsupported version %d", doc.Version))
+	}
+
+	// Check the document before touching anything
+	current := m.config.All()
+	for key, value := range doc.Settings {
+		if !isImportableSetting(key, value) {
+			summary.Ignored = append(summary.Ignored, key)
+		} else if current[key] != value {
+			summary.Settings[key] = value
+		}
+	}
+	sort.Strings(summary.Ignored)
+	// Imported vaults might refer to imported path variables
+	vars := importedPathVariables(m.pathVariables(), summary.Settings)
+	paths := make(map[string]bool) // expanded paths of imported vaults
+	docIds := make(map[int64]bool) // document IDs of imported vaults
+	groupNames := make(map[string]bool)
+	for i := range doc.Vaults {
+		if err := m.checkImportedVault(&doc.Vaults[i], vars); err != nil {
+			return summary, err
+		}
+		vault := doc.Vaults[i]
+		path := vars.Expand(vault.Path)
+		if paths[path] {
+			return summary, ErrInvalidImport.Reformat(fmt.Sprintf("vault %s is given more than once", path))
+		}
+		if docIds[vault.ID] {
+			return summary, ErrInvalidImport.Reformat(fmt.Sprintf("vault ID %d is given more than once", vault.ID))
+		}
+		paths[path], docIds[vault.ID] = true, true
+	}
+	for i, group := range doc.Groups {
+		doc.Groups[i].Name = strings.TrimSpace(group.Name)
+		if doc.Groups[i].Name == "" || groupNames[doc.Groups[i].Name] {
+			return summary, ErrInvalidImport.Reformat(fmt.Sprintf("group %q is unnamed or given more than once", group.Name))
+		}
+		groupNames[doc.Groups[i].Name] = true
+		for _, vaultId := range group.Vaults {
+			if !docIds[vaultId] {
+				return summary, ErrInvalidImport.Reformat(fmt.Sprintf("group %q refers to unknown vault ID %d", group.Name, vaultId))
+			}
+		}
+	}
+	// Lock internal maps
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var added, updated, removed []models.Vault
+	err := m.repo.WithTransaction(func(tx models.Transactional) error {
+		existing, err := m.repo.List(tx)
+		if err != nil {
+			return err
+		}
+		known := make(map[string]models.Vault) // paths once imported path variables are set: vault
+		for _, vault := range existing {
+			path := importedVaultPath(vault, vars)
+			known[path] = vault
+			if !replace && !paths[path] {
+				continue
+			}
+			// Vaults to update or remove must be locked
+			if _, ok := m.mountPoints[vault.ID]; ok {
+				return ErrVaultAlreadyUnlocked.WrapItem(VaultInfo{Vault: vault, State: "unlocked"})
+			}
+			if err := m.checkVaultIdle(vault.ID); err != nil {
+				return err
+			}
+			if replace && !paths[path] {
+				if err := m.repo.DeleteFsckResults(vault.ID, tx); err != nil {
+					return err
+				}
+				if err := m.repo.DeleteVaultGrouping(vault.ID, tx); err != nil {
+					return err
+				}
+				if err := m.repo.Delete(&vault, tx); err != nil {
+					return err
+				}
+				removed = append(removed, vault)
+			}
+		}
+
+		// Vaults, document ID: vault ID
+		ids := make(map[int64]int64)
+		for _, imported := range doc.Vaults {
+			path := vars.Expand(imported.Path)
+			vault, ok := known[path]
+			if ok && vault.Reverse != imported.Reverse {
+				return ErrInvalidImport.Reformat(fmt.Sprintf("vault %s: reverse mode differs from the known vault", path))
+			}
+			if ok && vault.Backend != imported.Backend {
+				return ErrInvalidImport.Reformat(fmt.Sprintf("vault %s: backend differs from the known vault", path))
+			}
+			if !ok {
+				identity := vaultIdentity(imported.Backend, path, imported.Reverse)
+				if identity == "" {
+					identity = imported.Identity
+				}
+				vault, err = m.repo.Create(map[string]interface{}{
+					"path":     imported.Path,
+					"reverse":  imported.Reverse,
+					"identity": identity,
+					"backend":  imported.Backend,
+				}, tx)
+				if err != nil {
+					return ErrInvalidImport.Reformat(fmt.Sprintf("vault %s: %v", path, err))
+				}
+			}
+			copyVaultOptions(&vault, imported.Vault)
+			if err := m.repo.Update(&vault, tx); err != nil {
+				return ErrInvalidImport.Reformat(fmt.Sprintf("vault %s: %v", path, err))
+			}
+			if err := m.repo.SetVaultTags(vault.ID, imported.Tags, tx); err != nil {
+				return err
+			}
+			ids[imported.ID] = vault.ID
+			if ok {
+				updated = append(updated, vault)
+			} else {
+				added = append(added, vault)
+			}
+		}
+
+		// Groups
+		groups, err := m.repo.ListGroups(tx)
+		if err != nil {
+			return err
+		}
+		memberships, err := m.repo.ListMemberships(tx)
+		if err != nil {
+			return err
+		}
+		groupVaults := make(map[int64][]int64)
+		for _, membership := range memberships {
+			groupVaults[membership.GroupID] = append(groupVaults[membership.GroupID], membership.VaultID)
+		}
+		groupIds := make(map[string]int64)
+		for _, group := range groups {
+			if replace && !groupNames[group.Name] {
+				if err := m.repo.DeleteGroup(group.ID, tx); err != nil {
+					return err
+				}
+				summary.Groups = append(summary.Groups, group.Name)
+				continue
+			}
+			groupIds[group.Name] = group.ID
+		}
+		for _, imported := range doc.Groups {
+			groupId, ok := groupIds[imported.Name]
+			if !ok {
+				group, err := m.repo.CreateGroup(imported.Name, tx)
+				if err != nil {
+					return err
+				}
+				groupId = group.ID
+			}
+			// Vaults already in the group are kept unless replacing
+			var vaultIds []int64
+			if !replace {
+				vaultIds = groupVaults[groupId]
+			}
+			for _, docId := range imported.Vaults {
+				vaultIds = append(vaultIds, ids[docId])
+			}
+			if ok && sameVaultIds(vaultIds, groupVaults[groupId]) {
+				continue
+			}
+			if err := m.repo.SetGroupVaults(groupId, vaultIds, tx); err != nil {
+				return err
+			}
+			summary.Groups = append(summary.Groups, imported.Name)
+		}
+
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		logger.Error().Err(err).Bool("replace", replace).Msg("Failed to import vaults")
+		return summary, err
+	}
+
+	for _, vault := range added {
+		summary.Added = append(summary.Added, importedVaultPath(vault, vars))
+	}
+	for _, vault := range updated {
+		summary.Updated = append(summary.Updated, importedVaultPath(vault, vars))
+	}
+	for _, vault := range removed {
+		summary.Removed = append(summary.Removed, importedVaultPath(vault, vars))
+	}
+	if dryRun {
+		return summary, nil
+	}
+
+	logger.Info().
+		Int("added", len(added)).
+		Int("updated", len(updated)).
+		Int("removed", len(removed)).
+		Bool("replace", replace).
+		Msg("Imported vaults")
+	for _, vault := range removed {
+		// Stored password is useless now
+		if vault.RememberPassword {
+			_ = m.ForgetPassword(vault)
+		}
+		delete(m.outputs, vault.ID)
+		removeDebugLog(vault.ID)
+		m.events.Publish(Event{Type: EventVaultRemoved, VaultID: vault.ID})
+	}
+	for _, vault := range added {
+		m.events.Publish(Event{Type: EventVaultAdded, VaultID: vault.ID, Data: VaultInfo{Vault: vault, State: "locked"}})
+	}
+	for _, vault := range updated {
+		m.events.Publish(Event{Type: EventVaultOptionsChanged, VaultID: vault.ID, Data: VaultInfo{Vault: vault, State: "locked"}})
+	}
+	if len(summary.Groups) > 0 {
+		m.events.Publish(Event{Type: EventGroupsChanged})
+	}
+	if len(summary.Settings) > 0 {
+		m.configCh <- summary.Settings
+	}
+	return summary, nil
+}
+
+// importedPathVariables returns path variables `vars` updated with those in imported `settings`,
+// an empty path unsets the variable.
+func importedPathVariables(vars models.PathVariables, settings map[string]string) models.PathVariables {
+	for key, value := range settings {
+		if name, ok := strings.CutPrefix(key, pathVarsPrefix); ok {
+			if value == "" {
+				delete(vars, name)
+			} else {
+				vars[name] = value
+			}
+		}
+	}
+	return vars
+}
+
+// importedVaultPath returns the path of a loaded vault once path variables `vars` are set.
+// Vaults created during the import keep variables unexpanded, since they are not set yet.
+func importedVaultPath(vault models.Vault, vars models.PathVariables) string {
+	if vault.PathTemplate != "" {
+		return vars.Expand(vault.PathTemplate)
+	}
+	return vars.Expand(vault.Path)
+}
+
+// checkImportedVault validates options of an imported vault, and normalizes its mount options.
+func (m *VaultManager) checkImportedVault(vault *ExportedVault, vars models.PathVariables) error {
+	path := vars.Expand(vault.Path)
+	if vault.Path == "" || !filepath.IsAbs(path) {
+		return ErrInvalidImport.Reformat(fmt.Sprintf("vault path %q is not absolute", vault.Path))
+	}
+	if vault.IdleTimeout < 0 || vault.MaxLifetime < 0 {
+		return ErrInvalidImport.Reformat(fmt.Sprintf("vault %s: negative timeout", path))
+	}
+	vault.Name = strings.TrimSpace(vault.Name)
+	if !validVaultLabels(&vault.Name, &vault.Notes, &vault.Icon) {
+		return ErrInvalidImport.Reformat(fmt.Sprintf("vault %s: malformed name, notes or icon", path))
+	}
+	if !vault.Reverse && (len(vault.Exclude) > 0 || len(vault.ExcludeWildcard) > 0) {
+		return ErrInvalidImport.Reformat(fmt.Sprintf("vault %s: exclusions are for reverse vaults only", path))
+	}
+	// Documents exported before backends became pluggable hold gocryptfs vaults only
+	if vault.Backend == "" {
+		vault.Backend = backend.Gocryptfs
+	}
+	features := backend.FeaturesOf(vault.Backend)
+	switch {
+	case !backend.IsKnown(vault.Backend):
+		return ErrInvalidImport.Reformat(fmt.Sprintf("vault %s: unknown backend %q", path, vault.Backend))
+	case vault.Reverse && !features.Reverse:
+		return ErrInvalidImport.Reformat(fmt.Sprintf("vault %s: %s vaults have no reverse mode", path, vault.Backend))
+	case vault.IdleTimeout > 0 && !features.IdleTimeout:
+		return ErrInvalidImport.Reformat(fmt.Sprintf("vault %s: %s vaults have no idle timeout", path, vault.Backend))
+	}
+	mountOptions, err := m.CheckMountOptions(vault.Backend, vault.MountOptions)
+	if err != nil {
+		var apiError *ApiError
+		if errors.As(err, &apiError) {
+			return ErrInvalidImport.Reformat(fmt.Sprintf("vault %s: %s", path, apiError.Message))
+		}
+		return err
+	}
+	vault.MountOptions = mountOptions
+	vault.MountPoint = strings.TrimSpace(vault.MountPoint)
+	return nil
+}
+
+// copyVaultOptions copies options of `src` to `dst`, i.e. everything except its ID, path, identity & reverse mode.
+func copyVaultOptions(dst *models.Vault, src models.Vault) {
+	dst.MountPoint = src.MountPoint
+	dst.AutoReveal = src.AutoReveal
+	dst.ReadOnly = src.ReadOnly
+	dst.IdleTimeout = src.IdleTimeout
+	dst.MaxLifetime = src.MaxLifetime
+	dst.LockSuspend = src.LockSuspend
+	dst.Exclude = src.Exclude
+	dst.ExcludeWildcard = src.ExcludeWildcard
+	dst.RememberPassword = src.RememberPassword
+	dst.DebugMount = src.DebugMount
+	dst.MountOptions = src.MountOptions
+	dst.Name = src.Name
+	dst.Notes = src.Notes
+	dst.Icon = src.Icon
+}
+
+// sameVaultIds tells whether given vault ID lists hold the same IDs, ignoring order & duplicates.
+func sameVaultIds(a []int64, b []int64) bool {
+	set := make(map[int64]bool)
+	for _, id := range a {
+		set[id] = true
+	}
+	for _, id := range b {
+		if !set[id] {
+			return false
+		}
+		delete(set, id)
+	}
+	return len(set) == 0
+}
+
+// isImportableSetting tells whether given app setting can be imported.
+func isImportableSetting(key string, value string) bool {
+	if name, ok := strings.CutPrefix(key, pathVarsPrefix); ok {
+		_, err := checkPathVariable(name, value)
+		return err == nil
+	}
+	valid, ok := importableSettings[key]
+	return ok && valid(value)
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"Cloak/models"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type exchangeTestSuite struct {
+	suite.Suite
+}
+
+func (s *exchangeTestSuite) Test_01_ImportedPathVariables() {
+	vars := importedPathVariables(models.PathVariables{"HOME": "/home/me", "Old": "/srv/old", "Work": "/srv/work"}, map[string]string{
+		"pathvars.Sync": "/home/me/Sync",
+		"pathvars.Work": "/mnt/work",
+		"pathvars.Old":  "",
+		"locale":        "en",
+	})
+	s.Equal(models.PathVariables{"HOME": "/home/me", "Sync": "/home/me/Sync", "Work": "/mnt/work"}, vars)
+
+	// Paths of known vaults change along with variables
+	s.Equal("/mnt/work/a", importedVaultPath(models.Vault{Path: "/srv/work/a", PathTemplate: "${Work}/a"}, vars))
+	s.Equal("/home/me/Sync/b", importedVaultPath(models.Vault{Path: "${Sync}/b"}, vars))
+	s.Equal("/srv/c", importedVaultPath(models.Vault{Path: "/srv/c"}, vars))
+}
+
+func (s *exchangeTestSuite) Test_02_CheckImportedVault() {
+	m := &VaultManager{}
+	vault := ExportedVault{Vault: models.Vault{Path: "${Sync}/work", Name: " work "}}
+	s.Require().ErrorContains(m.checkImportedVault(&vault, models.PathVariables{"HOME": "/home/me"}), "is not absolute")
+
+	// Variables set by the same document make paths absolute
+	vars := importedPathVariables(models.PathVariables{"HOME": "/home/me"}, map[string]string{"pathvars.Sync": "/home/me/Sync"})
+	s.Require().NoError(m.checkImportedVault(&vault, vars))
+	s.Equal("work", vault.Name)
+	s.Equal("gocryptfs", vault.Backend)
+
+	// Malformed variables are never taken
+	s.False(isImportableSetting("pathvars.Sync", "Sync"))
+	s.False(isImportableSetting("pathvars.HOME", "/home/other"))
+	s.True(isImportableSetting("pathvars.Sync", ""))
+}
+
+func Test_Exchange(t *testing.T) {
+	suite.Run(t, new(exchangeTestSuite))
+}
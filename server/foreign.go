@@ -0,0 +1,150 @@
+package server
+
+import (
+	"Cloak/backend"
+	"Cloak/gocryptfs"
+	"Cloak/importer"
+	"Cloak/models"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// States of vaults found in other front-ends
+const (
+	ForeignNew         = "new"         // can be added
+	ForeignAdded       = "added"       // got added
+	ForeignKnown       = "known"       // added to Cloak already
+	ForeignMissing     = "missing"     // the vault directory is gone
+	ForeignUnsupported = "unsupported" // of an unknown backend, or its config is kept elsewhere
+)
+
+// ForeignVault is a vault found in another front-end, along with whether it can be added to Cloak.
+type ForeignVault struct {
+	importer.Definition
+	State   string `json:"state"`
+	Reason  string `json:"reason,omitempty"` // why it's unsupported
+	VaultID int64  `json:"vault,omitempty"`  // ID of the added or known vault
+}
+
+// ImportForeign adds vaults saved by given front-end, `source` is either `importer.SourceSiriKali` or `importer.SourceVaults`.
+// Only vaults in `cipherDirs` are added unless it's empty. Nothing is added with `dryRun`,
+// which previews what would be added. Vaults are added all or nothing.
+func (m *VaultManager) ImportForeign(source string, cipherDirs []string, dryRun bool) ([]ForeignVault, error) {
+	definitions, err := importer.Load(source)
+	if err != nil {
+		logger.Error().Err(err).Str("source", source).Msg("Failed to read vaults of other front-end")
+		return nil, ErrInvalidImport.Reformat(err)
+	}
+	wanted := make(map[string]bool)
+	for _, dir := range cipherDirs {
+		wanted[filepath.Clean(dir)] = true
+	}
+
+	// Lock internal maps
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	foreign := make([]ForeignVault, 0, len(definitions))
+	var added []models.Vault
+	err = m.repo.WithTransaction(func(tx models.Transactional) error {
+		vaults, err := m.repo.List(tx)
+		if err != nil {
+			return err
+		}
+		known := make(map[string]int64)
+		for _, vault := range vaults {
+			known[vault.Path] = vault.ID
+		}
+
+		for _, definition := range definitions {
+			vault := checkForeignVault(definition, known)
+			if vault.State == ForeignNew && !dryRun && (len(wanted) == 0 || wanted[definition.CipherDir]) {
+				values := map[string]interface{}{
+					"path":        m.storedVaultPath(definition.CipherDir, definition.CipherDir),
+					"mountpoint":  definition.MountPoint,
+					"readonly":    definition.ReadOnly || definition.Reverse,
+					"autoreveal":  definition.AutoOpen,
+					"reverse":     definition.Reverse,
+					"idletimeout": definition.IdleTimeout,
+					"name":        definition.Name,
+					"backend":     vault.Backend,
+					"identity":    vaultIdentity(vault.Backend, definition.CipherDir, definition.Reverse),
+				}
+				if definition.LockSession != nil {
+					values["locksuspend"] = *definition.LockSession
+				}
+				created, err := m.repo.Create(values, tx)
+				if err != nil {
+					return ErrInvalidImport.Reformat(fmt.Sprintf("vault %s: %v", definition.CipherDir, err))
+				}
+				vault.State, vault.VaultID = ForeignAdded, created.ID
+				added = append(added, created)
+			}
+			// The same vault might be saved more than once
+			if vault.VaultID != 0 {
+				known[definition.CipherDir] = vault.VaultID
+			}
+			foreign = append(foreign, vault)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, vault := range added {
+		logger.Debug().
+			Str("source", source).
+			Str("vaultPath", vault.Path).
+			Int64("vaultId", vault.ID).
+			Msg("Added vault of other front-end")
+		m.events.Publish(Event{Type: EventVaultAdded, VaultID: vault.ID, Data: VaultInfo{Vault: vault, State: "locked"}})
+	}
+	return foreign, nil
+}
+
+// checkForeignVault tells whether a vault of another front-end can be added, given paths of known vaults.
+func checkForeignVault(definition importer.Definition, known map[string]int64) ForeignVault {
+	vault := ForeignVault{Definition: definition, State: ForeignNew}
+	if id, ok := known[definition.CipherDir]; ok {
+		vault.State, vault.VaultID = ForeignKnown, id
+		return vault
+	}
+	if definition.Backend != "" && !backend.IsKnown(definition.Backend) {
+		vault.State, vault.Reason = ForeignUnsupported, fmt.Sprintf("%s vaults are not supported", definition.Backend)
+		return vault
+	}
+	if definition.Reverse && definition.Backend != "" && !backend.FeaturesOf(definition.Backend).Reverse {
+		vault.State, vault.Reason = ForeignUnsupported, fmt.Sprintf("reverse %s vaults are not supported", definition.Backend)
+		return vault
+	}
+	if _, err := os.Stat(definition.CipherDir); os.IsNotExist(err) {
+		vault.State = ForeignMissing
+		return vault
+	}
+	// Front-ends supporting several backends might not tell which one a vault uses
+	if vault.Backend == "" {
+		vault.Backend = backend.Detect(definition.CipherDir, definition.Reverse)
+		if vault.Backend == "" {
+			vault.State, vault.Reason = ForeignUnsupported, "no config file of any known backend is found"
+			return vault
+		}
+	}
+
+	// Backends read configs elsewhere with flags like `-config`, which we don't support
+	confPath := filepath.Join(definition.CipherDir, backend.ConfigName(vault.Backend, definition.Reverse))
+	if definition.ConfigFile != "" && definition.ConfigFile != confPath {
+		vault.State, vault.Reason = ForeignUnsupported, "config file is kept outside of the vault directory"
+		return vault
+	}
+	// Only gocryptfs configs can be checked, configs of other backends are encrypted
+	if vault.Backend == backend.Gocryptfs {
+		if _, err := gocryptfs.LoadVault(definition.CipherDir, definition.Reverse); err != nil {
+			vault.State, vault.Reason = ForeignUnsupported, err.Error()
+		}
+	} else if _, err := os.Stat(confPath); err != nil {
+		vault.State, vault.Reason = ForeignUnsupported, err.Error()
+	}
+	return vault
+}
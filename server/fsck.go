@@ -0,0 +1,347 @@
+package server
+
+import (
+	"Cloak/extension"
+	"Cloak/gocryptfs"
+	"Cloak/models"
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"io/fs"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How often progress of a running vault check is measured
+const fsckProgressInterval = time.Second
+
+// FsckStatus is the state of a vault check, either running or finished.
+type FsckStatus struct {
+	models.FsckResult
+	Progress int `json:"progress"` // percentage, -1 if unknown
+}
+
+// fsckJob tracks a vault check run as a job, with the corrupt paths found so far.
+type fsckJob struct {
+	lock   sync.Mutex
+	status FsckStatus
+	job    int64 // ID of the job running the check
+}
+
+// snapshot returns a copy of current job status.
+func (j *fsckJob) snapshot() FsckStatus {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	status := j.status
+	status.Corrupt = append(models.StringList(nil), j.status.Corrupt...)
+	return status
+}
+
+// update modifies job status with `f`, it reports whether `f` did change anything worth publishing.
+func (j *fsckJob) update(f func(status *FsckStatus) bool) bool {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return f(&j.status)
+}
+
+// StartFsck starts checking integrity of given locked vault as a job, with `password` or the stored one.
+// Progress and corrupt files found are published as events, the final result is stored in repository.
+func (m *VaultManager) StartFsck(vaultId int64, password string) (Job, error) {
+	vault, err := m.repo.Get(vaultId, nil)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return Job{}, ErrVaultNotExist
+		}
+		return Job{}, err
+	}
+	// Reverse vaults have nothing encrypted on disk to check
+	if vault.Reverse {
+		return Job{}, ErrUnsupportedOperation
+	}
+	if err := requireGocryptfs(vault, "checking integrity"); err != nil {
+		return Job{}, err
+	}
+	g, err := m.gocryptfs()
+	if err != nil {
+		return Job{}, err
+	}
+	if password, err = storedPassword(vault, password); err != nil {
+		return Job{}, err
+	}
+	if err := checkPassword(vault, password); err != nil {
+		return Job{}, err
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if _, ok := m.mountPoints[vaultId]; ok {
+		return Job{}, ErrVaultAlreadyUnlocked
+	}
+	if err := m.checkVaultIdle(vaultId); err != nil {
+		return Job{}, err
+	}
+
+	fsck := &fsckJob{
+		status: FsckStatus{
+			FsckResult: models.FsckResult{
+				VaultID:   vaultId,
+				State:     JobQueued,
+				StartedAt: time.Now().Unix(),
+			},
+			Progress: -1,
+		},
+	}
+	m.fscks[vaultId] = fsck
+	job := m.jobs.SubmitWithCleanup("fsck", vaultId, func(ctx context.Context, progress func(int)) (interface{}, error) {
+		status := m.runFsck(ctx, g.Binary(), fsck, vault, password, progress)
+		switch {
+		case status.State != JobFailed:
+			return status, nil
+		case status.Message == ErrWrongPassword.Message:
+			return nil, ErrWrongPassword
+		default:
+			return nil, ErrUnknown.Reformat(status.Message)
+		}
+	}, func() {
+		// The check never runs if it gets cancelled while queued, the vault is released before cancelling returns
+		fsck.update(func(status *FsckStatus) bool {
+			if status.State == JobQueued {
+				status.State = JobCancelled
+			}
+			return false
+		})
+		m.lock.Lock()
+		defer m.lock.Unlock()
+		if m.fscks[vaultId] == fsck {
+			delete(m.fscks, vaultId)
+		}
+	})
+	fsck.job = job.ID
+
+	logger.Info().
+		Int64("vaultId", vaultId).
+		Int64("jobId", job.ID).
+		Str("vaultPath", vault.Path).
+		Msg("Queued checking vault")
+	return job, nil
+}
+
+// checkPassword verifies vault password by decrypting its master key, so a wrong one is reported right away.
+// Vaults we cannot decrypt natively are left for gocryptfs to check.
+func checkPassword(vault models.Vault, password string) error {
+	conf, err := gocryptfs.LoadVault(vault.Path, vault.Reverse)
+	if err != nil {
+		return confError(err)
+	}
+	key, err := conf.DecryptMasterKey(password)
+	if errors.Is(err, gocryptfs.ErrWrongPassword) {
+		return ErrWrongPassword
+	}
+	for i := range key {
+		key[i] = 0
+	}
+	return nil
+}
+
+// runFsck runs `gocryptfs -fsck` with the binary at `cmd` for given check until it finishes or gets cancelled,
+// its final status is returned.
+func (m *VaultManager) runFsck(ctx context.Context, cmd string, job *fsckJob, vault models.Vault, password string, progress func(int)) FsckStatus {
+	fsckLog := logger.With().Int64("vaultId", vault.ID).Str("vaultPath", vault.Path).Logger()
+	job.update(func(status *FsckStatus) bool {
+		status.State = JobRunning
+		status.StartedAt = time.Now().Unix()
+		return true
+	})
+
+	output, outputWriter := io.Pipe()
+	proc := exec.CommandContext(ctx, cmd, "-fsck", "--", vault.Path)
+	proc.Stdin = strings.NewReader(password)
+	proc.Stdout = outputWriter
+	proc.Stderr = outputWriter
+
+	// Problems are reported line by line, the summary comes last
+	var lastLine string
+	parsed := make(chan struct{})
+	go func() {
+		defer close(parsed)
+		scanner := bufio.NewScanner(output)
+		for scanner.Scan() {
+			line := gocryptfs.ParseFsckLine(scanner.Text())
+			if text := strings.TrimSpace(scanner.Text()); text != "" {
+				lastLine = text
+			}
+			if line.Corrupt == "" && line.Summary == "" {
+				continue
+			}
+			job.update(func(status *FsckStatus) bool {
+				if line.Corrupt != "" {
+					status.Corrupt = append(status.Corrupt, line.Corrupt)
+				} else {
+					status.Message = line.Summary
+				}
+				return true
+			})
+			if line.Corrupt != "" {
+				fsckLog.Warn().Str("path", line.Corrupt).Msg("Corrupt file found in vault")
+				m.events.Publish(Event{Type: EventFsckProgress, VaultID: vault.ID, Data: job.snapshot()})
+			}
+		}
+		// Drain the rest, so gocryptfs never blocks on writing
+		_, _ = io.Copy(io.Discard, output)
+	}()
+
+	err := proc.Start()
+	if err == nil {
+		stopProgress := m.watchFsckProgress(job, vault, proc.Process.Pid, progress)
+		err = proc.Wait()
+		stopProgress()
+	}
+	outputWriter.Close()
+	<-parsed
+
+	// Settle final state
+	job.update(func(status *FsckStatus) bool {
+		status.FinishedAt = time.Now().Unix()
+		rc := 0
+		if proc.ProcessState != nil {
+			rc = proc.ProcessState.ExitCode()
+		}
+		switch {
+		case ctx.Err() != nil:
+			status.State = JobCancelled
+			status.Message = ""
+		case err == nil || rc == gocryptfs.ExitCodeFsckErrors:
+			status.State = JobSucceeded
+			status.Progress = 100
+		case rc == 12:
+			status.State = JobFailed
+			status.Message = ErrWrongPassword.Message
+		default:
+			status.State = JobFailed
+			status.Message = lastLine
+			if status.Message == "" {
+				status.Message = err.Error()
+			}
+		}
+		return true
+	})
+	status := job.snapshot()
+	fsckLog.Info().
+		Str("state", status.State).
+		Int("corrupt", len(status.Corrupt)).
+		Str("message", status.Message).
+		Msg("Finished checking vault")
+
+	if err := m.repo.CreateFsckResult(&status.FsckResult, nil); err != nil {
+		fsckLog.Error().Err(err).Msg("Failed to store result of vault check")
+	}
+	job.update(func(s *FsckStatus) bool {
+		s.ID = status.ID
+		return false
+	})
+
+	m.lock.Lock()
+	delete(m.fscks, vault.ID)
+	m.lock.Unlock()
+	m.events.Publish(Event{Type: EventFsckFinished, VaultID: vault.ID, Data: status})
+	return status
+}
+
+// watchFsckProgress estimates progress of a vault check, by comparing how much gocryptfs has read with the vault size.
+// It returns a function to stop watching.
+func (m *VaultManager) watchFsckProgress(job *fsckJob, vault models.Vault, pid int, reportProgress func(int)) func() {
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		total := directorySize(vault.Path)
+		ticker := time.NewTicker(fsckProgressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			read, err := extension.ProcessReadBytes(pid)
+			if err != nil || total <= 0 {
+				continue
+			}
+			progress := int(read * 100 / total)
+			if progress > 99 {
+				progress = 99
+			}
+			if job.update(func(status *FsckStatus) bool {
+				changed := status.Progress != progress
+				status.Progress = progress
+				return changed
+			}) {
+				reportProgress(progress)
+				m.events.Publish(Event{Type: EventFsckProgress, VaultID: vault.ID, Data: job.snapshot()})
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-stopped
+	}
+}
+
+// directorySize sums up sizes of regular files under given directory, unreadable entries are skipped.
+func directorySize(path string) int64 {
+	var size int64
+	_ = filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// CancelFsck cancels the running check of given vault, and waits for it to finish.
+func (m *VaultManager) CancelFsck(vaultId int64) (FsckStatus, error) {
+	m.lock.Lock()
+	fsck, ok := m.fscks[vaultId]
+	m.lock.Unlock()
+	if !ok {
+		return FsckStatus{}, ErrFsckNotRunning
+	}
+	if _, err := m.jobs.Cancel(fsck.job); err != nil {
+		return FsckStatus{}, err
+	}
+	return fsck.snapshot(), nil
+}
+
+// GetFsck returns status of the running check of given vault, or its latest stored result.
+// A nil status is returned if the vault was never checked.
+func (m *VaultManager) GetFsck(vaultId int64) (*FsckStatus, error) {
+	m.lock.Lock()
+	job, ok := m.fscks[vaultId]
+	m.lock.Unlock()
+	if ok {
+		status := job.snapshot()
+		return &status, nil
+	}
+
+	result, err := m.repo.LatestFsckResult(vaultId, nil)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	status := FsckStatus{FsckResult: result, Progress: -1}
+	if result.State == JobSucceeded {
+		status.Progress = 100
+	}
+	return &status, nil
+}
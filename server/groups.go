@@ -0,0 +1,253 @@
+package server
+
+import (
+	"Cloak/models"
+	"database/sql"
+	"sort"
+	"strings"
+)
+
+// GroupInfo represents a group along with IDs of its vaults
+type GroupInfo struct {
+	models.Group
+	Vaults []int64 `json:"vaults"`
+}
+
+// GroupResult is the outcome of unlocking or locking a single vault of a group.
+type GroupResult struct {
+	VaultID int64     `json:"vault"`
+	State   string    `json:"state"` // Legal values: locked/unlocked
+	Error   *ApiError `json:"error,omitempty"`
+}
+
+// ListGroups lists all groups along with their vaults, ordered by name.
+func (m *VaultManager) ListGroups() ([]GroupInfo, error) {
+	groups, err := m.repo.ListGroups(nil)
+	if err != nil {
+		return nil, err
+	}
+	memberships, err := m.repo.ListMemberships(nil)
+	if err != nil {
+		return nil, err
+	}
+	vaults := make(map[int64][]int64)
+	for _, membership := range memberships {
+		vaults[membership.GroupID] = append(vaults[membership.GroupID], membership.VaultID)
+	}
+	infos := make([]GroupInfo, len(groups))
+	for i, group := range groups {
+		infos[i] = GroupInfo{Group: group, Vaults: vaults[group.ID]}
+		if infos[i].Vaults == nil {
+			infos[i].Vaults = []int64{}
+		}
+	}
+	return infos, nil
+}
+
+// SaveGroup creates a group if its ID is 0, or updates it otherwise.
+// An existing group keeps its name if `group.Name` is empty, and its vaults if `vaultIds` is nil.
+func (m *VaultManager) SaveGroup(group models.Group, vaultIds []int64) (GroupInfo, error) {
+	group.Name = strings.TrimSpace(group.Name)
+	if group.Name == "" && group.ID == 0 {
+		return GroupInfo{}, ErrMalformedInput
+	}
+	err := m.repo.WithTransaction(func(tx models.Transactional) error {
+		if group.ID != 0 {
+			existing, err := m.repo.GetGroup(group.ID, tx)
+			if err != nil {
+				if err == sql.ErrNoRows {
+					return ErrGroupNotExist
+				}
+				return err
+			}
+			if group.Name == "" {
+				group.Name = existing.Name
+			}
+		}
+		// Group names are shown to tell groups apart, so they must be unique
+		groups, err := m.repo.ListGroups(tx)
+		if err != nil {
+			return err
+		}
+		for _, g := range groups {
+			if g.Name == group.Name && g.ID != group.ID {
+				return ErrGroupNameTaken
+			}
+		}
+		for _, vaultId := range vaultIds {
+			if _, err := m.repo.Get(vaultId, tx); err != nil {
+				if err == sql.ErrNoRows {
+					return ErrVaultNotExist
+				}
+				return err
+			}
+		}
+
+		if group.ID == 0 {
+			if group, err = m.repo.CreateGroup(group.Name, tx); err != nil {
+				return err
+			}
+		} else if err := m.repo.UpdateGroup(&group, tx); err != nil {
+			return err
+		}
+		if vaultIds != nil {
+			return m.repo.SetGroupVaults(group.ID, vaultIds, tx)
+		}
+		return nil
+	})
+	if err != nil {
+		return GroupInfo{}, err
+	}
+
+	vaults, err := m.groupVaults(group.ID)
+	if err != nil {
+		return GroupInfo{}, err
+	}
+	info := GroupInfo{Group: group, Vaults: vaults}
+	m.events.Publish(Event{Type: EventGroupsChanged, Data: info})
+	return info, nil
+}
+
+// RemoveGroup deletes given group, its vaults are kept.
+func (m *VaultManager) RemoveGroup(groupId int64) error {
+	err := m.repo.WithTransaction(func(tx models.Transactional) error {
+		if _, err := m.repo.GetGroup(groupId, tx); err != nil {
+			if err == sql.ErrNoRows {
+				return ErrGroupNotExist
+			}
+			return err
+		}
+		return m.repo.DeleteGroup(groupId, tx)
+	})
+	if err != nil {
+		return err
+	}
+	m.events.Publish(Event{Type: EventGroupsChanged})
+	return nil
+}
+
+// groupVaults returns IDs of vaults in given group, ErrGroupNotExist is returned if there's no such group.
+func (m *VaultManager) groupVaults(groupId int64) ([]int64, error) {
+	groups, err := m.ListGroups()
+	if err != nil {
+		return nil, err
+	}
+	for _, group := range groups {
+		if group.ID == groupId {
+			return group.Vaults, nil
+		}
+	}
+	return nil, ErrGroupNotExist
+}
+
+// UnlockGroup unlocks all vaults of given group with one password, vaults unlocked already are skipped.
+// The password is tried on each vault in turn, vaults failed to unlock don't stop the rest.
+// With an empty password, each vault is unlocked with its stored password.
+func (m *VaultManager) UnlockGroup(groupId int64, password string) ([]GroupResult, error) {
+	vaultIds, err := m.groupVaults(groupId)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]GroupResult, 0, len(vaultIds))
+	for _, vaultId := range vaultIds {
+		result := GroupResult{VaultID: vaultId, State: "unlocked"}
+		if _, ok := m.MountPoint(vaultId); ok {
+			results = append(results, result)
+			continue
+		}
+		err := m.UnlockVault(vaultId, password)
+		m.Audit(vaultId, AuditUnlock, err)
+		if err != nil {
+			result.State = "locked"
+			result.Error = toApiError(err)
+		}
+		results = append(results, result)
+	}
+	logger.Info().
+		Int64("groupId", groupId).
+		Interface("results", results).
+		Msg("Unlocked vaults of group")
+	return results, nil
+}
+
+// LockGroup locks all vaults of given group, vaults locked already are skipped.
+// If `force` is true, busy vaults are unmounted lazily.
+func (m *VaultManager) LockGroup(groupId int64, force bool) ([]GroupResult, error) {
+	vaultIds, err := m.groupVaults(groupId)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]GroupResult, 0, len(vaultIds))
+	for _, vaultId := range vaultIds {
+		result := GroupResult{VaultID: vaultId, State: "locked"}
+		if _, ok := m.MountPoint(vaultId); !ok {
+			results = append(results, result)
+			continue
+		}
+		err := m.LockVault(vaultId, force)
+		m.Audit(vaultId, AuditLock, err)
+		if err != nil {
+			result.State = "unlocked"
+			result.Error = toApiError(err)
+		}
+		results = append(results, result)
+	}
+	logger.Info().
+		Int64("groupId", groupId).
+		Interface("results", results).
+		Msg("Locked vaults of group")
+	return results, nil
+}
+
+// SetVaultTags replaces tags of given vault, tags are trimmed and deduplicated.
+func (m *VaultManager) SetVaultTags(vaultId int64, tags []string) ([]string, error) {
+	cleaned := make([]string, 0, len(tags))
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		cleaned = append(cleaned, tag)
+	}
+	sort.Strings(cleaned)
+
+	err := m.repo.WithTransaction(func(tx models.Transactional) error {
+		if _, err := m.repo.Get(vaultId, tx); err != nil {
+			if err == sql.ErrNoRows {
+				return ErrVaultNotExist
+			}
+			return err
+		}
+		return m.repo.SetVaultTags(vaultId, cleaned, tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	m.events.Publish(Event{Type: EventGroupsChanged, VaultID: vaultId, Data: cleaned})
+	return cleaned, nil
+}
+
+// vaultGrouping returns groups and tags of each vault, keyed by vault ID.
+func (m *VaultManager) vaultGrouping() (map[int64][]models.Group, map[int64][]string, error) {
+	groups, err := m.ListGroups()
+	if err != nil {
+		return nil, nil, err
+	}
+	vaultGroups := make(map[int64][]models.Group)
+	for _, group := range groups {
+		for _, vaultId := range group.Vaults {
+			vaultGroups[vaultId] = append(vaultGroups[vaultId], group.Group)
+		}
+	}
+	tags, err := m.repo.ListTags(nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	vaultTags := make(map[int64][]string)
+	for _, tag := range tags {
+		vaultTags[tag.VaultID] = append(vaultTags[tag.VaultID], tag.Tag)
+	}
+	return vaultGroups, vaultTags, nil
+}
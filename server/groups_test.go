@@ -0,0 +1,137 @@
+package server
+
+import (
+	"Cloak/models"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/suite"
+)
+
+type groupsTestSuite struct {
+	suite.Suite
+	s       *ApiServer
+	mock    sqlmock.Sqlmock
+	backend *fakeBackend
+	vaults  []models.Vault
+}
+
+func (s *groupsTestSuite) SetupTest() {
+	if runtime.GOOS == "windows" {
+		s.T().Skip("fake backend is a shell script")
+	}
+	m, mock := newTestManager(s.T())
+	s.s, s.mock = &ApiServer{VaultManager: m}, mock
+	s.backend = useFakeBackend(s.T(), m)
+	s.backend.script = "trap 'exit 0' INT; sleep 30 > /dev/null 2>&1 & wait"
+	s.vaults = []models.Vault{fakeVault(s.T(), 1), fakeVault(s.T(), 2), fakeVault(s.T(), 3)}
+	// Vault 2 has another password
+	s.backend.passwords[s.vaults[1].Path] = "other"
+}
+
+func (s *groupsTestSuite) AfterTest(_, _ string) {
+	s.Require().NoError(s.mock.ExpectationsWereMet())
+}
+
+// expectGroup expects the group of all vaults to be looked up.
+func (s *groupsTestSuite) expectGroup() {
+	s.mock.ExpectQuery(`SELECT \* FROM groups`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "work"))
+	memberships := sqlmock.NewRows([]string{"groupid", "vaultid"})
+	for _, vault := range s.vaults {
+		memberships.AddRow(1, vault.ID)
+	}
+	s.mock.ExpectQuery(`SELECT groupid, vaultid FROM vault_groups`).WillReturnRows(memberships)
+}
+
+// expectVault expects given vault to be looked up.
+func (s *groupsTestSuite) expectVault(vault models.Vault) {
+	s.mock.ExpectQuery(`SELECT \* FROM vaults WHERE id = \?`).WithArgs(vault.ID).WillReturnRows(vaultRows(vault))
+}
+
+// expectAudit expects an operation on given vault to be recorded.
+func (s *groupsTestSuite) expectAudit(vaultId int64, operation string, outcome string, code int, rc int) {
+	s.mock.ExpectExec(`INSERT INTO events(.+)`).
+		WithArgs(vaultId, operation, outcome, code, rc, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+}
+
+// results operates on the group, and returns results of its vaults.
+func (s *groupsTestSuite) results(body string) []GroupResult {
+	err := call(s.s.OperateOnGroup, "1", body)
+	s.Require().Equal(ErrOk.Code, toApiError(err).Code, "%v", err)
+	return err.(*DataContainer).Items.([]GroupResult)
+}
+
+func (s *groupsTestSuite) Test_01_Unlock() {
+	// Vault 3 was unlocked by a previous run
+	s.s.mountPoints[3] = s.vaults[2].MountPoint
+
+	s.expectGroup()
+	s.expectVault(s.vaults[0])
+	s.expectVault(s.vaults[0])
+	s.expectAudit(1, AuditUnlock, JobSucceeded, 0, 0)
+	s.expectVault(s.vaults[1])
+	s.expectVault(s.vaults[1])
+	s.expectAudit(2, AuditUnlock, JobFailed, ErrWrongPassword.Code, fakeExitPassword)
+	results := s.results(`{"op": "unlock", "password": "secret"}`)
+
+	// The wrong password for vault 2 doesn't stop the rest
+	s.Require().Len(results, 3)
+	s.Equal(GroupResult{VaultID: 1, State: "unlocked"}, results[0])
+	s.EqualValues(2, results[1].VaultID)
+	s.Equal("locked", results[1].State)
+	s.Require().NotNil(results[1].Error)
+	s.Equal(ErrWrongPassword.Code, results[1].Error.Code)
+	s.Equal(GroupResult{VaultID: 3, State: "unlocked"}, results[2])
+	// The backend of vault 2 is cleaned up right after it exited
+	s.Eventually(func() bool {
+		_, unlocked := s.s.MountPoint(2)
+		return !unlocked
+	}, time.Second, time.Millisecond*10)
+	for _, vaultId := range []int64{1, 3} {
+		_, unlocked := s.s.MountPoint(vaultId)
+		s.True(unlocked, vaultId)
+	}
+}
+
+func (s *groupsTestSuite) Test_02_Lock() {
+	s.expectVault(s.vaults[0])
+	s.Require().NoError(s.s.MountVault(1, "secret"))
+	// Vault 3 was unlocked by a previous run, and it can't be unmounted
+	s.s.mountPoints[3] = s.vaults[2].MountPoint
+
+	s.expectGroup()
+	s.expectVault(s.vaults[0])
+	s.expectAudit(1, AuditLock, JobSucceeded, 0, 0)
+	s.expectVault(s.vaults[2])
+	s.expectAudit(3, AuditLock, JobFailed, ErrUnknown.Code, 0)
+	results := s.results(`{"op": "lock"}`)
+
+	s.Require().Len(results, 3)
+	s.Equal(GroupResult{VaultID: 1, State: "locked"}, results[0])
+	s.Equal(GroupResult{VaultID: 2, State: "locked"}, results[1])
+	s.EqualValues(3, results[2].VaultID)
+	s.Equal("unlocked", results[2].State)
+	s.Require().NotNil(results[2].Error)
+	s.Equal(ErrUnknown.Code, results[2].Error.Code)
+	for vaultId, expected := range map[int64]bool{1: false, 2: false, 3: true} {
+		_, unlocked := s.s.MountPoint(vaultId)
+		s.Equal(expected, unlocked, vaultId)
+	}
+}
+
+func (s *groupsTestSuite) Test_03_Rejected() {
+	s.Equal(ErrMalformedInput.Code, toApiError(call(s.s.OperateOnGroup, "work", `{"op": "lock"}`)).Code)
+	s.Equal(ErrUnsupportedOperation.Code, toApiError(call(s.s.OperateOnGroup, "1", `{"op": "reveal"}`)).Code)
+
+	s.mock.ExpectQuery(`SELECT \* FROM groups`).WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+	s.mock.ExpectQuery(`SELECT groupid, vaultid FROM vault_groups`).WillReturnRows(sqlmock.NewRows([]string{"groupid", "vaultid"}))
+	s.Equal(ErrGroupNotExist.Code, toApiError(call(s.s.OperateOnGroup, "1", `{"op": "unlock", "password": "secret"}`)).Code)
+}
+
+func Test_Groups(t *testing.T) {
+	suite.Run(t, new(groupsTestSuite))
+}
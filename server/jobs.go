@@ -0,0 +1,290 @@
+package server
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// States of a job
+const (
+	JobQueued    = "queued"    // waiting for a free slot
+	JobRunning   = "running"   // started
+	JobSucceeded = "succeeded" // finished without error, `Result` is set
+	JobFailed    = "failed"    // finished with an error, `Error` is set
+	JobCancelled = "cancelled" // cancelled before it finished
+)
+
+const (
+	maxRunningJobs  = 2  // jobs are CPU or IO heavy, running too many of them at once helps nothing
+	maxFinishedJobs = 50 // finished jobs kept for viewing later, older ones are dropped
+)
+
+// Job is a long-running operation, it runs in background and can be cancelled.
+type Job struct {
+	ID         int64       `json:"id"`
+	Kind       string      `json:"kind"` // operation, e.g. `create`, `fsck`
+	VaultID    int64       `json:"vault,omitempty"`
+	State      string      `json:"state"`
+	Progress   int         `json:"progress"` // percentage, -1 if unknown
+	CreatedAt  time.Time   `json:"createdat"`
+	StartedAt  *time.Time  `json:"startedat,omitempty"`
+	FinishedAt *time.Time  `json:"finishedat,omitempty"`
+	Result     interface{} `json:"result,omitempty"` // what the operation responds when it runs synchronously
+	Error      *ApiError   `json:"error,omitempty"`
+}
+
+// JobFunc does the work of a job. It should stop as soon as `ctx` is done,
+// and it might report progress in percentage through `progress`.
+type JobFunc func(ctx context.Context, progress func(percent int)) (interface{}, error)
+
+// jobEntry is a job along with its control handles.
+type jobEntry struct {
+	job     Job
+	cancel  context.CancelFunc
+	cleanup func()        // optional, runs after the job finished
+	done    chan struct{} // closed after the job finished and got cleaned up
+}
+
+// JobManager runs jobs in background, with limited concurrency.
+// Changes of jobs are published as `EventJobUpdated` events.
+type JobManager struct {
+	lock   sync.Mutex
+	jobs   map[int64]*jobEntry
+	nextId int64
+	slots  chan struct{}
+	events *EventBus
+}
+
+// NewJobManager creates a new JobManager instance.
+func NewJobManager(events *EventBus) *JobManager {
+	return &JobManager{
+		jobs:   make(map[int64]*jobEntry),
+		slots:  make(chan struct{}, maxRunningJobs),
+		events: events,
+	}
+}
+
+// Submit queues a job of given kind, `vaultId` might be 0 for jobs not bound to a vault.
+// The job starts as soon as there is a free slot.
+func (m *JobManager) Submit(kind string, vaultId int64, f JobFunc) Job {
+	return m.SubmitWithCleanup(kind, vaultId, f, nil)
+}
+
+// SubmitWithCleanup is like `Submit`, `cleanup` runs after the job finished,
+// even if it got cancelled while queued. The job is not reported finished by `Wait` until `cleanup` returns.
+func (m *JobManager) SubmitWithCleanup(kind string, vaultId int64, f JobFunc, cleanup func()) Job {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	m.lock.Lock()
+	m.nextId++
+	entry := &jobEntry{
+		job: Job{
+			ID:        m.nextId,
+			Kind:      kind,
+			VaultID:   vaultId,
+			State:     JobQueued,
+			Progress:  -1,
+			CreatedAt: time.Now().UTC(),
+		},
+		cancel:  cancel,
+		cleanup: cleanup,
+		done:    make(chan struct{}),
+	}
+	m.jobs[entry.job.ID] = entry
+	m.prune()
+	job := entry.job
+	m.lock.Unlock()
+
+	logger.Debug().
+		Int64("jobId", job.ID).
+		Str("kind", kind).
+		Int64("vaultId", vaultId).
+		Msg("Job queued")
+	m.publish(job)
+	go m.run(ctx, entry, f)
+	return job
+}
+
+// run waits for a free slot, then runs the job.
+func (m *JobManager) run(ctx context.Context, entry *jobEntry, f JobFunc) {
+	defer close(entry.done)
+	if entry.cleanup != nil {
+		defer entry.cleanup()
+	}
+	defer entry.cancel()
+
+	select {
+	case m.slots <- struct{}{}:
+		defer func() { <-m.slots }()
+	case <-ctx.Done():
+		m.finish(entry, nil, ctx.Err(), ctx)
+		return
+	}
+
+	m.update(entry, func(job *Job) bool {
+		now := time.Now().UTC()
+		job.State = JobRunning
+		job.StartedAt = &now
+		return true
+	})
+	result, err := f(ctx, func(percent int) {
+		m.update(entry, func(job *Job) bool {
+			if job.Progress == percent {
+				return false
+			}
+			job.Progress = percent
+			return true
+		})
+	})
+	m.finish(entry, result, err, ctx)
+}
+
+// finish settles the final state of a job.
+func (m *JobManager) finish(entry *jobEntry, result interface{}, err error, ctx context.Context) {
+	m.update(entry, func(job *Job) bool {
+		now := time.Now().UTC()
+		job.FinishedAt = &now
+		switch {
+		case ctx.Err() != nil:
+			job.State = JobCancelled
+		case err != nil:
+			job.State = JobFailed
+			job.Error = toApiError(err)
+		default:
+			job.State = JobSucceeded
+			job.Progress = 100
+			job.Result = result
+		}
+		return true
+	})
+	job, _ := m.Get(entry.job.ID)
+	logger.Debug().
+		Int64("jobId", job.ID).
+		Str("kind", job.Kind).
+		Str("state", job.State).
+		Msg("Job finished")
+}
+
+// update modifies a job with `f` and publishes the change, unless `f` reports nothing changed.
+func (m *JobManager) update(entry *jobEntry, f func(job *Job) bool) {
+	m.lock.Lock()
+	changed := f(&entry.job)
+	job := entry.job
+	m.lock.Unlock()
+	if changed {
+		m.publish(job)
+	}
+}
+
+func (m *JobManager) publish(job Job) {
+	m.events.Publish(Event{Type: EventJobUpdated, VaultID: job.VaultID, Data: job})
+}
+
+// prune drops the oldest finished jobs beyond `maxFinishedJobs`, `m.lock` must be held.
+func (m *JobManager) prune() {
+	var finished []int64
+	for id, entry := range m.jobs {
+		if entry.job.FinishedAt != nil {
+			finished = append(finished, id)
+		}
+	}
+	if len(finished) <= maxFinishedJobs {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool { return finished[i] < finished[j] })
+	for _, id := range finished[:len(finished)-maxFinishedJobs] {
+		delete(m.jobs, id)
+	}
+}
+
+// Get returns the job of given ID.
+func (m *JobManager) Get(id int64) (Job, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	entry, ok := m.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return entry.job, true
+}
+
+// List returns all known jobs, the latest first.
+func (m *JobManager) List() []Job {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	jobs := make([]Job, 0, len(m.jobs))
+	for _, entry := range m.jobs {
+		jobs = append(jobs, entry.job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID > jobs[j].ID })
+	return jobs
+}
+
+// Wait waits for given job to finish, and returns its final state.
+func (m *JobManager) Wait(id int64) (Job, error) {
+	m.lock.Lock()
+	entry, ok := m.jobs[id]
+	m.lock.Unlock()
+	if !ok {
+		return Job{}, ErrJobNotExist
+	}
+	<-entry.done
+	job, _ := m.Get(id)
+	return job, nil
+}
+
+// Cancel cancels given job and waits for it to stop.
+// It's not an error to cancel a finished job, nothing happens then.
+func (m *JobManager) Cancel(id int64) (Job, error) {
+	m.lock.Lock()
+	entry, ok := m.jobs[id]
+	m.lock.Unlock()
+	if !ok {
+		return Job{}, ErrJobNotExist
+	}
+	entry.cancel()
+	<-entry.done
+	job, _ := m.Get(id)
+	return job, nil
+}
+
+// CancelAll cancels all unfinished jobs and waits for them to stop.
+func (m *JobManager) CancelAll() {
+	m.cancelWhere(func(*Job) bool { return true })
+}
+
+// CancelVault cancels unfinished jobs bound to given vault and waits for them to stop.
+func (m *JobManager) CancelVault(vaultId int64) {
+	m.cancelWhere(func(job *Job) bool { return job.VaultID == vaultId })
+}
+
+// cancelWhere cancels unfinished jobs matching `match` and waits for them to stop.
+func (m *JobManager) cancelWhere(match func(job *Job) bool) {
+	m.lock.Lock()
+	entries := make([]*jobEntry, 0, len(m.jobs))
+	for _, entry := range m.jobs {
+		// Finished jobs might still be cleaning up, waiting for them is harmless
+		if match(&entry.job) {
+			entries = append(entries, entry)
+		}
+	}
+	m.lock.Unlock()
+	for _, entry := range entries {
+		entry.cancel()
+		<-entry.done
+	}
+}
+
+// toApiError converts errors returned by jobs to ApiError, so they are reported just like synchronous operations.
+func toApiError(err error) *ApiError {
+	switch e := err.(type) {
+	case *ApiError:
+		return e
+	case *DataContainer:
+		return e.ApiError
+	default:
+		return ErrUnknown.Reformat(err)
+	}
+}
@@ -0,0 +1,217 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type jobsTestSuite struct {
+	suite.Suite
+	jobs *JobManager
+}
+
+func (s *jobsTestSuite) SetupTest() {
+	s.jobs = NewJobManager(NewEventBus())
+}
+
+func (s *jobsTestSuite) TearDownTest() {
+	s.jobs.CancelAll()
+}
+
+// blocking returns a job which runs until `release` is closed or it gets cancelled, `started` counts its runs.
+func blocking(release chan struct{}, started *int32) JobFunc {
+	return func(ctx context.Context, _ func(int)) (interface{}, error) {
+		atomic.AddInt32(started, 1)
+		select {
+		case <-release:
+			return "done", nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// waitState waits for given job to reach `state`.
+func (s *jobsTestSuite) waitState(id int64, state string) {
+	s.Require().Eventually(func() bool {
+		job, _ := s.jobs.Get(id)
+		return job.State == state
+	}, time.Second, time.Millisecond, "job %d never got %s", id, state)
+}
+
+func (s *jobsTestSuite) Test_01_Slots() {
+	release := make(chan struct{})
+	var started int32
+	var ids []int64
+	for i := 0; i < maxRunningJobs+1; i++ {
+		ids = append(ids, s.jobs.Submit("fsck", int64(i+1), blocking(release, &started)).ID)
+	}
+	// Jobs take free slots in no particular order, one of them has to wait
+	s.Require().Eventually(func() bool {
+		return atomic.LoadInt32(&started) == maxRunningJobs
+	}, time.Second, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	states := make(map[string]int)
+	for _, job := range s.jobs.List() {
+		states[job.State]++
+	}
+	s.Equal(map[string]int{JobRunning: maxRunningJobs, JobQueued: 1}, states)
+	s.EqualValues(maxRunningJobs, atomic.LoadInt32(&started))
+
+	close(release)
+	for _, id := range ids {
+		job, err := s.jobs.Wait(id)
+		s.Require().NoError(err)
+		s.Equal(JobSucceeded, job.State)
+		s.Equal("done", job.Result)
+		s.Equal(100, job.Progress)
+	}
+	s.EqualValues(maxRunningJobs+1, atomic.LoadInt32(&started))
+}
+
+func (s *jobsTestSuite) Test_02_CancelQueued() {
+	release := make(chan struct{})
+	defer close(release)
+	var started int32
+	for i := 0; i < maxRunningJobs; i++ {
+		s.waitState(s.jobs.Submit("fsck", 1, blocking(release, &started)).ID, JobRunning)
+	}
+
+	// A queued job never runs once cancelled, but it's cleaned up all the same
+	var cleaned int32
+	queued := s.jobs.SubmitWithCleanup("password", 2, blocking(release, &started), func() {
+		atomic.AddInt32(&cleaned, 1)
+	})
+	job, err := s.jobs.Cancel(queued.ID)
+	s.Require().NoError(err)
+	s.Equal(JobCancelled, job.State)
+	s.NotNil(job.FinishedAt)
+	s.Nil(job.StartedAt)
+	s.EqualValues(maxRunningJobs, atomic.LoadInt32(&started))
+	s.EqualValues(1, atomic.LoadInt32(&cleaned))
+
+	// Cancelling again changes nothing
+	job, err = s.jobs.Cancel(queued.ID)
+	s.Require().NoError(err)
+	s.Equal(JobCancelled, job.State)
+	s.EqualValues(1, atomic.LoadInt32(&cleaned))
+
+	_, err = s.jobs.Cancel(12345)
+	s.ErrorIs(err, ErrJobNotExist)
+}
+
+func (s *jobsTestSuite) Test_03_CancelVault() {
+	release := make(chan struct{})
+	var started int32
+	var cleaned int32
+	cleanup := func() { atomic.AddInt32(&cleaned, 1) }
+	first := s.jobs.SubmitWithCleanup("password", 1, blocking(release, &started), cleanup)
+	other := s.jobs.SubmitWithCleanup("upgrade_kdf", 2, blocking(release, &started), cleanup)
+	s.waitState(first.ID, JobRunning)
+	s.waitState(other.ID, JobRunning)
+	queued := s.jobs.SubmitWithCleanup("fsck", 1, blocking(release, &started), cleanup)
+
+	// Jobs of other vaults keep running, the vault is cleaned up before `CancelVault` returns
+	s.jobs.CancelVault(1)
+	s.EqualValues(2, atomic.LoadInt32(&cleaned))
+	for _, id := range []int64{first.ID, queued.ID} {
+		job, _ := s.jobs.Get(id)
+		s.Equal(JobCancelled, job.State)
+	}
+	job, _ := s.jobs.Get(other.ID)
+	s.Equal(JobRunning, job.State)
+
+	close(release)
+	job, err := s.jobs.Wait(other.ID)
+	s.Require().NoError(err)
+	s.Equal(JobSucceeded, job.State)
+	s.EqualValues(3, atomic.LoadInt32(&cleaned))
+}
+
+func (s *jobsTestSuite) Test_04_Wait() {
+	failed := s.jobs.Submit("password", 1, func(context.Context, func(int)) (interface{}, error) {
+		return nil, ErrWrongPassword
+	})
+	job, err := s.jobs.Wait(failed.ID)
+	s.Require().NoError(err)
+	s.Equal(JobFailed, job.State)
+	s.Equal(ErrWrongPassword, job.Error)
+
+	// Other errors are reported as unknown ones, along with the backend exit code if any
+	wrapped := s.jobs.Submit("create", 0, func(context.Context, func(int)) (interface{}, error) {
+		return nil, ErrUnknown.Reformat("boom").WrapRC(7)
+	})
+	job, err = s.jobs.Wait(wrapped.ID)
+	s.Require().NoError(err)
+	s.Equal(ErrUnknown.Code, job.Error.Code)
+	plain := s.jobs.Submit("create", 0, func(context.Context, func(int)) (interface{}, error) {
+		return nil, errors.New("boom")
+	})
+	job, err = s.jobs.Wait(plain.ID)
+	s.Require().NoError(err)
+	s.Equal(ErrUnknown.Code, job.Error.Code)
+	s.Contains(job.Error.Message, "boom")
+
+	progressed := s.jobs.Submit("fsck", 1, func(_ context.Context, progress func(int)) (interface{}, error) {
+		progress(50)
+		return 42, nil
+	})
+	job, err = s.jobs.Wait(progressed.ID)
+	s.Require().NoError(err)
+	s.Equal(JobSucceeded, job.State)
+	s.Equal(42, job.Result)
+	s.NotNil(job.StartedAt)
+
+	_, err = s.jobs.Wait(12345)
+	s.ErrorIs(err, ErrJobNotExist)
+}
+
+func (s *jobsTestSuite) Test_05_Prune() {
+	finish := func(context.Context, func(int)) (interface{}, error) { return nil, nil }
+	var ids []int64
+	for i := 0; i < maxFinishedJobs+5; i++ {
+		id := s.jobs.Submit("create", 0, finish).ID
+		_, err := s.jobs.Wait(id)
+		s.Require().NoError(err)
+		ids = append(ids, id)
+	}
+
+	// Finished jobs are pruned when another one gets submitted, the oldest go first
+	release := make(chan struct{})
+	defer close(release)
+	var started int32
+	latest := s.jobs.Submit("fsck", 1, blocking(release, &started))
+	jobs := s.jobs.List()
+	s.Len(jobs, maxFinishedJobs+1)
+	s.Equal(latest.ID, jobs[0].ID)
+	s.Equal(ids[5], jobs[len(jobs)-1].ID)
+	for _, id := range ids[:5] {
+		_, ok := s.jobs.Get(id)
+		s.False(ok)
+	}
+}
+
+func (s *jobsTestSuite) Test_06_CancelAll() {
+	release := make(chan struct{})
+	defer close(release)
+	var started int32
+	var ids []int64
+	for i := 0; i < maxRunningJobs+2; i++ {
+		ids = append(ids, s.jobs.Submit("fsck", int64(i+1), blocking(release, &started)).ID)
+	}
+	s.jobs.CancelAll()
+	for _, id := range ids {
+		job, _ := s.jobs.Get(id)
+		s.Equal(JobCancelled, job.State)
+		s.NotNil(job.FinishedAt)
+	}
+}
+
+func Test_Jobs(t *testing.T) {
+	suite.Run(t, new(jobsTestSuite))
+}
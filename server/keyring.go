@@ -0,0 +1,122 @@
+package server
+
+import (
+	"Cloak/extension"
+	"Cloak/models"
+	"database/sql"
+	"errors"
+)
+
+// UnlockVault unlocks given vault with `password`, or with its password stored in system keyring if `password` is empty.
+// For vaults with `RememberPassword` option on, the password is stored in system keyring once the vault gets unlocked.
+// Failures are published as events, except for vaults unlocked already and wrong passwords typed by users,
+// who are told right away.
+func (m *VaultManager) UnlockVault(vaultId int64, password string) error {
+	err := m.unlockVault(vaultId, password)
+	if err != nil && err != ErrVaultAlreadyUnlocked && !(password != "" && isWrongPassword(err)) {
+		event := Event{Type: EventVaultUnlockFailed, VaultID: vaultId, Data: toApiError(err)}
+		if container, ok := err.(*DataContainer); ok {
+			event.RC = container.RC
+		}
+		m.events.Publish(event)
+	}
+	return err
+}
+
+func (m *VaultManager) unlockVault(vaultId int64, password string) error {
+	m.lock.Lock()
+	_, unlocked := m.mountPoints[vaultId]
+	m.lock.Unlock()
+	if unlocked {
+		return ErrVaultAlreadyUnlocked
+	}
+
+	vault, err := m.repo.Get(vaultId, nil)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrVaultNotExist
+		}
+		return err
+	}
+
+	stored := password == ""
+	if password, err = storedPassword(vault, password); err != nil {
+		return err
+	}
+
+	err = m.MountVault(vaultId, password)
+	if stored {
+		// The vault password was changed elsewhere, the stored one is useless
+		if isWrongPassword(err) {
+			logger.Warn().
+				Int64("vaultId", vaultId).
+				Str("vaultPath", vault.Path).
+				Msg("Stored password is incorrect, forgetting it")
+			if err := extension.ForgetPassword(vault.ID); err != nil {
+				logger.Error().Err(err).Int64("vaultId", vaultId).Msg("Failed to forget stored password")
+			}
+		}
+		return err
+	}
+	if err == nil {
+		m.rememberPassword(vault, password)
+	}
+	return err
+}
+
+// storedPassword returns `password` as is, or the password of given vault stored in system keyring if it's empty.
+func storedPassword(vault models.Vault, password string) (string, error) {
+	if password != "" {
+		return password, nil
+	}
+	if !vault.RememberPassword {
+		return "", ErrPasswordNotStored
+	}
+	password, err := extension.LookupPassword(vault.ID)
+	if err != nil {
+		return "", keyringError(err)
+	}
+	return password, nil
+}
+
+// rememberPassword stores vault password in system keyring, if it's enabled for the vault.
+// Failures are logged only, since the vault itself is fine.
+func (m *VaultManager) rememberPassword(vault models.Vault, password string) {
+	if !vault.RememberPassword {
+		return
+	}
+	if err := extension.StorePassword(vault.ID, vault.Path, password); err != nil {
+		logger.Error().Err(err).
+			Int64("vaultId", vault.ID).
+			Str("vaultPath", vault.Path).
+			Msg("Failed to store vault password in keyring")
+	}
+}
+
+// ForgetPassword deletes the stored password of given vault from system keyring.
+func (m *VaultManager) ForgetPassword(vault models.Vault) error {
+	if err := extension.ForgetPassword(vault.ID); err != nil {
+		logger.Error().Err(err).
+			Int64("vaultId", vault.ID).
+			Str("vaultPath", vault.Path).
+			Msg("Failed to forget vault password in keyring")
+		return keyringError(err)
+	}
+	return nil
+}
+
+// keyringError converts errors from system keyring to ApiError.
+func keyringError(err error) *ApiError {
+	if errors.Is(err, extension.ErrSecretNotFound) {
+		return ErrPasswordNotStored
+	}
+	return ErrKeyringUnavailable.Reformat(err)
+}
+
+// isWrongPassword tells whether an error returned by unlocking is `ErrWrongPassword`.
+func isWrongPassword(err error) bool {
+	if container, ok := err.(*DataContainer); ok {
+		return container.ApiError == ErrWrongPassword
+	}
+	return err == ErrWrongPassword
+}
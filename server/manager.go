@@ -1,10 +1,14 @@
 package server
 
 import (
+	"Cloak/backend"
+	"Cloak/config"
 	"Cloak/extension"
+	"Cloak/gocryptfs"
 	"Cloak/models"
-	"bytes"
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"io"
 	"math/rand"
@@ -19,10 +23,10 @@ import (
 )
 
 /*
-	This file contains (hopefully) all the necessary utility functions to interact with gocryptfs tool.
+	This file contains (hopefully) all the necessary utility functions to interact with vault backends, e.g. gocryptfs.
 	All the utility functions:
 	  - return ApiError if possible;
-	  - do nothing but running gocryptfs process and logging;
+	  - do nothing but running backend processes and logging;
 */
 
 // Vault represents a single vault, as available to the frontend store.
@@ -37,33 +41,38 @@ type Vault struct {
 
 // VaultManager is the main server type exposed to Wails frontend, for managing all vaults.
 type VaultManager struct {
-	repo          *models.VaultRepo      // database repository
-	cmd           string                 // `gocryptfs` binary path
-	xrayCmd       string                 // `gocryptfs-xray` binary path
-	fuseAvailable bool                   // whether FUSE is available
-	processes     map[int64]*exec.Cmd    // vaultID: process
-	mountPoints   map[int64]string       // vaultID: mountPoint
-	lock          sync.Mutex             // lock on `processes` and `mountPoints`
-	configCh      chan map[string]string // channel for notifying config change requests
+	repo          *models.VaultRepo          // database repository
+	config        *config.Configurator       // app config, changes should be requested through `configCh`
+	backends      map[string]backend.Backend // backend type: located backend
+	fuseAvailable bool                       // whether FUSE is available
+	processes     map[int64]*exec.Cmd        // vaultID: process
+	exited        map[int64]chan struct{}    // vaultID: closed after the process exited and got cleaned up
+	mountPoints   map[int64]string           // vaultID: mountPoint
+	lock          sync.Mutex                 // lock on `processes` and `mountPoints`
+	configCh      chan map[string]string     // channel for notifying config change requests
+	events        *EventBus                  // vault state changes are published here
+	autoLocks     map[int64]*autoLock        // vaultID: auto-lock timer for max unlock lifetime
+	locking       map[int64]bool             // vaultID: being locked by `LockVault`, so its backend exiting is expected
+	fscks         map[int64]*fsckJob         // vaultID: running vault check
+	busy          map[int64]string           // vaultID: kind of the operation reserving the vault, e.g. `password`
+	jobs          *JobManager                // long-running operations in background
+	outputs       map[int64]*outputBuffer    // vaultID: output of the latest backend process unlocking the vault
+
+	mountPointRecords *mountPointRegistry // mountpoint directories we created
+}
+
+// autoLock locks a vault when its max unlock lifetime is reached.
+type autoLock struct {
+	timer    *time.Timer
+	deadline time.Time
 }
 
 // Init init current manager instance.
 func (m *VaultManager) Init() error {
 	// Detect external runtime dependencies
-	var err error
-	if m.cmd, err = extension.LocateBinary("gocryptfs"); err != nil {
-		logger.Error().Err(err).
-			Msg("Failed to locate gocryptfs binary, nothing will work")
-		return err
-	} else {
-		logger.Debug().Str("gocryptfs", m.cmd).Msg("Gocryptfs binary located")
-	}
-	if m.xrayCmd, err = extension.LocateBinary("gocryptfs-xray"); err != nil {
-		logger.Error().Err(err).
-			Msg("Failed to locate gocryptfs-xray binary, masterkey revealing will not work")
-		return err
-	} else {
-		logger.Debug().Str("gocryptfs-xray", m.xrayCmd).Msg("gocryptfs-xray binary located")
+	m.locateBackends()
+	if len(m.backends) == 0 {
+		return ErrMissingGocryptfsBinary
 	}
 	logger.Debug().Bool("fuseAvailable", m.fuseAvailable).Msg("FUSE detection finished")
 
@@ -73,255 +82,619 @@ func (m *VaultManager) Init() error {
 	return nil
 }
 
-func NewVaultManager(repo *models.VaultRepo, releaseMode bool, configCh chan map[string]string) *VaultManager {
+// locateBackends locates binaries of all backends, backends not installed are skipped.
+func (m *VaultManager) locateBackends() {
+	for _, kind := range backend.Names {
+		b, err := backend.Locate(kind)
+		if err != nil {
+			logger.Debug().Err(err).Str("backend", kind).Msg("Backend binary not located, vaults of this type won't work")
+			continue
+		}
+		logger.Debug().Str("backend", kind).Str("binary", b.Binary()).Msg("Backend binary located")
+		m.backends[kind] = b
+	}
+	if _, ok := m.backends[backend.Gocryptfs]; !ok {
+		logger.Error().Msg("Failed to locate gocryptfs binary, only vaults of other backends will work")
+	}
+}
+
+// backend returns the located backend of given type.
+func (m *VaultManager) backend(kind string) (backend.Backend, error) {
+	if kind == "" {
+		kind = backend.Gocryptfs
+	}
+	if !backend.IsKnown(kind) {
+		return nil, ErrUnknownBackend.Reformat(kind)
+	}
+	b, ok := m.backends[kind]
+	if !ok {
+		return nil, ErrBackendUnavailable.Reformat(kind)
+	}
+	return b, nil
+}
+
+// checkVaultIdle makes sure no operation is running on given vault, which mounts it or rewrites its config.
+// `m.lock` must be held by the caller.
+func (m *VaultManager) checkVaultIdle(vaultId int64) error {
+	if job, ok := m.fscks[vaultId]; ok {
+		return ErrFsckRunning.WrapItem(job.snapshot())
+	}
+	if kind, ok := m.busy[vaultId]; ok {
+		return ErrVaultOperationRunning.Reformat(kind)
+	}
+	return nil
+}
+
+// reserveVault marks given vault busy with an operation of `kind`, so that it can't be unlocked,
+// removed or changed by other operations meanwhile. `m.lock` must be held by the caller,
+// and `releaseVault` must be called once the operation finished.
+func (m *VaultManager) reserveVault(vaultId int64, kind string) error {
+	if err := m.checkVaultIdle(vaultId); err != nil {
+		return err
+	}
+	m.busy[vaultId] = kind
+	return nil
+}
+
+// releaseVault marks given vault idle again, after the operation reserving it finished.
+func (m *VaultManager) releaseVault(vaultId int64) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.busy, vaultId)
+}
+
+// gocryptfs returns the gocryptfs backend, for features only gocryptfs supports.
+func (m *VaultManager) gocryptfs() (*backend.GocryptfsBackend, error) {
+	b, err := m.backend(backend.Gocryptfs)
+	if err != nil {
+		return nil, err
+	}
+	return b.(*backend.GocryptfsBackend), nil
+}
+
+// backendError converts errors of backends into ApiError.
+// `confWriteErr` is returned for failing to write the vault config, which depends on the operation.
+func backendError(kind string, err error, confWriteErr *ApiError) *ApiError {
+	switch {
+	case errors.Is(err, backend.ErrDirNotEmpty):
+		return ErrVaultDirNotEmpty
+	case errors.Is(err, backend.ErrMountpointNotEmpty):
+		return ErrMountpointNotEmpty
+	case errors.Is(err, backend.ErrPasswordEmpty):
+		return ErrVaultPasswordEmpty
+	case errors.Is(err, backend.ErrWrongPassword):
+		return ErrWrongPassword
+	case errors.Is(err, backend.ErrConfRead):
+		return ErrCantOpenVaultConf
+	case errors.Is(err, backend.ErrConfWrite):
+		return confWriteErr
+	case errors.Is(err, backend.ErrUnsupported):
+		return ErrUnsupportedOperation
+	case errors.Is(err, backend.ErrUnknownBackend):
+		return ErrUnknownBackend.Reformat(kind)
+	}
+	var exitErr *backend.ExitError
+	if errors.As(err, &exitErr) {
+		return ErrUnknown.Reformat(strings.TrimSpace(exitErr.Output))
+	}
+	return ErrUnknown.Reformat(err)
+}
+
+// exitCode returns exit code of the failed backend process, or 0 if the error is not about one.
+func exitCode(err error) int {
+	var exitErr *backend.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.RC
+	}
+	return 0
+}
+
+// requireGocryptfs makes sure given vault is a gocryptfs one, for features only gocryptfs supports.
+func requireGocryptfs(vault models.Vault, feature string) error {
+	if vault.Backend != "" && vault.Backend != backend.Gocryptfs {
+		return ErrUnsupportedByBackend.Reformat(vault.Backend, feature)
+	}
+	return nil
+}
+
+func NewVaultManager(repo *models.VaultRepo, cfg *config.Configurator, releaseMode bool, configCh chan map[string]string) *VaultManager {
+	events := NewEventBus()
 	// Create manager
 	return &VaultManager{
 		repo:          repo,
+		config:        cfg,
 		fuseAvailable: extension.IsFuseAvailable(),
+		backends:      make(map[string]backend.Backend),
 		processes:     make(map[int64]*exec.Cmd),
+		exited:        make(map[int64]chan struct{}),
 		mountPoints:   make(map[int64]string),
 		configCh:      configCh,
+		events:        events,
+		autoLocks:     make(map[int64]*autoLock),
+		locking:       make(map[int64]bool),
+		fscks:         make(map[int64]*fsckJob),
+		busy:          make(map[int64]string),
+		jobs:          NewJobManager(events),
+		outputs:       make(map[int64]*outputBuffer),
+
+		mountPointRecords: newMountPointRegistry(),
 	}
 }
 
-// GocryptfsCreateVault creates a new vault at `path` with `password`.
-func (m *VaultManager) GocryptfsCreateVault(path string, password string) error {
-	// Start a gocryptfs process to init this vault
-	initProc := exec.Command(m.cmd, "-init", "--", path)
-	// Password is piped through STDIN
-	stdIn, err := initProc.StdinPipe()
-	var errorOutput bytes.Buffer
-	initProc.Stderr = &errorOutput
-	if err != nil {
-		logger.Error().Err(err).
-			Str("vaultPath", path).
-			Msg("Failed to create STDIN pipe when initializing new vault")
-		return err
+// Subscribe subscribes to vault events, see `EventBus.Subscribe`.
+func (m *VaultManager) Subscribe() (<-chan Event, func()) {
+	return m.events.Subscribe()
+}
+
+// ConfigChanged tells subscribers that config changes requested through `configCh` got applied.
+func (m *VaultManager) ConfigChanged(changes map[string]string) {
+	m.events.Publish(Event{Type: EventConfigChanged, Data: changes})
+}
+
+var (
+	// lockTimeout is how long we wait for the backend process to exit after its vault got unmounted.
+	lockTimeout = time.Second * 10
+	// interruptTimeout is how long we wait for the backend process to exit after it got interrupted.
+	interruptTimeout = time.Second * 3
+)
+
+// LockVault locks given vault by unmounting it, then waits for its backend process to exit.
+// The pairing goroutine in `MountVault` does the cleanup after the process exits.
+// If files are still open inside the vault, ErrVaultBusy is returned along with processes holding them,
+// unless `force` is true, in which case the vault gets unmounted lazily.
+// ErrCantUnmount is returned if the backend process keeps running even after being interrupted.
+func (m *VaultManager) LockVault(vaultId int64, force bool) error {
+	m.lock.Lock()
+	mountPoint, unlocked := m.mountPoints[vaultId]
+	proc := m.processes[vaultId]
+	exited := m.exited[vaultId]
+	if proc != nil {
+		m.locking[vaultId] = true
+		defer func() {
+			m.lock.Lock()
+			delete(m.locking, vaultId)
+			m.lock.Unlock()
+		}()
+	}
+	m.lock.Unlock()
+	if !unlocked {
+		return ErrVaultAlreadyLocked
 	}
 
-	go func() {
-		defer stdIn.Close()
-		if _, err := io.WriteString(stdIn, password); err != nil {
-			logger.Error().Err(err).
-				Str("vaultPath", path).
-				Msg("Failed to pipe vault password to gocryptfs when initializing new vault")
+	lockLog := logger.With().
+		Int64("vaultId", vaultId).
+		Str("mountPoint", mountPoint).
+		Bool("force", force).
+		Logger()
+	if err := m.unmount(vaultId, mountPoint, force); err != nil {
+		if errors.Is(err, extension.ErrMountBusy) {
+			holders, holdersErr := extension.ListHolders(mountPoint)
+			if holdersErr != nil {
+				lockLog.Warn().Err(holdersErr).Msg("Failed to find processes holding files in vault")
+			}
+			lockLog.Warn().Err(err).Interface("holders", holders).Msg("Vault is busy")
+			return ErrVaultBusy.Reformat(describeHolders(holders)).WrapItem(holders)
 		}
-	}()
+		if proc == nil {
+			lockLog.Error().Err(err).Msg("Failed to unmount vault")
+			return ErrUnknown.Reformat(err)
+		}
+		// The mount might not be ready yet, let the backend handle it
+		lockLog.Warn().Err(err).Msg("Failed to unmount vault, interrupting backend process instead")
+		if err := proc.Process.Signal(os.Interrupt); err != nil {
+			return err
+		}
+	}
 
-	// Vault created, add to vault repository
-	if err = initProc.Run(); err != nil { // Failed to init vault, inspect error and respond to UI
-		rc := initProc.ProcessState.ExitCode()
-		errString := errorOutput.String()
-		errLog := logger.With().
-			Err(err).
-			Int("RC", rc).
-			Str("vaultPath", path).
-			Str("stdErr", errString).
-			Logger()
-		switch rc {
-		case 6:
-			errLog.Error().Msg("New vault directory (CIPHERDIR) is not empty")
-			return ErrVaultDirNotEmpty
-		case 22:
-			errLog.Error().Msg("Password for new vault is empty")
-			return ErrVaultPasswordEmpty
-		case 24:
-			errLog.Error().Msg("Gocryptfs could not create gocryptfs.conf")
-			return ErrVaultInitConfFailed
-		default:
-			errLog.Error().Msg("Unknown error when initializing new vault")
-			return ErrUnknown.Reformat(errString)
+	// We don't own processes of adopted vaults, so there's nothing to wait for
+	if proc == nil {
+		m.lock.Lock()
+		defer m.lock.Unlock()
+		if m.isAdopted(vaultId) {
+			lockLog.Info().Msg("Vault locked")
+			m.releaseAdopted(vaultId)
+		}
+		return nil
+	}
+
+	// Backend processes exit by themselves after the vault got unmounted
+	select {
+	case <-exited:
+		return nil
+	case <-time.After(lockTimeout):
+	}
+	lockLog.Warn().Msg("Backend process did not exit after unmounting, interrupting it")
+	if err := proc.Process.Signal(os.Interrupt); err != nil {
+		lockLog.Warn().Err(err).Msg("Failed to interrupt backend process")
+	}
+	select {
+	case <-exited:
+		return nil
+	case <-time.After(interruptTimeout):
+	}
+	// The vault stays unlocked until the process exits, the pairing goroutine cleans up then
+	lockLog.Error().Int("pid", proc.Process.Pid).Msg("Backend process is still running after being interrupted")
+	return ErrCantUnmount.Reformat(filepath.Base(proc.Path))
+}
+
+// unmount unmounts given vault using its backend.
+// `extension.ErrMountBusy` is returned if files are still open inside the vault.
+func (m *VaultManager) unmount(vaultId int64, mountPoint string, force bool) error {
+	if vault, err := m.repo.Get(vaultId, nil); err == nil {
+		if b, err := m.backend(vault.Backend); err == nil {
+			return b.Unmount(mountPoint, force)
 		}
 	}
-	return err
+	// Vaults are FUSE mounts after all, even if their backend is gone
+	return extension.Unmount(mountPoint, force)
 }
 
-// GocryptfsChangeVaultPassword changes password for vault identified by `path` directory.
-func (m *VaultManager) GocryptfsChangeVaultPassword(path string, password string, newPassword string) error {
-	chPwProc := exec.Command(m.cmd, "-passwd", "--", path)
-	// Password is piped through STDIN
-	stdIn, err := chPwProc.StdinPipe()
-	var errorOutput bytes.Buffer
-	chPwProc.Stderr = &errorOutput
-	if err != nil {
-		logger.Error().Err(err).
-			Str("vaultPath", path).
-			Msg("Failed to create STDIN pipe when changing password for vault")
-		return err
+// describeHolders formats processes holding files as a human-readable list.
+func describeHolders(holders []extension.Holder) string {
+	if len(holders) == 0 {
+		return "unknown processes"
 	}
+	descriptions := make([]string, len(holders))
+	for i, holder := range holders {
+		descriptions[i] = fmt.Sprintf("%s (PID %d)", holder.Name, holder.PID)
+	}
+	return strings.Join(descriptions, ", ")
+}
 
-	go func() {
-		defer stdIn.Close()
-		passwords := strings.Join([]string{password, newPassword}, "\n")
-		if _, err := io.WriteString(stdIn, passwords); err != nil {
-			logger.Error().Err(err).
-				Str("vaultPath", path).
-				Msg("Failed to pipe passwords to gocryptfs when changing password for vault")
+// LockAllVaults locks all unlocked vaults, vaults for which `keep` returns true are skipped.
+// If `reason` is not empty, an auto-lock event is published for each vault locked.
+// Busy vaults are unmounted lazily, open files should not keep vaults unlocked when we're told to lock them all.
+func (m *VaultManager) LockAllVaults(reason string, keep func(vault models.Vault) bool) {
+	m.lock.Lock()
+	vaultIds := make([]int64, 0, len(m.mountPoints))
+	for vaultId := range m.mountPoints {
+		vaultIds = append(vaultIds, vaultId)
+	}
+	m.lock.Unlock()
+
+	for _, vaultId := range vaultIds {
+		if keep != nil {
+			if vault, err := m.repo.Get(vaultId, nil); err == nil && keep(vault) {
+				logger.Debug().
+					Int64("vaultId", vaultId).
+					Str("vaultPath", vault.Path).
+					Str("reason", reason).
+					Msg("Vault is kept unlocked")
+				continue
+			}
 		}
-	}()
 
-	// Vault created, add to vault repository
-	if err := chPwProc.Run(); err != nil { // Failed to init vault, inspect error and respond to UI
-		rc := chPwProc.ProcessState.ExitCode()
-		errString := errorOutput.String()
-		errLog := logger.With().
-			Err(err).
-			Int("RC", rc).
-			Str("vaultPath", path).
-			Str("stdErr", errString).
-			Logger()
-		switch rc {
-		case 12:
-			errLog.Error().Msg("Password incorrect")
-			return ErrWrongPassword
-		case 23:
-			errLog.Error().Msg("Gocryptfs could not open gocryptfs.conf for reading")
-			return ErrCantOpenVaultConf
-		case 24:
-			errLog.Error().Msg("Gocryptfs could not write the updated gocryptfs.conf")
-			return ErrVaultUpdateConfFailed
-		default:
-			errLog.Error().Msg("Unknown error when changing password for vault")
-			return ErrUnknown.Reformat(errString)
+		logger.Debug().
+			Int64("vaultId", vaultId).
+			Str("reason", reason).
+			Msg("Locking vault")
+		if reason != "" {
+			m.events.Publish(Event{
+				Type:    EventVaultAutoLocked,
+				VaultID: vaultId,
+				Data:    map[string]string{"reason": reason},
+			})
+		}
+		err := m.LockVault(vaultId, true)
+		if reason != "" {
+			m.Audit(vaultId, AuditAutoLock, err)
+		} else {
+			m.Audit(vaultId, AuditLock, err)
+		}
+		if err != nil && err != ErrVaultAlreadyLocked {
+			logger.Error().Err(err).
+				Int64("vaultId", vaultId).
+				Msg("Failed to lock vault")
 		}
 	}
+}
+
+// LockVaultsOnSessionEvent locks vaults when system suspends or the screen gets locked.
+// Vaults which opted out of this are kept unlocked.
+func (m *VaultManager) LockVaultsOnSessionEvent(event string) {
+	logger.Info().Str("event", event).Msg("Locking vaults on session event")
+	m.LockAllVaults(event, func(vault models.Vault) bool {
+		return !vault.LockSuspend
+	})
+}
+
+// MountPoint returns the mountpoint of given vault, the second return value is false if the vault is locked.
+func (m *VaultManager) MountPoint(vaultId int64) (string, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	mountPoint, ok := m.mountPoints[vaultId]
+	return mountPoint, ok
+}
+
+// RemainingLifetime returns how long the vault stays unlocked before it gets locked automatically.
+// The second return value is false if the vault has no max unlock lifetime.
+func (m *VaultManager) RemainingLifetime(vaultId int64) (time.Duration, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if al, ok := m.autoLocks[vaultId]; ok {
+		return time.Until(al.deadline), true
+	}
+	return 0, false
+}
+
+// scheduleAutoLock locks the vault once its max unlock lifetime is reached, counting from `unlocked`.
+// `m.lock` must be held by the caller.
+func (m *VaultManager) scheduleAutoLock(vault models.Vault, unlocked time.Time) {
+	if vault.MaxLifetime <= 0 {
+		return
+	}
+	lifetime := time.Duration(vault.MaxLifetime) * time.Second
+	deadline := unlocked.Add(lifetime)
+	m.autoLocks[vault.ID] = &autoLock{
+		deadline: deadline,
+		timer: time.AfterFunc(time.Until(deadline), func() {
+			logger.Info().
+				Int64("vaultId", vault.ID).
+				Str("vaultPath", vault.Path).
+				Dur("maxLifetime", lifetime).
+				Msg("Max unlock lifetime reached, locking vault")
+			m.events.Publish(Event{
+				Type:    EventVaultAutoLocked,
+				VaultID: vault.ID,
+				Data:    map[string]string{"reason": "maxlifetime"},
+			})
+			// Open files should not keep the vault unlocked beyond its lifetime
+			err := m.LockVault(vault.ID, true)
+			m.Audit(vault.ID, AuditAutoLock, err)
+			if err != nil && err != ErrVaultAlreadyLocked {
+				logger.Error().Err(err).
+					Int64("vaultId", vault.ID).
+					Str("vaultPath", vault.Path).
+					Msg("Failed to auto-lock vault")
+			}
+		}),
+	}
+}
+
+// recordIdleLock publishes and audits the auto-lock of given vault, which its backend unmounted after being idle.
+func (m *VaultManager) recordIdleLock(vault models.Vault) {
+	logger.Info().
+		Int64("vaultId", vault.ID).
+		Str("vaultPath", vault.Path).
+		Int64("idleTimeout", vault.IdleTimeout).
+		Msg("Vault got locked after being idle")
+	m.events.Publish(Event{
+		Type:    EventVaultAutoLocked,
+		VaultID: vault.ID,
+		Data:    map[string]string{"reason": "idle"},
+	})
+	m.Audit(vault.ID, AuditAutoLock, nil)
+}
+
+// cancelAutoLock stops auto-lock timer of given vault.
+// `m.lock` must be held by the caller.
+func (m *VaultManager) cancelAutoLock(vaultId int64) {
+	if al, ok := m.autoLocks[vaultId]; ok {
+		al.timer.Stop()
+		delete(m.autoLocks, vaultId)
+	}
+}
+
+// publishExit publishes an event for exited backend process according to its exit code,
+// `err` is the exit code converted by the backend.
+func (m *VaultManager) publishExit(vaultId int64, rc int, err error) {
+	var exitErr *backend.ExitError
+	if err == nil || (errors.As(err, &exitErr) && exitErr.Err != nil) {
+		// Locked, or failed to unlock due to known errors
+		m.events.Publish(Event{Type: EventVaultLocked, VaultID: vaultId, RC: rc})
+	} else {
+		m.events.Publish(Event{Type: EventVaultExited, VaultID: vaultId, RC: rc})
+	}
+}
+
+// CreateOptions holds optional gocryptfs features for creating a new vault.
+// Zero values leave the gocryptfs defaults in place.
+type CreateOptions struct {
+	Reverse            bool `json:"reverse"`            // create a reverse vault, for an encrypted view of a plaintext directory
+	PlaintextNames     bool `json:"plaintextnames"`     // do not encrypt file names
+	AESSIV             bool `json:"aessiv"`             // use AES-SIV encryption instead of AES-GCM
+	XChaCha            bool `json:"xchacha"`            // use XChaCha20-Poly1305 encryption instead of AES-GCM
+	DeterministicNames bool `json:"deterministicnames"` // disable per-directory IVs for file name encryption
+	LongNameMax        int  `json:"longnamemax"`        // hash encrypted names longer than this, 62 ~ 255
+	ScryptN            int  `json:"scryptn"`            // scrypt cost parameter as logarithm, 10 ~ 28
+}
+
+// Validate checks whether the options are sane and can be used together.
+// An `ErrInvalidCreateOptions` is returned if not.
+func (o CreateOptions) Validate() error {
+	switch {
+	case o.AESSIV && o.XChaCha:
+		return ErrInvalidCreateOptions.Reformat("aessiv and xchacha cannot be used together")
+	case o.PlaintextNames && o.DeterministicNames:
+		return ErrInvalidCreateOptions.Reformat("deterministicnames cannot be used with plaintextnames")
+	case o.PlaintextNames && o.LongNameMax != 0:
+		return ErrInvalidCreateOptions.Reformat("longnamemax cannot be used with plaintextnames")
+	case o.LongNameMax != 0 && (o.LongNameMax < 62 || o.LongNameMax > 255):
+		return ErrInvalidCreateOptions.Reformat("longnamemax must be between 62 and 255")
+	case o.ScryptN != 0 && (o.ScryptN < gocryptfs.ScryptMinLogN || o.ScryptN > gocryptfs.ScryptMaxLogN):
+		return ErrInvalidCreateOptions.Reformat(fmt.Sprintf("scryptn must be between %d and %d", gocryptfs.ScryptMinLogN, gocryptfs.ScryptMaxLogN))
+	}
 	return nil
 }
 
-// GocryptfsShowVaultMasterkey reveals masterkey for vault identified by `path` directory.
-// Returns (masterkey, error).
-// Notice: `path` is the path to the vault directory.
-func (m *VaultManager) GocryptfsShowVaultMasterkey(path string, password string) (string, error) {
-	var err error
-	var masterKey string
+// args converts the options into gocryptfs command line arguments.
+func (o CreateOptions) args() []string {
+	var args []string
+	if o.Reverse {
+		args = append(args, "-reverse")
+	}
+	if o.PlaintextNames {
+		args = append(args, "-plaintextnames")
+	}
+	if o.AESSIV {
+		args = append(args, "-aessiv")
+	}
+	if o.XChaCha {
+		args = append(args, "-xchacha")
+	}
+	if o.DeterministicNames {
+		args = append(args, "-deterministic-names")
+	}
+	if o.LongNameMax != 0 {
+		args = append(args, "-longnamemax", strconv.Itoa(o.LongNameMax))
+	}
+	if o.ScryptN != 0 {
+		args = append(args, "-scryptn", strconv.Itoa(o.ScryptN))
+	}
+	return args
+}
 
-	vaultConfigPath := filepath.Join(path, "gocryptfs.conf")
-	xrayProc := exec.Command(m.xrayCmd, "-dumpmasterkey", vaultConfigPath)
-	// Password is piped through STDIN
-	stdIn, err := xrayProc.StdinPipe()
-	var errorOutput, stdOutput bytes.Buffer
-	xrayProc.Stderr = &errorOutput
-	xrayProc.Stdout = &stdOutput
+// GocryptfsSupports tells whether the gocryptfs binary supports given command line flag, e.g. `-xchacha`.
+// Nothing is supported if gocryptfs is not located.
+func (m *VaultManager) GocryptfsSupports(flag string) bool {
+	g, err := m.gocryptfs()
+	return err == nil && g.Supports(flag)
+}
+
+// CheckCreateOptions validates given options, and makes sure they are supported by the backend of type `kind`.
+// Options other than `Reverse` are gocryptfs features.
+func (m *VaultManager) CheckCreateOptions(kind string, options CreateOptions) error {
+	if options.Reverse && !backend.FeaturesOf(kind).Reverse {
+		return ErrUnsupportedByBackend.Reformat(kind, "reverse mode")
+	}
+	if err := options.Validate(); err != nil {
+		return err
+	}
+	for _, arg := range options.args() {
+		if !strings.HasPrefix(arg, "-") || arg == "-reverse" {
+			continue
+		}
+		if kind != backend.Gocryptfs {
+			return ErrUnsupportedByBackend.Reformat(kind, arg)
+		}
+		if !m.GocryptfsSupports(arg) {
+			logger.Error().Str("flag", arg).Msg("Flag not supported by gocryptfs")
+			return ErrUnsupportedFeature.Reformat(arg)
+		}
+	}
+	return nil
+}
+
+// InitVault creates a new vault of backend type `kind` at `path` with `password`.
+// `options` should be checked with `CheckCreateOptions` first. The backend process gets killed if `ctx` is done.
+func (m *VaultManager) InitVault(ctx context.Context, kind string, path string, password string, options CreateOptions) error {
+	b, err := m.backend(kind)
 	if err != nil {
+		return err
+	}
+	initOptions := backend.InitOptions{Reverse: options.Reverse}
+	for _, arg := range options.args() {
+		if arg != "-reverse" {
+			initOptions.Args = append(initOptions.Args, arg)
+		}
+	}
+	if err := b.Init(ctx, path, password, initOptions); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 		logger.Error().Err(err).
+			Str("backend", kind).
 			Str("vaultPath", path).
-			Msg("Failed to create STDIN pipe when revealing masterkey for vault")
-		return masterKey, err
+			Msg("Failed to initialize new vault")
+		return backendError(kind, err, ErrVaultInitConfFailed)
 	}
+	return nil
+}
 
-	go func() {
-		defer stdIn.Close()
-		if _, err := io.WriteString(stdIn, password); err != nil {
-			logger.Error().Err(err).
-				Str("vaultPath", path).
-				Msg("Failed to pipe passwords to gocryptfs when revealing masterkey for vault")
+// ChangePassword changes password for given vault.
+// For gocryptfs vaults, the master key is re-wrapped with scrypt cost `scryptN` (as logarithm) if it's not 0,
+// otherwise gocryptfs picks the default. The backend process gets killed if `ctx` is done.
+func (m *VaultManager) ChangePassword(ctx context.Context, vault models.Vault, password string, newPassword string, scryptN int) error {
+	b, err := m.backend(vault.Backend)
+	if err != nil {
+		return err
+	}
+	if !backend.FeaturesOf(vault.Backend).ChangePassword {
+		return ErrUnsupportedByBackend.Reformat(vault.Backend, "changing password")
+	}
+	options := backend.PasswordOptions{Reverse: vault.Reverse}
+	if scryptN != 0 {
+		if err := requireGocryptfs(vault, "scrypt cost"); err != nil {
+			return err
 		}
-	}()
+		options.Args = append(options.Args, "-scryptn", strconv.Itoa(scryptN))
+	}
+	if err := b.ChangePassword(ctx, vault.Path, password, newPassword, options); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		logger.Error().Err(err).
+			Str("backend", b.Name()).
+			Str("vaultPath", vault.Path).
+			Msg("Failed to change password for vault")
+		return backendError(b.Name(), err, ErrVaultUpdateConfFailed).WrapRC(exitCode(err))
+	}
+	return nil
+}
 
-	// Vault created, add to vault repository
-	if err := xrayProc.Run(); err != nil { // Failed to init vault, inspect error and respond to UI
-		rc := xrayProc.ProcessState.ExitCode()
-		errString := errorOutput.String()
-		outString := stdOutput.String()
-		errLog := logger.With().
-			Err(err).
-			Int("RC", rc).
-			Str("vaultPath", path).
-			Str("stdErr", errString).
-			Str("stdOut", outString).
-			Logger()
-		switch rc {
-		case 12:
+// GocryptfsShowVaultMasterkey reveals masterkey for given vault.
+// The master key is decrypted natively from the vault config, so gocryptfs-xray is not needed.
+// Returns (masterkey, error).
+func (m *VaultManager) GocryptfsShowVaultMasterkey(vault models.Vault, password string) (string, error) {
+	if err := requireGocryptfs(vault, "revealing master key"); err != nil {
+		return "", err
+	}
+	conf, err := gocryptfs.LoadVault(vault.Path, vault.Reverse)
+	if err != nil {
+		logger.Error().Err(err).
+			Str("vaultPath", vault.Path).
+			Msg("Failed to load vault config when revealing masterkey for vault")
+		return "", confError(err)
+	}
+
+	masterKey, err := conf.DecryptMasterKey(password)
+	if err != nil {
+		errLog := logger.With().Err(err).Str("vaultPath", vault.Path).Logger()
+		switch {
+		case errors.Is(err, gocryptfs.ErrWrongPassword):
 			errLog.Error().Msg("Password incorrect")
-			return masterKey, ErrWrongPassword
-		case 23:
-			errLog.Error().Msg("Gocryptfs could not open gocryptfs.conf for reading")
-			return masterKey, ErrCantOpenVaultConf
-		case 24:
-			errLog.Error().Msg("Gocryptfs could not write the updated gocryptfs.conf")
-			return masterKey, ErrVaultUpdateConfFailed
+			return "", ErrWrongPassword
+		case errors.Is(err, gocryptfs.ErrUnsupportedConf):
+			errLog.Error().Msg("Vault config uses a feature we cannot decrypt")
+			return "", ErrUnsupportedFeature.Reformat(err)
 		default:
-			errLog.Error().Msg("Unknown error when changing password for vault")
-			if strings.TrimSpace(errString) == "" {
-				errString = outString
-			}
-			return masterKey, ErrUnknown.Reformat(errString)
+			errLog.Error().Msg("Failed to decrypt masterkey for vault")
+			return "", confError(err)
 		}
 	}
-	masterKey = strings.TrimSpace(stdOutput.String())
-	return masterKey, nil
+	defer func() {
+		for i := range masterKey {
+			masterKey[i] = 0
+		}
+	}()
+	return gocryptfs.FormatMasterKey(masterKey), nil
 }
 
 // GocryptfsResetVaultPassword reset password for vault using masterkey.
-func (m *VaultManager) GocryptfsResetVaultPassword(path string, masterkey string, newPassword string) error {
-	chPwProc := exec.Command(m.cmd, "-passwd", "-masterkey", masterkey, "--", path)
-	// Password is piped through STDIN
-	stdIn, err := chPwProc.StdinPipe()
-	var errorOutput bytes.Buffer
-	chPwProc.Stderr = &errorOutput
+// gocryptfs gets killed if `ctx` is done.
+func (m *VaultManager) GocryptfsResetVaultPassword(ctx context.Context, vault models.Vault, masterkey string, newPassword string) error {
+	if err := requireGocryptfs(vault, "resetting password with master key"); err != nil {
+		return err
+	}
+	g, err := m.gocryptfs()
 	if err != nil {
-		logger.Error().Err(err).
-			Str("vaultPath", path).
-			Msg("Failed to create STDIN pipe when recovering password for vault")
 		return err
 	}
-
-	go func() {
-		defer stdIn.Close()
-		passwords := strings.Join([]string{newPassword, newPassword}, "\n")
-		if _, err := io.WriteString(stdIn, passwords); err != nil {
-			logger.Error().Err(err).
-				Str("vaultPath", path).
-				Msg("Failed to pipe passwords to gocryptfs when recovering password for vault")
+	if err := g.ResetPassword(ctx, vault.Path, masterkey, newPassword, vault.Reverse); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
 		}
-	}()
-
-	if err := chPwProc.Run(); err != nil {
-		rc := chPwProc.ProcessState.ExitCode()
-		errString := errorOutput.String()
-		errLog := logger.With().
-			Err(err).
-			Int("RC", rc).
-			Str("vaultPath", path).
-			Str("stdErr", errString).
-			Logger()
-		switch rc {
-		case 12:
-			errLog.Error().Msg("Password incorrect")
-			return ErrWrongPassword
-		case 23:
-			errLog.Error().Msg("Gocryptfs could not open gocryptfs.conf for reading")
-			return ErrCantOpenVaultConf
-		case 24:
-			errLog.Error().Msg("Gocryptfs could not write the updated gocryptfs.conf")
-			return ErrVaultUpdateConfFailed
-		default:
-			errLog.Error().Msg("Unknown error when recovering password for vault")
-			return ErrUnknown.Reformat(errString)
-		}
-	}
-
-	// Rename backup of original vault config file
-	// so the next time user do a password resetting there is no file conflicting
-	vaultConfBackup := filepath.Join(path, "gocryptfs.conf.bak")
-	vaultConfBackupWithTime := filepath.Join(
-		path,
-		fmt.Sprintf(
-			"gocryptfs.conf.bak.%s", time.Now().UTC().Format("2006-01-02T15:04:05.000 MST"),
-		),
-	)
-	if err := os.Rename(vaultConfBackup, vaultConfBackupWithTime); err != nil && !os.IsNotExist(err) {
-		logger.Warn().Err(err).
-			Str("vaultPath", path).
-			Str("from", vaultConfBackup).
-			Str("to", vaultConfBackupWithTime).
-			Msg("Failed to rename backup of original gocryptfs.conf file")
+		logger.Error().Err(err).
+			Str("vaultPath", vault.Path).
+			Msg("Failed to recover password for vault")
+		return backendError(backend.Gocryptfs, err, ErrVaultUpdateConfFailed).WrapRC(exitCode(err))
 	}
 	return nil
 }
 
-// GocryptfsUnlockVault unlocks the vault identified by `vaultId` using given `password`.
-func (m *VaultManager) GocryptfsUnlockVault(vaultId int64, password string) error {
+// MountVault unlocks the vault identified by `vaultId` using given `password`, by mounting it with its backend.
+func (m *VaultManager) MountVault(vaultId int64, password string) error {
 	// Check current state
 	if _, ok := m.mountPoints[vaultId]; ok {
 		return ErrVaultAlreadyUnlocked
@@ -339,6 +712,18 @@ func (m *VaultManager) GocryptfsUnlockVault(vaultId int64, password string) erro
 		}
 		return err
 	}
+	// Operations like changing password must finish first
+	if err := m.checkVaultIdle(vaultId); err != nil {
+		return err
+	}
+	b, err := m.backend(vault.Backend)
+	if err != nil {
+		return err
+	}
+	// A moved vault would make the backend fail with a confusing RC
+	if vaultMissing(vault) {
+		return ErrVaultMissing
+	}
 	// Locate a mountpoint for this vault
 	if strings.TrimSpace(vault.MountPoint) == "" {
 		var mountPointBase string
@@ -349,120 +734,162 @@ func (m *VaultManager) GocryptfsUnlockVault(vaultId int64, password string) erro
 		}
 		vault.MountPoint = filepath.Join(mountPointBase, strconv.FormatInt(int64(rand.Int31()), 16))
 	}
-	// OSXFUSE will create mountpoint for us if it's located in `/Volumes`,
-	// but for Linux we'll have to do the mkdir ourselves.
-	shouldRemoveMountpoint := false
-	if runtime.GOOS != "darwin" {
-		if err := os.MkdirAll(vault.MountPoint, 0700); err != nil {
-			logger.Error().Err(err).
-				Str("vaultPath", vault.Path).
-				Str("mountPoint", vault.MountPoint).
-				Msg("Failed to create mountpoint directory")
-			return ErrMountpointMkdirFailed
-		}
-		shouldRemoveMountpoint = true
+	// Prepare a backend process to unlock this vault
+	options := backend.MountOptions{
+		ReadOnly:    vault.ReadOnly,
+		Reverse:     vault.Reverse,
+		IdleTimeout: time.Duration(vault.IdleTimeout) * time.Second, // let the backend unmount the vault if it's idle for long enough
+		Debug:       vault.DebugMount,
 	}
-	// Start a gocryptfs process to unlock this vault
-	args := []string{"-fg"}
+	// Advanced mount options of this vault, FUSE options are merged with ours
+	options.Args, options.FuseOptions = mountArgs(vault.MountOptions)
+	// Name the volume after the vault, so file managers show it instead of a generic one
 	if runtime.GOOS == "darwin" {
-		args = append(
-			args,
-			"-ko",
-			fmt.Sprintf("volname=%s,local,auto_xattr,noappledouble", filepath.Base(vault.Path)),
-		)
+		options.VolumeName = volumeName(vault.Label())
+	} else if vault.Name != "" && !hasMountOption(vault.MountOptions, "-fsname") {
+		options.VolumeName = volumeName(vault.Name)
+	}
+	// Paths excluded from the encrypted view of reverse vaults
+	if vault.Reverse {
+		for _, pattern := range vault.Exclude {
+			options.Args = append(options.Args, "-exclude", pattern)
+		}
+		for _, pattern := range vault.ExcludeWildcard {
+			options.Args = append(options.Args, "-exclude-wildcard", pattern)
+		}
 	}
-	// Readonly mode
 	if vault.ReadOnly {
-		args = append(args, "-ro")
 		logger.Debug().
 			Str("vaultPath", vault.Path).
 			Str("mountPoint", vault.MountPoint).
 			Bool("readOnly", vault.ReadOnly).
 			Msg("Vault is set to mount Read-Only")
 	}
-	args = append(args, "--", vault.Path, vault.MountPoint)
-	m.processes[vaultId] = exec.Command(m.cmd, args...)
-	m.mountPoints[vaultId] = vault.MountPoint
-
 	// Password is piped through STDIN
-	stdIn, err := m.processes[vaultId].StdinPipe()
+	proc, err := b.Mount(vault.Path, vault.MountPoint, password, options)
 	if err != nil {
 		logger.Error().Err(err).
+			Str("backend", b.Name()).
 			Str("vaultPath", vault.Path).
-			Str("mountPoint", vault.MountPoint).
-			Msg("Failed to create STDIN pipe")
-		defer delete(m.processes, vaultId)
-		defer delete(m.mountPoints, vaultId)
-		return err
+			Msg("Backend cannot mount vault with its options")
+		return backendError(b.Name(), err, ErrUnknown)
 	}
-
-	rcPipe := make(chan int)
-
-	go func() {
-		defer stdIn.Close()
-		if _, err := io.WriteString(stdIn, password); err != nil {
+	// OSXFUSE will create mountpoint for us if it's located in `/Volumes`,
+	// but for Linux we'll have to do the mkdir ourselves.
+	shouldRemoveMountpoint := false
+	if runtime.GOOS != "darwin" {
+		if err := os.MkdirAll(vault.MountPoint, 0700); err != nil {
 			logger.Error().Err(err).
 				Str("vaultPath", vault.Path).
 				Str("mountPoint", vault.MountPoint).
-				Msg("Failed to pipe vault password to gocryptfs")
+				Msg("Failed to create mountpoint directory")
+			return ErrMountpointMkdirFailed
 		}
-	}()
-	if err := m.processes[vaultId].Start(); err != nil {
+		shouldRemoveMountpoint = true
+		m.mountPointRecords.add(vault.MountPoint, vaultId)
+	}
+	m.processes[vaultId] = proc
+	m.mountPoints[vaultId] = vault.MountPoint
+	exited := make(chan struct{})
+	m.exited[vaultId] = exited
+
+	// Keep output of the backend, and write it to the debug log too in debug mode
+	output := newOutputBuffer()
+	m.outputs[vaultId] = output
+	var outputWriter io.Writer = output
+	var debugLog io.WriteCloser
+	if vault.DebugMount {
+		if debugLog, err = openDebugLog(vaultId); err != nil {
+			logger.Warn().Err(err).
+				Int64("vaultId", vaultId).
+				Str("logPath", debugLogPath(vaultId)).
+				Msg("Failed to open debug log, mounting without it")
+			debugLog = nil
+		} else {
+			fmt.Fprintf(debugLog, "=== %s: %s\n", time.Now().Format(time.RFC3339), strings.Join(proc.Args, " "))
+			outputWriter = io.MultiWriter(output, debugLog)
+		}
+	}
+	proc.Stdout = outputWriter
+	proc.Stderr = outputWriter
+
+	rcPipe := make(chan int)
+
+	if err := proc.Start(); err != nil {
 		logger.Error().Err(err).
 			Int64("vaultId", vaultId).
 			Str("vaultPath", vault.Path).
 			Str("mountPoint", vault.MountPoint).
-			Str("gocryptfs", m.cmd).
-			Msg("Failed to start gocryptfs process")
+			Str("binary", b.Binary()).
+			Msg("Failed to start backend process")
 
 		// Cleanup immediately
 		defer delete(m.processes, vaultId)
 		defer delete(m.mountPoints, vaultId)
+		defer delete(m.exited, vaultId)
 		defer close(rcPipe)
+		if debugLog != nil {
+			debugLog.Close()
+		}
 
 		return err
 	}
 
+	m.events.Publish(Event{Type: EventVaultUnlocking, VaultID: vaultId})
+
 	// Need to wait for this process to exit, otherwise it becomes zombie after exiting.
 	go func() {
-		proc := m.processes[vaultId]
-		if err := proc.Wait(); err != nil {
-			rc := proc.ProcessState.ExitCode()
-			switch rc {
-			case 10, 12, 23: // These are known errors meant to be reported directly to the UI
-				break
-			case 15: // gocryptfs interrupted by SIGINT, a.k.a. we locked this vault
-				logger.Info().
-					Int("RC", rc).
-					Int64("vaultId", vaultId).
-					Str("vaultPath", vault.Path).
-					Str("mountPoint", vault.MountPoint).
-					Msg("Vault locked")
-				break
-			default:
-				logger.Error().Err(err).
-					Int("RC", rc).
-					Int64("vaultId", vaultId).
-					Str("vaultPath", vault.Path).
-					Str("mountPoint", vault.MountPoint).
-					Msg("Gocryptfs exited unexpectedly")
-			}
-			rcPipe <- rc
-		} else {
-			logger.Debug().
+		rc := 0
+		err := proc.Wait()
+		if debugLog != nil {
+			debugLog.Close()
+		}
+		if err != nil {
+			rc = proc.ProcessState.ExitCode()
+		}
+		exitErr := b.MountError(rc, strings.Join(output.Lines(), "\n"))
+		var knownErr *backend.ExitError
+		switch {
+		case exitErr == nil: // the vault got unmounted, a.k.a. we locked this vault
+			logger.Info().
+				Int("RC", rc).
+				Int64("vaultId", vaultId).
+				Str("vaultPath", vault.Path).
+				Str("mountPoint", vault.MountPoint).
+				Msg("Vault locked")
+		case errors.As(exitErr, &knownErr) && knownErr.Err != nil: // These are known errors meant to be reported directly to the UI
+		default:
+			logger.Error().Err(err).
+				Int("RC", rc).
 				Int64("vaultId", vaultId).
 				Str("vaultPath", vault.Path).
 				Str("mountPoint", vault.MountPoint).
-				Msg("Gocryptfs exited without error, the mountpoint was probably unmounted manually")
-			rcPipe <- 0
+				Strs("output", output.Tail(10)).
+				Msg("Backend process exited unexpectedly")
 		}
+		rcPipe <- rc
+
+		// Notify subscribers after the cleanup, so they always observe the final state
+		defer m.publishExit(vaultId, rc, exitErr)
+		idle := false
+		defer func() {
+			if idle {
+				m.recordIdleLock(vault)
+			}
+		}()
 
 		// Cleanup
 		m.lock.Lock()
 		defer m.lock.Unlock()
+		// Backends unmounting vaults by themselves did so because of the idle timeout,
+		// though it can't be told apart from vaults unmounted elsewhere
+		idle = exitErr == nil && vault.IdleTimeout > 0 && !m.locking[vaultId]
+		defer close(exited)
 		defer delete(m.processes, vaultId)
 		defer delete(m.mountPoints, vaultId)
+		defer delete(m.exited, vaultId)
 		defer close(rcPipe)
+		m.cancelAutoLock(vaultId)
 
 		// Remove mountpoint directory if we created it
 		if shouldRemoveMountpoint {
@@ -472,59 +899,45 @@ func (m *VaultManager) GocryptfsUnlockVault(vaultId int64, password string) erro
 					Str("vaultPath", vault.Path).
 					Str("mountPoint", vault.MountPoint).
 					Msg("Failed to remove mountpoint directory")
+			} else {
+				m.mountPointRecords.remove(vault.MountPoint)
 			}
 		}
 	}()
 
-	// Wait for a little time, then check if gocryptfs process is alive
+	// Wait for a little time, then check if the backend process is alive
 	// If it exited, there's something wrong, respond to the UI
 	timer := time.NewTimer(time.Second)
 	select {
 	case rc := <-rcPipe:
-		switch rc {
-		case 10: // Mountpoint not empty
-			logger.Error().Err(err).
-				Int("RC", rc).
-				Int64("vaultId", vaultId).
-				Str("vaultPath", vault.Path).
-				Str("mountPoint", vault.MountPoint).
-				Msg("Mountpoint not empty")
-			return ErrMountpointNotEmpty.WrapState("locked")
-		case 12: // Incorrect password
-			logger.Error().Err(err).
-				Int("RC", rc).
-				Int64("vaultId", vaultId).
-				Str("vaultPath", vault.Path).
-				Str("mountPoint", vault.MountPoint).
-				Msg("Vault password incorrect")
-			return ErrWrongPassword.WrapState("locked")
-		case 23: // gocryptfs.conf IO error
-			logger.Error().Err(err).
-				Int("RC", rc).
-				Int64("vaultId", vaultId).
-				Str("vaultPath", vault.Path).
-				Str("mountPoint", vault.MountPoint).
-				Msg("Gocryptfs cannot open gocryptfs.conf")
-			return ErrCantOpenVaultConf.WrapState("locked")
-		default:
-			logger.Error().Err(err).
-				Int("RC", rc).
-				Int64("vaultId", vaultId).
-				Str("vaultPath", vault.Path).
-				Str("mountPoint", vault.MountPoint).
-				Msg("Gocryptfs exited unexpectedly")
-			return ErrUnknown.Reformat(rc).WrapState("locked")
+		exitErr := b.MountError(rc, strings.Join(output.Lines(), "\n"))
+		if exitErr == nil {
+			// Exiting cleanly right after mounting is unexpected as well
+			exitErr = &backend.ExitError{RC: rc}
+		}
+		apiErr := backendError(b.Name(), exitErr, ErrUnknown)
+		logger.Error().Err(exitErr).
+			Int("RC", rc).
+			Int64("vaultId", vaultId).
+			Str("vaultPath", vault.Path).
+			Str("mountPoint", vault.MountPoint).
+			Msg("Failed to unlock vault")
+		if apiErr.Code == ErrUnknown.Code {
+			return ErrUnknown.Reformat(rc).WrapState("locked").WithRC(rc).WithLog(output.Lines())
 		}
+		return apiErr.WrapState("locked").WithRC(rc)
 	case <-timer.C:
 		logger.Debug().
 			Int64("vaultId", vaultId).
 			Str("vaultPath", vault.Path).
 			Str("mountPoint", vault.MountPoint).
 			Msg("Vault unlocked")
-		// Read from rcPipe, otherwise the `Wait` goroutine will block after gocryptfs exited
+		// Read from rcPipe, otherwise the `Wait` goroutine will block after the backend process exited
 		go func() {
 			<-rcPipe
 		}()
+		m.scheduleAutoLock(vault, time.Now())
+		m.events.Publish(Event{Type: EventVaultUnlocked, VaultID: vaultId})
 	}
 
 	if vault.AutoReveal {
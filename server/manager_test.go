@@ -0,0 +1,319 @@
+package server
+
+import (
+	"Cloak/backend"
+	"Cloak/models"
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/suite"
+)
+
+type lockTestSuite struct {
+	suite.Suite
+	m *VaultManager
+}
+
+func (s *lockTestSuite) SetupTest() {
+	if runtime.GOOS == "windows" {
+		s.T().Skip("fake backend is a shell script")
+	}
+	db, _, err := sqlmock.New()
+	s.Require().NoError(err)
+	s.T().Cleanup(func() { db.Close() })
+	s.m = &VaultManager{
+		repo:        models.NewVaultRepo(db),
+		processes:   make(map[int64]*exec.Cmd),
+		exited:      make(map[int64]chan struct{}),
+		mountPoints: make(map[int64]string),
+		locking:     make(map[int64]bool),
+	}
+
+	timeouts := []time.Duration{lockTimeout, interruptTimeout}
+	lockTimeout, interruptTimeout = time.Millisecond*200, time.Millisecond*200
+	s.T().Cleanup(func() { lockTimeout, interruptTimeout = timeouts[0], timeouts[1] })
+}
+
+// start starts a fake backend process for given vault, which is not mounted actually.
+// It returns once `script` printed a line, after setting its signal handling.
+func (s *lockTestSuite) start(vaultId int64, script string) {
+	proc := exec.Command("sh", "-c", script)
+	stdout, err := proc.StdoutPipe()
+	s.Require().NoError(err)
+	s.Require().NoError(proc.Start())
+	_, err = bufio.NewReader(stdout).ReadString('\n')
+	s.Require().NoError(err)
+	exited := make(chan struct{})
+	go func() {
+		_ = proc.Wait()
+		close(exited)
+	}()
+	s.T().Cleanup(func() {
+		_ = proc.Process.Kill()
+		<-exited
+	})
+	s.m.processes[vaultId] = proc
+	s.m.exited[vaultId] = exited
+	s.m.mountPoints[vaultId] = s.T().TempDir()
+}
+
+func (s *lockTestSuite) Test_01_Interrupted() {
+	// Unmounting fails, the backend gets interrupted instead
+	s.start(1, "trap 'exit 0' INT; echo; sleep 30 & wait")
+	s.Require().NoError(s.m.LockVault(1, false))
+	s.Require().Equal(ErrVaultAlreadyLocked, s.m.LockVault(2, false))
+}
+
+func (s *lockTestSuite) Test_02_StillRunning() {
+	// Backends might hang up in the kernel, ignoring signals
+	s.start(1, "trap '' INT; echo; sleep 30 & wait; sleep 30")
+	s.Require().Equal(ErrCantUnmount.Code, apiErrorCode(s.m.LockVault(1, true)))
+	s.Require().ErrorContains(s.m.LockVault(1, true), "sh is still busy")
+}
+
+func Test_Lock(t *testing.T) {
+	suite.Run(t, new(lockTestSuite))
+}
+
+// fakeExitPassword is the exit code of fake backends given a wrong password, the same as gocryptfs.
+const fakeExitPassword = 12
+
+// fakeBackend mounts vaults by running a shell script, which keeps running as if the vault was mounted.
+type fakeBackend struct {
+	script     string
+	passwords  map[string]string    // vault directory: password, other vaults accept any password
+	options    backend.MountOptions // options of the latest mount
+	unmountErr error                // returned by `Unmount`, backends get interrupted if it's not nil
+}
+
+// useFakeBackend makes given manager mount vaults with a fake backend, and shortens timeouts of locking.
+// Backend processes still running are killed after the test.
+func useFakeBackend(t *testing.T, m *VaultManager) *fakeBackend {
+	timeouts := []time.Duration{lockTimeout, interruptTimeout}
+	lockTimeout, interruptTimeout = time.Millisecond*200, time.Millisecond*200
+	t.Cleanup(func() { lockTimeout, interruptTimeout = timeouts[0], timeouts[1] })
+
+	b := &fakeBackend{passwords: make(map[string]string), unmountErr: errors.New("not mounted")}
+	m.backends = map[string]backend.Backend{backend.Gocryptfs: b}
+	m.mountPointRecords = &mountPointRegistry{path: filepath.Join(t.TempDir(), "mountpoints.json")}
+	t.Cleanup(func() {
+		m.lock.Lock()
+		defer m.lock.Unlock()
+		for _, proc := range m.processes {
+			_ = proc.Process.Kill()
+		}
+	})
+	return b
+}
+
+// fakeVault returns a vault of given ID, whose directory is created in a temporary directory.
+func fakeVault(t *testing.T, vaultId int64) models.Vault {
+	dir := t.TempDir()
+	vault := models.Vault{ID: vaultId, Path: filepath.Join(dir, "vault"), MountPoint: filepath.Join(dir, "mnt"), Backend: backend.Gocryptfs}
+	if err := os.Mkdir(vault.Path, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(vault.Path, "gocryptfs.conf"), []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return vault
+}
+
+func (b *fakeBackend) Name() string   { return backend.Gocryptfs }
+func (b *fakeBackend) Binary() string { return "sh" }
+func (b *fakeBackend) Init(context.Context, string, string, backend.InitOptions) error {
+	return errors.ErrUnsupported
+}
+func (b *fakeBackend) ChangePassword(context.Context, string, string, string, backend.PasswordOptions) error {
+	return errors.ErrUnsupported
+}
+func (b *fakeBackend) Mount(dir string, _ string, password string, options backend.MountOptions) (*exec.Cmd, error) {
+	b.options = options
+	if expected, ok := b.passwords[dir]; ok && password != expected {
+		return exec.Command("sh", "-c", fmt.Sprintf("exit %d", fakeExitPassword)), nil
+	}
+	return exec.Command("sh", "-c", b.script), nil
+}
+func (b *fakeBackend) MountError(rc int, _ string) error {
+	switch rc {
+	case 0:
+		return nil
+	case fakeExitPassword:
+		return &backend.ExitError{RC: rc, Err: backend.ErrWrongPassword}
+	}
+	return &backend.ExitError{RC: rc}
+}
+
+func (b *fakeBackend) Unmount(string, bool) error { return b.unmountErr }
+
+type autoLockTestSuite struct {
+	suite.Suite
+	m       *VaultManager
+	mock    sqlmock.Sqlmock
+	backend *fakeBackend
+	vault   models.Vault
+}
+
+func (s *autoLockTestSuite) SetupTest() {
+	if runtime.GOOS == "windows" {
+		s.T().Skip("fake backend is a shell script")
+	}
+	s.m, s.mock = newTestManager(s.T())
+	s.backend = useFakeBackend(s.T(), s.m)
+	s.vault = fakeVault(s.T(), 1)
+}
+
+func (s *autoLockTestSuite) AfterTest(_, _ string) {
+	// Audit events are recorded right after vaults got locked
+	s.Eventually(func() bool { return s.mock.ExpectationsWereMet() == nil }, time.Second, time.Millisecond*10)
+	s.Require().NoError(s.mock.ExpectationsWereMet())
+}
+
+// expectVault expects the vault to be looked up.
+// Expectations must be set before backends run, they might look up vaults themselves.
+func (s *autoLockTestSuite) expectVault() {
+	s.mock.ExpectQuery(`SELECT \* FROM vaults WHERE id = \?`).WithArgs(1).WillReturnRows(vaultRows(s.vault))
+}
+
+// mount unlocks the vault with the fake backend running `script`.
+func (s *autoLockTestSuite) mount(script string) {
+	s.backend.script = script
+	s.Require().NoError(s.m.MountVault(1, "secret"))
+}
+
+// expectAudit expects the auto-lock of the vault to be recorded.
+func (s *autoLockTestSuite) expectAudit() {
+	s.mock.ExpectExec(`INSERT INTO events(.+)`).
+		WithArgs(1, AuditAutoLock, JobSucceeded, 0, 0, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+}
+
+// expectEvent expects the next event published to be of given type, about given vault.
+func (s *autoLockTestSuite) expectEvent(events <-chan Event, eventType string, vaultId int64) Event {
+	event := s.next(events)
+	s.Equal(eventType, event.Type)
+	s.Equal(vaultId, event.VaultID)
+	return event
+}
+
+// next returns the next event published, failing if none comes in time.
+func (s *autoLockTestSuite) next(events <-chan Event) Event {
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(time.Second * 5):
+		s.FailNow("no event published")
+		return Event{}
+	}
+}
+
+func (s *autoLockTestSuite) Test_01_MaxLifetime() {
+	s.vault.MaxLifetime = 2
+	events, unsubscribe := s.m.Subscribe()
+	defer unsubscribe()
+	s.expectVault()
+	// Unmounting looks the vault up for its backend
+	s.expectVault()
+	s.expectAudit()
+	s.mount("trap 'exit 0' INT; sleep 30 > /dev/null 2>&1 & wait")
+	remaining, ok := s.m.RemainingLifetime(1)
+	s.Require().True(ok)
+	s.InDelta(time.Second*2, remaining, float64(time.Millisecond*500))
+	s.Equal(EventVaultUnlocking, s.next(events).Type)
+	s.Equal(EventVaultUnlocked, s.next(events).Type)
+
+	event := s.next(events)
+	s.Equal(EventVaultAutoLocked, event.Type)
+	s.Equal(map[string]string{"reason": "maxlifetime"}, event.Data)
+	s.Equal(EventVaultLocked, s.next(events).Type)
+	_, unlocked := s.m.MountPoint(1)
+	s.False(unlocked)
+	_, ok = s.m.RemainingLifetime(1)
+	s.False(ok)
+}
+
+func (s *autoLockTestSuite) Test_02_IdleTimeout() {
+	s.vault.IdleTimeout = 60
+	events, unsubscribe := s.m.Subscribe()
+	defer unsubscribe()
+	s.expectVault()
+	s.expectAudit()
+	// The backend unmounts the vault by itself after being idle
+	s.mount("sleep 1.5")
+	s.Equal(time.Minute, s.backend.options.IdleTimeout)
+	_, ok := s.m.RemainingLifetime(1)
+	s.False(ok)
+	s.Equal(EventVaultUnlocking, s.next(events).Type)
+	s.Equal(EventVaultUnlocked, s.next(events).Type)
+
+	event := s.next(events)
+	s.Equal(EventVaultAutoLocked, event.Type)
+	s.Equal(map[string]string{"reason": "idle"}, event.Data)
+	s.Equal(EventVaultLocked, s.next(events).Type)
+	_, unlocked := s.m.MountPoint(1)
+	s.False(unlocked)
+}
+
+func (s *autoLockTestSuite) Test_03_Locked() {
+	// Vaults locked by us are no auto-locks, even with an idle timeout
+	s.vault.IdleTimeout = 60
+	s.vault.MaxLifetime = 60
+	events, unsubscribe := s.m.Subscribe()
+	defer unsubscribe()
+	s.expectVault()
+	s.expectVault()
+	s.mount("trap 'exit 0' INT; sleep 30 > /dev/null 2>&1 & wait")
+	s.Require().NoError(s.m.LockVault(1, false))
+	_, ok := s.m.RemainingLifetime(1)
+	s.False(ok)
+	for _, expected := range []string{EventVaultUnlocking, EventVaultUnlocked, EventVaultLocked} {
+		s.Equal(expected, s.next(events).Type)
+	}
+}
+
+func (s *autoLockTestSuite) Test_04_Adopted() {
+	s.backend.unmountErr = nil
+	s.vault.MaxLifetime = 60
+	expired := s.vault
+	expired.ID = 2
+	events, unsubscribe := s.m.Subscribe()
+	defer unsubscribe()
+
+	// Lifetimes count from when the previous run unlocked vaults
+	s.m.lock.Lock()
+	s.m.adoptVault(s.vault, s.vault.MountPoint, time.Now().Add(-time.Second*20))
+	s.m.lock.Unlock()
+	remaining, ok := s.m.RemainingLifetime(1)
+	s.Require().True(ok)
+	s.InDelta(time.Second*40, remaining, float64(time.Second))
+	s.expectEvent(events, EventVaultUnlocked, 1)
+
+	// Vaults past their lifetime get locked right away
+	s.mock.ExpectQuery(`SELECT \* FROM vaults WHERE id = \?`).WithArgs(2).WillReturnRows(vaultRows(expired))
+	s.mock.ExpectExec(`INSERT INTO events(.+)`).
+		WithArgs(2, AuditAutoLock, JobSucceeded, 0, 0, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	s.m.lock.Lock()
+	s.m.adoptVault(expired, filepath.Join(s.T().TempDir(), "mnt"), time.Now().Add(-time.Minute*2))
+	s.m.lock.Unlock()
+	s.expectEvent(events, EventVaultUnlocked, 2)
+	s.Equal(map[string]string{"reason": "maxlifetime"}, s.expectEvent(events, EventVaultAutoLocked, 2).Data)
+	s.expectEvent(events, EventVaultLocked, 2)
+	_, unlocked := s.m.MountPoint(2)
+	s.False(unlocked)
+	_, unlocked = s.m.MountPoint(1)
+	s.True(unlocked)
+}
+
+func Test_AutoLock(t *testing.T) {
+	suite.Run(t, new(autoLockTestSuite))
+}
@@ -18,7 +18,8 @@ func (s *ApiServer) CheckRuntimeDeps(next echo.HandlerFunc) echo.HandlerFunc {
 		if !s.fuseAvailable {
 			return ErrMissingFuse
 		}
-		if s.cmd == "" {
+		// Vaults of other backends work without gocryptfs, but nothing works without any backend
+		if len(s.backends) == 0 {
 			return ErrMissingGocryptfsBinary
 		}
 		// All check passes, call next handler
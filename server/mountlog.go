@@ -0,0 +1,120 @@
+package server
+
+import (
+	"Cloak/extension"
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	mountLogLines      = 200     // lines of gocryptfs output kept for each vault
+	mountLogLineLength = 1024    // longer lines are truncated
+	debugLogMaxSize    = 4 << 20 // bytes, debug log files are rotated beyond this size
+	debugLogBackups    = 2       // rotated debug log files kept for each vault
+)
+
+// MountLog represents output of gocryptfs which unlocked a vault.
+type MountLog struct {
+	Lines    []string `json:"lines"`              // last lines of output, oldest first
+	DebugLog string   `json:"debuglog,omitempty"` // path to the debug log file, for vaults mounted in debug mode
+}
+
+// outputBuffer is an io.Writer keeping the last `mountLogLines` lines written to it.
+type outputBuffer struct {
+	lock    sync.Mutex
+	lines   []string
+	next    int          // index in `lines` to put the next line, once it's full
+	partial bytes.Buffer // incomplete last line
+}
+
+func newOutputBuffer() *outputBuffer {
+	return &outputBuffer{lines: make([]string, 0, mountLogLines)}
+}
+
+// Write splits `p` into lines and keeps them, dropping the oldest ones if necessary.
+func (b *outputBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for _, c := range p {
+		if c == '\n' {
+			b.push()
+			continue
+		}
+		if b.partial.Len() < mountLogLineLength {
+			b.partial.WriteByte(c)
+		}
+	}
+	return len(p), nil
+}
+
+// push moves the incomplete last line into `lines`.
+func (b *outputBuffer) push() {
+	line := b.partial.String()
+	b.partial.Reset()
+	if len(b.lines) < mountLogLines {
+		b.lines = append(b.lines, line)
+		return
+	}
+	b.lines[b.next] = line
+	b.next = (b.next + 1) % mountLogLines
+}
+
+// Lines returns kept lines from the oldest to the newest, including the incomplete last line.
+func (b *outputBuffer) Lines() []string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	lines := make([]string, 0, len(b.lines)+1)
+	lines = append(lines, b.lines[b.next:]...)
+	lines = append(lines, b.lines[:b.next]...)
+	if b.partial.Len() > 0 {
+		lines = append(lines, b.partial.String())
+	}
+	return lines
+}
+
+// Tail returns at most `n` of the newest lines.
+func (b *outputBuffer) Tail(n int) []string {
+	lines := b.Lines()
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
+
+// MountOutput returns the last lines of output of gocryptfs which unlocked given vault most recently.
+// The output is kept after the vault gets locked, until it's unlocked again.
+func (m *VaultManager) MountOutput(vaultId int64) []string {
+	m.lock.Lock()
+	output := m.outputs[vaultId]
+	m.lock.Unlock()
+	if output == nil {
+		return []string{}
+	}
+	return output.Lines()
+}
+
+// debugLogPath returns path to the debug log file of given vault.
+func debugLogPath(vaultId int64) string {
+	return filepath.Join(extension.GetLogDirectory(), fmt.Sprintf("gocryptfs-%d.log", vaultId))
+}
+
+// removeDebugLog removes debug log files of given vault, failures are logged only.
+func removeDebugLog(vaultId int64) {
+	if err := extension.RemoveRotatingFile(debugLogPath(vaultId)); err != nil {
+		logger.Warn().Err(err).
+			Int64("vaultId", vaultId).
+			Msg("Failed to remove debug log of vault")
+	}
+}
+
+// openDebugLog opens the debug log file of given vault for appending.
+func openDebugLog(vaultId int64) (*extension.RotatingFile, error) {
+	if _, err := extension.EnsureDirectoryExists(extension.GetLogDirectory()); err != nil {
+		return nil, err
+	}
+	return extension.OpenRotatingFile(debugLogPath(vaultId), debugLogMaxSize, debugLogBackups)
+}
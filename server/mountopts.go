@@ -0,0 +1,150 @@
+package server
+
+import (
+	"Cloak/backend"
+	"Cloak/models"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Types of values taken by mount options
+const (
+	mountOptionFlag   = iota // takes no value, e.g. `-allow_other`
+	mountOptionString        // a non-empty string without commas, e.g. `-fsname=work`
+	mountOptionOwner         // `uid:gid`, e.g. `-force_owner=1000:1000`
+	mountOptionFuse          // comma separated FUSE options from `fuseOptions`, e.g. `-ko=noatime,nodev`
+)
+
+// mountOptionTypes is the allow-list of gocryptfs flags which can be set per vault, along with their value types.
+// Flags controlled by other vault settings (e.g. `-ro`, `-reverse`) are deliberately left out.
+var mountOptionTypes = map[string]int{
+	"-allow_other":   mountOptionFlag,
+	"-force_owner":   mountOptionOwner,
+	"-kernel_cache":  mountOptionFlag,
+	"-sharedstorage": mountOptionFlag,
+	"-noprealloc":    mountOptionFlag,
+	"-nosyslog":      mountOptionFlag,
+	"-fsname":        mountOptionString,
+	"-ko":            mountOptionFuse,
+}
+
+// fuseOptions is the allow-list of FUSE options which can be passed through `-ko`.
+// Options loosening access to the vault (e.g. `allow_other`, `suid`, `dev`) are left out,
+// so are those controlled by other vault settings or mount options (e.g. `ro`, `fsname`).
+var fuseOptions = map[string]bool{
+	"noatime":             true,
+	"nodiratime":          true,
+	"relatime":            true,
+	"strictatime":         true,
+	"nodev":               true,
+	"nosuid":              true,
+	"noexec":              true,
+	"sync":                true,
+	"dirsync":             true,
+	"default_permissions": true,
+	// macFUSE only
+	"noappledouble": true,
+	"noapplexattr":  true,
+}
+
+var ownerPattern = regexp.MustCompile(`^[0-9]+:[0-9]+$`)
+
+// parseMountOption splits a mount option into its flag and value, e.g. `-fsname=work` into `-fsname` and `work`.
+// Leading dashes are optional, the flag always gets one.
+func parseMountOption(option string) (flag string, value string, hasValue bool) {
+	option = strings.TrimLeft(strings.TrimSpace(option), "-")
+	flag, value, hasValue = strings.Cut(option, "=")
+	return "-" + flag, value, hasValue
+}
+
+// CheckMountOptions validates given mount options against the allow-list, and makes sure they are supported by gocryptfs.
+// Options are returned in their normalized form `-flag` or `-flag=value`.
+// An `ErrInvalidMountOptions` is returned for unknown flags, malformed values or flags given twice.
+// Mount options are gocryptfs flags, so vaults of other backend types `kind` cannot have any.
+func (m *VaultManager) CheckMountOptions(kind string, options []string) (models.StringList, error) {
+	normalized := make(models.StringList, 0, len(options))
+	seen := make(map[string]bool)
+	for _, option := range options {
+		if strings.TrimSpace(option) == "" {
+			continue
+		}
+		if kind != "" && kind != backend.Gocryptfs {
+			return nil, ErrUnsupportedByBackend.Reformat(kind, "mount options")
+		}
+		flag, value, hasValue := parseMountOption(option)
+		valueType, ok := mountOptionTypes[flag]
+		if !ok {
+			return nil, ErrInvalidMountOptions.Reformat(fmt.Sprintf("%s is not allowed", flag))
+		}
+		if seen[flag] {
+			return nil, ErrInvalidMountOptions.Reformat(fmt.Sprintf("%s is given more than once", flag))
+		}
+		seen[flag] = true
+
+		switch valueType {
+		case mountOptionFlag:
+			if hasValue {
+				return nil, ErrInvalidMountOptions.Reformat(fmt.Sprintf("%s takes no value", flag))
+			}
+		case mountOptionString:
+			if value == "" || strings.ContainsAny(value, ",\t\r\n") {
+				return nil, ErrInvalidMountOptions.Reformat(fmt.Sprintf("%s must be a non-empty string without commas", flag))
+			}
+		case mountOptionOwner:
+			if !ownerPattern.MatchString(value) {
+				return nil, ErrInvalidMountOptions.Reformat(fmt.Sprintf("%s must be in uid:gid form", flag))
+			}
+		case mountOptionFuse:
+			for _, fuseOption := range strings.Split(value, ",") {
+				if !fuseOptions[fuseOption] {
+					return nil, ErrInvalidMountOptions.Reformat(fmt.Sprintf("%s has a FUSE option which is not allowed: %q", flag, fuseOption))
+				}
+			}
+		}
+
+		if !m.GocryptfsSupports(flag) {
+			logger.Error().Str("flag", flag).Msg("Flag not supported by gocryptfs")
+			return nil, ErrUnsupportedFeature.Reformat(flag)
+		}
+		if valueType == mountOptionFlag {
+			normalized = append(normalized, flag)
+		} else {
+			normalized = append(normalized, flag+"="+value)
+		}
+	}
+	return normalized, nil
+}
+
+// hasMountOption tells whether given flag is set in mount options.
+func hasMountOption(options []string, flag string) bool {
+	for _, option := range options {
+		if f, _, _ := parseMountOption(option); f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// volumeName makes given name safe to be used as a filesystem name, which ends up in comma separated FUSE options,
+// so commas are dropped.
+func volumeName(name string) string {
+	return strings.ReplaceAll(name, ",", "")
+}
+
+// mountArgs converts mount options checked by `CheckMountOptions` into gocryptfs arguments.
+// FUSE options of `-ko` are returned separately, since gocryptfs takes only one `-ko` and we might have our own.
+func mountArgs(options []string) (args []string, fuseOptions []string) {
+	for _, option := range options {
+		flag, value, hasValue := parseMountOption(option)
+		switch {
+		case flag == "-ko":
+			fuseOptions = append(fuseOptions, strings.Split(value, ",")...)
+		case hasValue:
+			args = append(args, flag, value)
+		default:
+			args = append(args, flag)
+		}
+	}
+	return
+}
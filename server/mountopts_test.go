@@ -0,0 +1,76 @@
+package server
+
+import (
+	"Cloak/backend"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type mountOptionsTestSuite struct {
+	suite.Suite
+	m *VaultManager
+}
+
+func (s *mountOptionsTestSuite) SetupTest() {
+	if runtime.GOOS == "windows" {
+		s.T().Skip("fake gocryptfs is a shell script")
+	}
+	// Flags are detected from the help text
+	cmd := filepath.Join(s.T().TempDir(), "gocryptfs")
+	script := "#!/bin/sh\necho '-allow_other -force_owner -kernel_cache -fsname -ko'\n"
+	s.Require().NoError(os.WriteFile(cmd, []byte(script), 0755))
+	s.m = &VaultManager{backends: map[string]backend.Backend{backend.Gocryptfs: backend.NewGocryptfs(cmd)}}
+}
+
+// apiErrorCode returns code of given ApiError, which is reformatted with details usually, or -1 if it's not one.
+func apiErrorCode(err error) int {
+	var apiError *ApiError
+	if errors.As(err, &apiError) {
+		return apiError.Code
+	}
+	return -1
+}
+
+func (s *mountOptionsTestSuite) Test_01_Normalize() {
+	options, err := s.m.CheckMountOptions("", []string{"allow_other", " -fsname=work ", "--ko=noatime,nodev", ""})
+	s.Require().NoError(err)
+	s.Equal([]string{"-allow_other", "-fsname=work", "-ko=noatime,nodev"}, []string(options))
+
+	args, fuse := mountArgs(options)
+	s.Equal([]string{"-allow_other", "-fsname", "work"}, args)
+	s.Equal([]string{"noatime", "nodev"}, fuse)
+}
+
+func (s *mountOptionsTestSuite) Test_02_Rejected() {
+	for _, options := range [][]string{
+		{"-ro"},
+		{"-allow_other=yes"},
+		{"-fsname=a,b"},
+		{"-force_owner=root"},
+		{"-kernel_cache", "-kernel_cache"},
+		// FUSE options loosening access to the vault
+		{"-ko=allow_other"},
+		{"-ko=noatime,suid"},
+		{"-ko=dev"},
+		{"-ko=fsname=other"},
+		{"-ko=noatime,"},
+	} {
+		_, err := s.m.CheckMountOptions("", options)
+		s.Equal(ErrInvalidMountOptions.Code, apiErrorCode(err), "%v", options)
+	}
+
+	// Allowed, but not supported by this gocryptfs
+	_, err := s.m.CheckMountOptions("", []string{"-sharedstorage"})
+	s.Equal(ErrUnsupportedFeature.Code, apiErrorCode(err))
+	_, err = s.m.CheckMountOptions(backend.CryFS, []string{"-ko=noatime"})
+	s.Equal(ErrUnsupportedByBackend.Code, apiErrorCode(err))
+}
+
+func Test_MountOptions(t *testing.T) {
+	suite.Run(t, new(mountOptionsTestSuite))
+}
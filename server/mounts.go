@@ -0,0 +1,276 @@
+package server
+
+import (
+	"Cloak/backend"
+	"Cloak/extension"
+	"Cloak/models"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// mountPointRegistry records mountpoint directories created by Cloak along with their vault IDs,
+// so that they can be cleaned up if Cloak exits without locking vaults (e.g. it crashed or got killed).
+type mountPointRegistry struct {
+	path string
+	lock sync.Mutex
+}
+
+// mountPointRecord tells which vault a mountpoint directory was created for, and when the vault got mounted there.
+type mountPointRecord struct {
+	VaultID int64     `json:"vaultId"`
+	Mounted time.Time `json:"mounted"`
+}
+
+// UnmarshalJSON accepts records of older versions too, which are vault IDs only.
+func (r *mountPointRecord) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &r.VaultID); err == nil {
+		return nil
+	}
+	type record mountPointRecord
+	return json.Unmarshal(data, (*record)(r))
+}
+
+// newMountPointRegistry creates a registry persisted in app data directory.
+func newMountPointRegistry() *mountPointRegistry {
+	return &mountPointRegistry{path: filepath.Join(extension.GetAppDataDirectory(), "mountpoints.json")}
+}
+
+// load returns recorded mountpoints, as mountPoint: record.
+func (r *mountPointRegistry) load() map[string]mountPointRecord {
+	records := make(map[string]mountPointRecord)
+	content, err := os.ReadFile(r.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warn().Err(err).Str("path", r.path).Msg("Failed to read mountpoint records")
+		}
+		return records
+	}
+	if err := json.Unmarshal(content, &records); err != nil {
+		logger.Warn().Err(err).Str("path", r.path).Msg("Mountpoint records are malformed, ignored")
+	}
+	return records
+}
+
+// update applies `fn` on recorded mountpoints and persists the result.
+func (r *mountPointRegistry) update(fn func(records map[string]mountPointRecord)) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	records := r.load()
+	fn(records)
+	content, err := json.Marshal(records)
+	if err == nil {
+		err = os.WriteFile(r.path, content, 0600)
+	}
+	if err != nil {
+		logger.Warn().Err(err).Str("path", r.path).Msg("Failed to write mountpoint records")
+	}
+}
+
+// add records a mountpoint directory created for given vault, which is being mounted there.
+func (r *mountPointRegistry) add(mountPoint string, vaultId int64) {
+	r.update(func(records map[string]mountPointRecord) {
+		records[mountPoint] = mountPointRecord{VaultID: vaultId, Mounted: time.Now()}
+	})
+}
+
+// remove forgets a mountpoint directory, usually after it got removed.
+func (r *mountPointRegistry) remove(mountPoint string) {
+	r.update(func(records map[string]mountPointRecord) {
+		delete(records, mountPoint)
+	})
+}
+
+// isMountAlive tells whether the filesystem mounted at given path still responds.
+// A FUSE mount whose process is gone fails with "transport endpoint is not connected".
+func isMountAlive(mountPoint string) bool {
+	_, err := os.Stat(mountPoint)
+	return err == nil || (!errors.Is(err, syscall.ENOTCONN) && !errors.Is(err, syscall.EIO))
+}
+
+// ReconcileMounts brings internal state in line with the system after Cloak restarted.
+// Vaults still mounted by a backend are adopted as unlocked, dead mounts are unmounted,
+// and mountpoint directories created by a previous run are removed.
+func (m *VaultManager) ReconcileMounts() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	mounts, err := extension.ListFuseMounts()
+	if err != nil {
+		logger.Debug().Err(err).Msg("Cannot list FUSE mounts, skipped reconciling")
+		return
+	}
+	vaults, err := m.repo.List(nil)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to list vaults, skipped reconciling")
+		return
+	}
+	vaultIds := make(map[string]int64, len(vaults))
+	mountPointIds := make(map[string]int64, len(vaults))
+	known := make(map[int64]models.Vault, len(vaults))
+	for _, vault := range vaults {
+		vaultIds[filepath.Clean(vault.Path)] = vault.ID
+		if vault.MountPoint != "" {
+			mountPointIds[filepath.Clean(vault.MountPoint)] = vault.ID
+		}
+		known[vault.ID] = vault
+	}
+	records := m.mountPointRecords.load()
+
+	mounted := make(map[string]bool, len(mounts))
+	for _, mount := range mounts {
+		if backend.FromFsType(mount.FsType) == "" {
+			continue
+		}
+		mounted[mount.MountPoint] = true
+
+		// The mount source is the vault path, unless the vault got mounted with `-fsname`,
+		// in which case we fall back to the recorded or configured mountpoint
+		record, recorded := records[mount.MountPoint]
+		vaultId, ok := vaultIds[filepath.Clean(mount.Source)]
+		if !ok && recorded {
+			_, ok = known[record.VaultID]
+			vaultId = record.VaultID
+		}
+		if !ok {
+			vaultId, ok = mountPointIds[filepath.Clean(mount.MountPoint)]
+		}
+		mountLog := logger.With().
+			Int64("vaultId", vaultId).
+			Str("vaultPath", mount.Source).
+			Str("mountPoint", mount.MountPoint).
+			Logger()
+
+		if !isMountAlive(mount.MountPoint) {
+			if !ok && !recorded {
+				mountLog.Debug().Msg("Dead vault mount not managed by Cloak, ignored")
+				continue
+			}
+			if err := extension.Unmount(mount.MountPoint, true); err != nil {
+				mountLog.Error().Err(err).Msg("Failed to unmount dead vault mount")
+				continue
+			}
+			mountLog.Info().Msg("Unmounted dead vault mount")
+			mounted[mount.MountPoint] = false
+			continue
+		}
+
+		if !ok {
+			mountLog.Debug().Msg("Vault mount does not belong to any vault, ignored")
+			continue
+		}
+		if _, unlocked := m.mountPoints[vaultId]; unlocked {
+			continue
+		}
+		// The max unlock lifetime counts from when the previous run mounted the vault, if it was recorded
+		since := time.Now()
+		if recorded && record.VaultID == vaultId && !record.Mounted.IsZero() {
+			since = record.Mounted
+		}
+		mountLog.Info().Time("mounted", since).Msg("Adopted vault left unlocked by a previous run")
+		m.adoptVault(known[vaultId], mount.MountPoint, since)
+	}
+
+	// Remove mountpoint directories which are no longer in use
+	for mountPoint := range records {
+		if mounted[mountPoint] {
+			continue
+		}
+		if err := os.Remove(mountPoint); err != nil && !os.IsNotExist(err) {
+			logger.Warn().Err(err).Str("mountPoint", mountPoint).Msg("Failed to remove stale mountpoint directory")
+			continue
+		}
+		logger.Info().Str("mountPoint", mountPoint).Msg("Removed stale mountpoint directory")
+		m.mountPointRecords.remove(mountPoint)
+	}
+
+	if len(m.mountPoints) > len(m.processes) {
+		go m.watchAdoptedMounts()
+	}
+}
+
+// adoptVault marks given vault unlocked at `mountPoint` by a previous run, which mounted it at `mounted`.
+// The vault gets locked right away if its max unlock lifetime has passed already.
+// `m.lock` must be held by the caller.
+func (m *VaultManager) adoptVault(vault models.Vault, mountPoint string, mounted time.Time) {
+	m.mountPoints[vault.ID] = mountPoint
+	m.events.Publish(Event{Type: EventVaultUnlocked, VaultID: vault.ID})
+	m.scheduleAutoLock(vault, mounted)
+}
+
+// isAdopted tells whether given vault was found unlocked by `ReconcileMounts`,
+// instead of being unlocked by a backend process we started.
+// `m.lock` must be held by the caller.
+func (m *VaultManager) isAdopted(vaultId int64) bool {
+	_, unlocked := m.mountPoints[vaultId]
+	_, started := m.processes[vaultId]
+	return unlocked && !started
+}
+
+// watchAdoptedMounts watches adopted vaults and cleans up after they got unmounted elsewhere,
+// since we cannot wait for processes we did not start.
+// It returns when no adopted vault is left.
+func (m *VaultManager) watchAdoptedMounts() {
+	ticker := time.NewTicker(time.Second * 5)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		mounts, err := extension.ListFuseMounts()
+		if err != nil {
+			logger.Error().Err(err).Msg("Failed to list FUSE mounts, stopped watching adopted vaults")
+			return
+		}
+		mounted := make(map[string]bool, len(mounts))
+		for _, mount := range mounts {
+			mounted[mount.MountPoint] = true
+		}
+
+		m.lock.Lock()
+		adopted := 0
+		for vaultId, mountPoint := range m.mountPoints {
+			if !m.isAdopted(vaultId) {
+				continue
+			}
+			if mounted[mountPoint] {
+				adopted++
+				continue
+			}
+			logger.Info().
+				Int64("vaultId", vaultId).
+				Str("mountPoint", mountPoint).
+				Msg("Adopted vault got unmounted")
+			m.releaseAdopted(vaultId)
+		}
+		m.lock.Unlock()
+
+		if adopted == 0 {
+			return
+		}
+	}
+}
+
+// releaseAdopted forgets an adopted vault which is no longer mounted.
+// `m.lock` must be held by the caller.
+func (m *VaultManager) releaseAdopted(vaultId int64) {
+	mountPoint := m.mountPoints[vaultId]
+	delete(m.mountPoints, vaultId)
+	m.cancelAutoLock(vaultId)
+
+	// Remove mountpoint directory if we created it
+	if _, recorded := m.mountPointRecords.load()[mountPoint]; recorded {
+		if err := os.Remove(mountPoint); err != nil && !os.IsNotExist(err) {
+			logger.Error().Err(err).
+				Int64("vaultId", vaultId).
+				Str("mountPoint", mountPoint).
+				Msg("Failed to remove mountpoint directory")
+		} else {
+			m.mountPointRecords.remove(mountPoint)
+		}
+	}
+	m.events.Publish(Event{Type: EventVaultLocked, VaultID: vaultId})
+}
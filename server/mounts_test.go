@@ -0,0 +1,41 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type mountsTestSuite struct {
+	suite.Suite
+	records *mountPointRegistry
+}
+
+func (s *mountsTestSuite) SetupTest() {
+	s.records = &mountPointRegistry{path: filepath.Join(s.T().TempDir(), "mountpoints.json")}
+}
+
+func (s *mountsTestSuite) Test_01_Records() {
+	s.Empty(s.records.load())
+	s.records.add("/tmp/1a2b", 1)
+	s.records.add("/tmp/3c4d", 2)
+	s.records.remove("/tmp/3c4d")
+	records := s.records.load()
+	s.Require().Len(records, 1)
+	s.EqualValues(1, records["/tmp/1a2b"].VaultID)
+	s.WithinDuration(time.Now(), records["/tmp/1a2b"].Mounted, time.Second)
+}
+
+func (s *mountsTestSuite) Test_02_LegacyRecords() {
+	// Older versions recorded vault IDs only
+	s.Require().NoError(os.WriteFile(s.records.path, []byte(`{"/tmp/1a2b": 3}`), 0600))
+	records := s.records.load()
+	s.Equal(map[string]mountPointRecord{"/tmp/1a2b": {VaultID: 3}}, records)
+}
+
+func Test_Mounts(t *testing.T) {
+	suite.Run(t, new(mountsTestSuite))
+}
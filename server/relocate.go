@@ -0,0 +1,176 @@
+package server
+
+import (
+	"Cloak/backend"
+	"Cloak/gocryptfs"
+	"Cloak/models"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	pathVarsPrefix       = "pathvars." // config keys of path variables, i.e. the `[pathvars]` section
+	relocateDefaultDepth = 4           // directory levels searched under each root by default
+	relocateMaxDepth     = 8
+)
+
+// pathVariables returns variables usable in vault paths: `HOME`, plus those set in the `[pathvars]` config section.
+func (m *VaultManager) pathVariables() models.PathVariables {
+	vars := make(models.PathVariables)
+	for key, value := range m.config.All() {
+		if name, ok := strings.CutPrefix(key, pathVarsPrefix); ok && value != "" {
+			vars[name] = value
+		}
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		vars["HOME"] = home
+	}
+	return vars
+}
+
+// checkPathVariable validates a path variable to set, and returns its cleaned path.
+// `HOME` can't be set, an empty path unsets the variable.
+func checkPathVariable(name string, path string) (string, error) {
+	if !models.IsValidPathVariableName(name) || name == "HOME" {
+		return "", ErrInvalidPathVariable.Reformat(fmt.Sprintf("%q is not a valid name", name))
+	}
+	if path == "" {
+		return "", nil
+	}
+	if !filepath.IsAbs(path) {
+		return "", ErrInvalidPathVariable.Reformat(fmt.Sprintf("%s must be an absolute path", name))
+	}
+	return filepath.Clean(path), nil
+}
+
+// storedVaultPath returns the path to store for a vault at `path`, which was given as `given` by users.
+// Variables are kept if users referred to any, otherwise the path is contracted with configured variables.
+// `HOME` is left out then, so paths only refer to variables users opted in to.
+func (m *VaultManager) storedVaultPath(given string, path string) string {
+	if strings.Contains(given, "${") {
+		return filepath.Clean(given)
+	}
+	vars := m.pathVariables()
+	delete(vars, "HOME")
+	return vars.Contract(path)
+}
+
+// vaultConfigPath returns path to the config file of given vault, which depends on its backend.
+func vaultConfigPath(vault models.Vault) string {
+	return filepath.Join(vault.Path, backend.ConfigName(vault.Backend, vault.Reverse))
+}
+
+// vaultMissing tells whether the config of given vault is gone, most likely along with the vault directory.
+func vaultMissing(vault models.Vault) bool {
+	_, err := os.Stat(vaultConfigPath(vault))
+	return os.IsNotExist(err)
+}
+
+// vaultIdentity returns identity of the vault of backend type `kind` in given directory,
+// or an empty string if its config can't be loaded. Only gocryptfs configs tell identities.
+func vaultIdentity(kind string, dir string, reverse bool) string {
+	if kind != "" && kind != backend.Gocryptfs {
+		return ""
+	}
+	conf, err := gocryptfs.LoadVault(dir, reverse)
+	if err != nil {
+		return ""
+	}
+	return conf.Identity()
+}
+
+// recordIdentity updates the stored identity of given vault, which changes along with its password.
+func (m *VaultManager) recordIdentity(vault models.Vault) {
+	identity := vaultIdentity(vault.Backend, vault.Path, vault.Reverse)
+	if identity == "" || identity == vault.Identity {
+		return
+	}
+	vault.Identity = identity
+	if err := m.repo.Update(&vault, nil); err != nil {
+		logger.Warn().Err(err).
+			Int64("vaultId", vault.ID).
+			Msg("Failed to record vault identity")
+	}
+}
+
+// RecordIdentities records identities of vaults added before identities were introduced.
+func (m *VaultManager) RecordIdentities() {
+	vaults, err := m.repo.List(nil)
+	if err != nil {
+		logger.Warn().Err(err).Msg("Failed to list vaults for recording identities")
+		return
+	}
+	for _, vault := range vaults {
+		if vault.Identity == "" {
+			m.recordIdentity(vault)
+		}
+	}
+}
+
+// SearchVault searches `roots` for the directory of given vault, at most `depth` levels deep.
+// Directories containing a config of the same backend and mode are candidates,
+// the one matching the vault identity is returned, or the only one if the identity isn't known yet.
+// Symbolic links are not followed, neither are hidden directories or vault directories descended into.
+// Directories of other known vaults are never candidates.
+func (m *VaultManager) SearchVault(vault models.Vault, roots []string, depth int) (string, error) {
+	vaults, err := m.repo.List(nil)
+	if err != nil {
+		return "", err
+	}
+	known := make(map[string]bool)
+	for _, v := range vaults {
+		if v.ID != vault.ID {
+			known[v.Path] = true
+		}
+	}
+
+	confName := filepath.Base(vaultConfigPath(vault))
+	var candidates []string
+	for _, root := range roots {
+		root = filepath.Clean(root)
+		_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || !d.IsDir() {
+				// Unreadable directories are skipped
+				return nil
+			}
+			if path != root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			if known[path] {
+				return filepath.SkipDir
+			}
+			if info, err := os.Stat(filepath.Join(path, confName)); err == nil && info.Mode().IsRegular() {
+				candidates = append(candidates, path)
+				return filepath.SkipDir
+			}
+			if rel, _ := filepath.Rel(root, path); rel != "." && strings.Count(rel, string(filepath.Separator))+1 >= depth {
+				return filepath.SkipDir
+			}
+			return nil
+		})
+	}
+
+	var matches []string
+	for _, candidate := range candidates {
+		if vault.Identity == "" || vaultIdentity(vault.Backend, candidate, vault.Reverse) == vault.Identity {
+			matches = append(matches, candidate)
+		}
+	}
+	logger.Debug().
+		Int64("vaultId", vault.ID).
+		Strs("roots", roots).
+		Strs("candidates", candidates).
+		Strs("matches", matches).
+		Msg("Searched for vault")
+	switch len(matches) {
+	case 0:
+		return "", ErrVaultNotFound
+	case 1:
+		return matches[0], nil
+	default:
+		return "", ErrVaultAmbiguous.WrapList(matches)
+	}
+}
@@ -0,0 +1,24 @@
+package server
+
+import (
+	"Cloak/models"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type relocateTestSuite struct {
+	suite.Suite
+}
+
+func (s *relocateTestSuite) Test_01_ConfigPath() {
+	// Vaults added before backends were introduced have no backend stored
+	s.Equal("/vaults/a/gocryptfs.conf", vaultConfigPath(models.Vault{Path: "/vaults/a"}))
+	s.Equal("/vaults/b/.gocryptfs.reverse.conf", vaultConfigPath(models.Vault{Path: "/vaults/b", Backend: "gocryptfs", Reverse: true}))
+	s.Equal("/vaults/c/cryfs.config", vaultConfigPath(models.Vault{Path: "/vaults/c", Backend: "cryfs"}))
+	s.Equal("/vaults/d/.securefs.json", vaultConfigPath(models.Vault{Path: "/vaults/d", Backend: "securefs"}))
+}
+
+func Test_Relocate(t *testing.T) {
+	suite.Run(t, new(relocateTestSuite))
+}
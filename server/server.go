@@ -1,25 +1,34 @@
 package server
 
 import (
+	"Cloak/backend"
+	"Cloak/config"
 	"Cloak/extension"
+	"Cloak/gocryptfs"
 	"Cloak/i18n"
+	"Cloak/importer"
+	"Cloak/instance"
 	"Cloak/models"
 	"Cloak/version"
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/labstack/gommon/random"
 	"io/fs"
 	"io/ioutil"
 	"net/http"
 	"os"
-	"os/exec"
 	"os/user"
 	"path/filepath"
+	"regexp"
 	"runtime"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
@@ -38,44 +47,34 @@ func init() {
 // - Controls and reacts to gocryptfs processes;
 // - Maintains the vault database;
 type ApiServer struct {
-	VaultManager
+	*VaultManager
 	echo  *echo.Echo // the actual HTTP server
 	token string
 }
 
 // NewApiServer creates a new ApiServer instance
 // - repo passes in the vault repository to persist vault list data
-func NewApiServer(repo *models.VaultRepo, releaseMode bool, configCh chan map[string]string) *ApiServer {
+// - cfg passes in the app config, which is read-only for the server
+func NewApiServer(repo *models.VaultRepo, cfg *config.Configurator, releaseMode bool, configCh chan map[string]string) *ApiServer {
 	// Create server
 	server := ApiServer{
-		echo: echo.New(),
-		VaultManager: VaultManager{
-			repo:          repo,
-			fuseAvailable: extension.IsFuseAvailable(),
-			processes:     make(map[int64]*exec.Cmd),
-			mountPoints:   make(map[int64]string),
-			configCh:      configCh,
-		},
+		echo:         echo.New(),
+		VaultManager: NewVaultManager(repo, cfg, releaseMode, configCh),
 		// Generate a random token on startup, for API access
 		token: random.String(64),
 	}
 
 	// Detect external runtime dependencies
-	var err error
-	if server.cmd, err = extension.LocateBinary("gocryptfs"); err != nil {
-		logger.Error().Err(err).
-			Msg("Failed to locate gocryptfs binary, nothing will work")
-	} else {
-		logger.Debug().Str("gocryptfs", server.cmd).Msg("Gocryptfs binary located")
-	}
-	if server.xrayCmd, err = extension.LocateBinary("gocryptfs-xray"); err != nil {
-		logger.Error().Err(err).
-			Msg("Failed to locate gocryptfs-xray binary, masterkey revealing will not work")
-	} else {
-		logger.Debug().Str("gocryptfs-xray", server.xrayCmd).Msg("gocryptfs-xray binary located")
-	}
+	server.locateBackends()
 	logger.Debug().Bool("fuseAvailable", server.fuseAvailable).Msg("FUSE detection finished")
 
+	// Vault paths might refer to path variables
+	repo.SetPathVariables(server.pathVariables)
+
+	// Pick up vaults left unlocked by a previous run, and clean up after it
+	server.ReconcileMounts()
+	server.RecordIdentities()
+
 	// Setup HTTP server
 	server.echo.HideBanner = true
 	server.echo.HidePort = true
@@ -107,14 +106,34 @@ func NewApiServer(repo *models.VaultRepo, releaseMode bool, configCh chan map[st
 	APIs are located at /api:
 	- GET /vaults: get a list of all known vaults
 	- POST /vaults: create or add a vault
-	  - op=create: create a new vault
-	  - op=add: add an existing vault to Cloak app
+	  - op=create: create a new vault, gocryptfs features can be chosen with `options`.
+	    With `options.reverse`, the existing plaintext directory at `path` becomes a reverse vault.
+	    `backend` is the tool encrypting the vault, gocryptfs by default, or cryfs/securefs.
+	  - op=add: add an existing vault to Cloak app, its backend is recognized by the config file at `path`
 	- POST /vault/N: operate on a vault
 	  - op=update: update vault information
 	  - op=unlock: unlock a vault, pass password with `pw`
-	  - op=lock: lock a vault
+	  - op=lock: lock a vault, pass `force` to unmount it even if files are still open
 	  - op=reveal: reveal mountpoint in file manager, only available if vault is unlocked
 	- DELETE /vault/N: delete a vault from Cloak. Files are reserved on disk.
+	- GET /vault/N/info: unencrypted metadata from gocryptfs.conf of a vault
+	- POST /vault/N/tags: replace tags of a vault
+	- POST /vault/N/relocate: search `roots` for a moved vault, at most `depth` levels deep, and update its path
+	- GET /groups: get a list of all groups along with their vaults
+	- POST /groups: create a group, pass its `name` and `vaults`
+	- POST /group/N: operate on a group
+	  - op=update: rename the group, or replace its vaults
+	  - op=unlock: unlock all its vaults with one password `password`, results are reported per vault
+	  - op=lock: lock all its vaults, pass `force` to unmount busy vaults
+	- DELETE /group/N: delete a group, its vaults are kept
+	- GET /events: stream of vault events (Server-Sent Events)
+	- GET /export: vaults along with their options, tags & groups, plus app settings, as a JSON file
+	- POST /import: import an exported `document`, `mode` is either `merge` (default) or `replace`,
+	  nothing is changed with `dryrun` but what would change is responded
+	- POST /import/sirikali, /import/vaults: add vaults saved by other front-ends, only those in `cipherdirs` unless empty.
+	  With `dryrun`, found vaults are responded along with whether they can be added
+	- GET /audit: audit log of vault operations, filtered by `vault`/`op`/`outcome`/`since`/`until`, paginated by `page`/`limit`.
+	  With `format=csv` or `format=json`, it's exported as a file.
 	*/
 	if !releaseMode {
 		logger.Warn().
@@ -139,7 +158,10 @@ func NewApiServer(repo *models.VaultRepo, releaseMode bool, configCh chan map[st
 		return func(c echo.Context) error {
 			// Check token
 			if c.Request().Header.Get("Authorization") != fmt.Sprintf("Bearer %s", server.token) {
-				return ErrUnauthorized
+				// `EventSource` in browsers cannot set headers, so allow passing token via query string
+				if c.Path() != "/api/events" || c.QueryParam("token") != server.token {
+					return ErrUnauthorized
+				}
 			}
 			return next(c)
 		}
@@ -154,12 +176,47 @@ func NewApiServer(repo *models.VaultRepo, releaseMode bool, configCh chan map[st
 		apis.POST("/vault/:id/options", server.UpdateVaultOptions)
 		// Change vault password
 		apis.POST("/vault/:id/password", server.ChangeVaultPassword)
+		// Forget vault password stored in system keyring
+		apis.DELETE("/vault/:id/password", server.ForgetVaultPassword)
+		// Set vault tags
+		apis.POST("/vault/:id/tags", server.UpdateVaultTags)
+		// Show unencrypted vault metadata
+		apis.GET("/vault/:id/info", server.GetVaultInfo)
+		// Show output of gocryptfs which unlocked the vault most recently
+		apis.GET("/vault/:id/log", server.GetVaultLog)
+		// Find a moved vault and update its path
+		apis.POST("/vault/:id/relocate", server.RelocateVault)
+		// Check vault integrity in background / show its progress or latest result / cancel it
+		apis.POST("/vault/:id/fsck", server.StartVaultFsck)
+		apis.GET("/vault/:id/fsck", server.GetVaultFsck)
+		apis.DELETE("/vault/:id/fsck", server.CancelVaultFsck)
+		// Re-wrap vault masterkey with a stronger scrypt cost
+		apis.POST("/vault/:id/kdf", server.UpgradeVaultKDF)
 		// Reveal vault masterkey
 		apis.POST("/vault/:id/masterkey", server.RevealVaultMasterkey)
+		// Vault groups / unlock, lock or update a group / delete a group
+		apis.GET("/groups", server.ListVaultGroups)
+		apis.POST("/groups", server.CreateVaultGroup)
+		apis.POST("/group/:id", server.OperateOnGroup)
+		apis.DELETE("/group/:id", server.RemoveVaultGroup)
 		// List local disk content
 		apis.POST("/subpaths", server.ListSubPaths)
+		// Background jobs / show a job / cancel a job
+		apis.GET("/jobs", server.ListJobs)
+		apis.GET("/jobs/:id", server.GetJob)
+		apis.DELETE("/jobs/:id", server.CancelJob)
+		// Audit log of vault operations, optionally exported as CSV/JSON
+		apis.GET("/audit", server.ListAuditEvents)
+		// Suggest scrypt cost for new vaults
+		apis.GET("/kdf/benchmark", server.BenchmarkKDF)
+		// Move vaults & settings to another computer
+		apis.GET("/export", server.ExportVaults)
+		apis.POST("/import", server.ImportVaults)
+		apis.POST("/import/:source", server.ImportForeignVaults)
 		apis.GET("/options", server.GetOptions)
 		apis.POST("/options", server.SetOptions)
+		// Stream vault events
+		apis.GET("/events", server.StreamEvents)
 	}
 
 	return &server
@@ -169,8 +226,23 @@ func (s *ApiServer) GetAccessUrl() string {
 	return fmt.Sprintf("http://127.0.0.1:9763/#token=%s", s.token)
 }
 
+// GetVaultUrl returns URL of the UI with given vault selected.
+func (s *ApiServer) GetVaultUrl(vaultId int64) string {
+	return fmt.Sprintf("%s&vault=%d", s.GetAccessUrl(), vaultId)
+}
+
 // Start starts the server
+// Instance info is published so that local clients like `cloakctl` can find this server.
 func (s *ApiServer) Start(address string) error {
+	if err := instance.Write(instance.Info{
+		Address: address,
+		Token:   s.token,
+		PID:     os.Getpid(),
+	}); err != nil {
+		logger.Warn().Err(err).
+			Str("instanceFile", instance.FilePath()).
+			Msg("Failed to write instance file, cloakctl will not be able to find this instance")
+	}
 	return s.echo.Start(address)
 }
 
@@ -179,24 +251,17 @@ func (s *ApiServer) Start(address string) error {
 func (s *ApiServer) Stop() error {
 	logger.Debug().Msg("Requested to stop API server")
 
-	// Lock internal maps
-	s.lock.Lock()
-	defer s.lock.Unlock()
+	// Stop running jobs, then lock all unlocked vaults
+	s.jobs.CancelAll()
+	s.LockAllVaults("", nil)
 
-	// Lock all unlocked vaults
-	for vaultId, mountPoint := range s.mountPoints {
-		logger.Debug().
-			Int64("vaultId", vaultId).
-			Str("mountPoint", mountPoint).
-			Msg("Locking vault")
+	// Close event streams, otherwise the server waits for them on shutdown
+	s.events.Close()
 
-		// stop corresponding gocryptfs process to lock this vault
-		if err := s.processes[vaultId].Process.Signal(os.Interrupt); err != nil {
-			logger.Error().Err(err).
-				Int64("vaultId", vaultId).
-				Str("mountPoint", mountPoint).
-				Msg("Failed to stop gocryptfs process with SIGINT")
-		}
+	if err := instance.Remove(); err != nil {
+		logger.Warn().Err(err).
+			Str("instanceFile", instance.FilePath()).
+			Msg("Failed to remove instance file")
 	}
 
 	// Shutdown the server
@@ -208,7 +273,12 @@ func (s *ApiServer) Stop() error {
 // VaultInfo represents a single vault along with its current state
 type VaultInfo struct {
 	models.Vault
-	State string `json:"state"` // Legal values: locked/unlocked
+	State     string `json:"state"`               // Legal values: locked/unlocked
+	Remaining int64  `json:"remaining,omitempty"` // seconds left before the vault gets locked automatically
+	Missing   bool   `json:"missing,omitempty"`   // the vault directory is gone, it can be relocated
+
+	Groups []models.Group `json:"groups,omitempty"` // groups the vault belongs to, filled when listing vaults
+	Tags   []string       `json:"tags,omitempty"`   // filled when listing vaults
 }
 
 // ListVaults returns a list of all known vaults
@@ -220,13 +290,22 @@ func (s *ApiServer) ListVaults(_ echo.Context) error {
 	if vaults, err = s.repo.List(nil); err != nil {
 		return ErrListFailed
 	}
+	vaultGroups, vaultTags, err := s.vaultGrouping()
+	if err != nil {
+		return ErrListFailed
+	}
 
 	vaultList := make([]VaultInfo, len(vaults))
 	for i, v := range vaults {
-		vaultList[i] = VaultInfo{Vault: v, State: "locked"}
+		vaultList[i] = VaultInfo{Vault: v, State: "locked", Groups: vaultGroups[v.ID], Tags: vaultTags[v.ID]}
 		// Detect vault state
 		if _, ok := s.mountPoints[v.ID]; ok {
 			vaultList[i].State = "unlocked"
+			if remaining, ok := s.RemainingLifetime(v.ID); ok {
+				vaultList[i].Remaining = int64(remaining.Round(time.Second) / time.Second)
+			}
+		} else {
+			vaultList[i].Missing = vaultMissing(v)
 		}
 	}
 	return ErrOk.WrapList(vaultList)
@@ -244,7 +323,8 @@ func (s *ApiServer) OperateOnVault(c echo.Context) error {
 
 	var form struct {
 		Op       string `json:"op"`       // lock/unlock/reveal/update
-		Password string `json:"password"` // for `unlock` op only
+		Password string `json:"password"` // for `unlock` op only, the stored password is used if it's empty
+		Force    bool   `json:"force"`    // for `lock` op only, unmount lazily even if the vault is busy
 	}
 	if err := c.Bind(&form); err != nil {
 		return ErrMalformedInput
@@ -252,21 +332,20 @@ func (s *ApiServer) OperateOnVault(c echo.Context) error {
 
 	switch form.Op {
 	case "unlock":
-		if err := s.GocryptfsUnlockVault(vaultId, form.Password); err != nil {
+		err := s.UnlockVault(vaultId, form.Password)
+		s.Audit(vaultId, AuditUnlock, err)
+		if err != nil {
 			return err
 		}
 		return ErrOk.WrapState("unlocked")
 	case "lock":
-		// Check current state
-		if _, ok := s.mountPoints[vaultId]; !ok {
-			return ErrVaultAlreadyLocked.WrapState("locked")
-		}
-
-		// Lock internal maps
-		s.lock.Lock()
-		defer s.lock.Unlock()
-		// stop corresponding gocryptfs process to lock this vault
-		if err := s.processes[vaultId].Process.Signal(os.Interrupt); err != nil {
+		// unmount this vault and wait for the corresponding gocryptfs process to exit
+		err := s.LockVault(vaultId, form.Force)
+		s.Audit(vaultId, AuditLock, err)
+		if err != nil {
+			if err == ErrVaultAlreadyLocked {
+				return ErrVaultAlreadyLocked.WrapState("locked")
+			}
 			return err
 		}
 		// We have a pairing gorountine to wait for gocryptfs process to exit and do the cleanup,
@@ -313,13 +392,37 @@ func (s *ApiServer) UpdateVaultOptions(c echo.Context) error {
 	}
 
 	var form struct {
-		AutoReveal bool   `json:"autoreveal"`
-		ReadOnly   bool   `json:"readonly"`
-		Mountpoint string `json:"mountpoint"`
+		AutoReveal  bool   `json:"autoreveal"`
+		ReadOnly    bool   `json:"readonly"`
+		Mountpoint  string `json:"mountpoint"`
+		IdleTimeout *int64 `json:"idletimeout"` // optional, seconds
+		MaxLifetime *int64 `json:"maxlifetime"` // optional, seconds
+		LockSuspend *bool  `json:"locksuspend"` // optional
+
+		RememberPassword *bool `json:"rememberpassword"` // optional, turning it off forgets the stored password
+		DebugMount       *bool `json:"debugmount"`       // optional, run the backend with debug output, e.g. gocryptfs `-debug`
+
+		MountOptions *[]string `json:"mountoptions"` // optional, gocryptfs vaults only, checked against an allow-list
+
+		Name  *string `json:"name"`  // optional, display name, empty to show the directory name
+		Notes *string `json:"notes"` // optional
+		Icon  *string `json:"icon"`  // optional, colour or icon key
+
+		Exclude         *[]string `json:"exclude"`         // optional, reverse vaults only
+		ExcludeWildcard *[]string `json:"excludewildcard"` // optional, reverse vaults only
 	}
 	if err := c.Bind(&form); err != nil {
 		return ErrMalformedInput
 	}
+	if (form.IdleTimeout != nil && *form.IdleTimeout < 0) || (form.MaxLifetime != nil && *form.MaxLifetime < 0) {
+		return ErrMalformedInput
+	}
+	if form.Name != nil {
+		*form.Name = strings.TrimSpace(*form.Name)
+	}
+	if !validVaultLabels(form.Name, form.Notes, form.Icon) {
+		return ErrMalformedInput
+	}
 
 	// Lock internal maps
 	s.lock.Lock()
@@ -341,20 +444,167 @@ func (s *ApiServer) UpdateVaultOptions(c echo.Context) error {
 			State: "unlocked",
 		})
 	}
+	if err := s.checkVaultIdle(vaultId); err != nil {
+		return err
+	}
+	// gocryptfs supports exclusion in reverse mode only
+	if !vault.Reverse && ((form.Exclude != nil && len(*form.Exclude) > 0) ||
+		(form.ExcludeWildcard != nil && len(*form.ExcludeWildcard) > 0)) {
+		return ErrMalformedInput
+	}
+	// Not all backends unmount idle vaults by themselves
+	if form.IdleTimeout != nil && *form.IdleTimeout > 0 && !backend.FeaturesOf(vault.Backend).IdleTimeout {
+		return ErrUnsupportedByBackend.Reformat(vault.Backend, "idle timeout")
+	}
+	var mountOptions models.StringList
+	if form.MountOptions != nil {
+		if mountOptions, err = s.CheckMountOptions(vault.Backend, *form.MountOptions); err != nil {
+			return err
+		}
+	}
 
+	wasRemembered := vault.RememberPassword
 	if err := s.repo.WithTransaction(func(tx models.Transactional) error {
 		vault.AutoReveal = form.AutoReveal
 		vault.ReadOnly = form.ReadOnly
 		vault.MountPoint = strings.TrimSpace(form.Mountpoint)
+		if form.IdleTimeout != nil {
+			vault.IdleTimeout = *form.IdleTimeout
+		}
+		if form.MaxLifetime != nil {
+			vault.MaxLifetime = *form.MaxLifetime
+		}
+		if form.LockSuspend != nil {
+			vault.LockSuspend = *form.LockSuspend
+		}
+		if form.RememberPassword != nil {
+			vault.RememberPassword = *form.RememberPassword
+		}
+		if form.DebugMount != nil {
+			vault.DebugMount = *form.DebugMount
+		}
+		if form.MountOptions != nil {
+			vault.MountOptions = mountOptions
+		}
+		if form.Name != nil {
+			vault.Name = *form.Name
+		}
+		if form.Notes != nil {
+			vault.Notes = *form.Notes
+		}
+		if form.Icon != nil {
+			vault.Icon = *form.Icon
+		}
+		if form.Exclude != nil {
+			vault.Exclude = *form.Exclude
+		}
+		if form.ExcludeWildcard != nil {
+			vault.ExcludeWildcard = *form.ExcludeWildcard
+		}
 		return s.repo.Update(&vault, tx)
 	}); err != nil {
 		return err
 	}
-	return ErrOk.WrapItem(VaultInfo{State: "locked", Vault: vault})
+	if wasRemembered && !vault.RememberPassword {
+		// The option is saved anyway, the user could retry forgetting the password
+		if err := s.ForgetPassword(vault); err != nil {
+			return err
+		}
+	}
+	vaultInfo := VaultInfo{State: "locked", Vault: vault}
+	s.events.Publish(Event{Type: EventVaultOptionsChanged, VaultID: vaultId, Data: vaultInfo})
+	return ErrOk.WrapItem(vaultInfo)
+}
+
+// Limits of vault display names, notes and icon keys
+const (
+	maxVaultNameLength  = 64   // in characters
+	maxVaultNotesLength = 4096 // in bytes
+)
+
+var iconPattern = regexp.MustCompile(`^[a-z0-9-]{0,32}$`)
+
+// validVaultLabels checks display name, notes and icon key of a vault, nil ones are skipped.
+// Names end up as filesystem names, so they must be single-line.
+func validVaultLabels(name, notes, icon *string) bool {
+	if name != nil {
+		if utf8.RuneCountInString(*name) > maxVaultNameLength {
+			return false
+		}
+		for _, r := range *name {
+			if unicode.IsControl(r) {
+				return false
+			}
+		}
+	}
+	if notes != nil && len(*notes) > maxVaultNotesLength {
+		return false
+	}
+	return icon == nil || iconPattern.MatchString(*icon)
+}
+
+// GetVaultInfo returns unencrypted metadata of given vault, read from its gocryptfs config.
+// The vault can be either locked or unlocked, other backends keep their configs encrypted.
+func (s *ApiServer) GetVaultInfo(c echo.Context) error {
+	// Pre-check on ID
+	vaultId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return ErrMalformedInput
+	}
+
+	// Locate vault in repository
+	vault, err := s.repo.Get(vaultId, nil)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrVaultNotExist
+		}
+		return err
+	}
+	if err := requireGocryptfs(vault, "vault info"); err != nil {
+		return err
+	}
+
+	conf, err := gocryptfs.LoadVault(vault.Path, vault.Reverse)
+	if err != nil {
+		logger.Error().Err(err).
+			Int64("vaultId", vaultId).
+			Str("vaultPath", vault.Path).
+			Bool("reverse", vault.Reverse).
+			Msg("Failed to load vault config")
+		return confError(err)
+	}
+	return ErrOk.WrapItem(conf.Info())
+}
+
+// GetVaultLog returns the last lines of output of the backend process which unlocked given vault most recently,
+// along with path to the debug log file if the vault is mounted in debug mode.
+func (s *ApiServer) GetVaultLog(c echo.Context) error {
+	// Pre-check on ID
+	vaultId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return ErrMalformedInput
+	}
+
+	// Locate vault in repository
+	vault, err := s.repo.Get(vaultId, nil)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrVaultNotExist
+		}
+		return err
+	}
+
+	mountLog := MountLog{Lines: s.MountOutput(vaultId)}
+	if vault.DebugMount {
+		mountLog.DebugLog = debugLogPath(vaultId)
+	}
+	return ErrOk.WrapItem(mountLog)
 }
 
-// ChangeVaultPassword changes password for given vault
-func (s *ApiServer) ChangeVaultPassword(c echo.Context) error {
+// RelocateVault searches given directories for a vault which was moved, and updates its path.
+// The found directory must hold a config of the vault backend, matching the vault identity for gocryptfs vaults,
+// vaults without a known identity are only relocated if exactly one candidate is found.
+func (s *ApiServer) RelocateVault(c echo.Context) error {
 	// Pre-check on ID
 	vaultId, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
@@ -362,17 +612,25 @@ func (s *ApiServer) ChangeVaultPassword(c echo.Context) error {
 	}
 
 	var form struct {
-		Password    string `json:"password"`  // optional, either `password` or `masterkey` will do
-		MasterKey   string `json:"masterkey"` // optional, either `password` or `masterkey` will do
-		NewPassword string `json:"newpassword"`
+		Roots []string `json:"roots"`
+		Depth int      `json:"depth"` // optional, directory levels searched under each root
 	}
 	if err := c.Bind(&form); err != nil {
 		return ErrMalformedInput
 	}
-
-	// Lock internal maps
-	s.lock.Lock()
-	defer s.lock.Unlock()
+	if form.Depth == 0 {
+		form.Depth = relocateDefaultDepth
+	}
+	if len(form.Roots) == 0 || form.Depth < 0 || form.Depth > relocateMaxDepth {
+		return ErrMalformedInput
+	}
+	vars := s.pathVariables()
+	for i, root := range form.Roots {
+		form.Roots[i] = vars.Expand(root)
+		if !filepath.IsAbs(form.Roots[i]) {
+			return ErrMalformedInput
+		}
+	}
 
 	// Locate vault in repository
 	vault, err := s.repo.Get(vaultId, nil)
@@ -382,160 +640,599 @@ func (s *ApiServer) ChangeVaultPassword(c echo.Context) error {
 		}
 		return err
 	}
+	path, err := s.SearchVault(vault, form.Roots, form.Depth)
+	if err != nil {
+		return err
+	}
 
-	// Vault must be locked to update its settings
+	// Lock internal maps
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// Vault must be locked to change its path
 	if _, ok := s.mountPoints[vaultId]; ok {
 		return ErrVaultAlreadyUnlocked.WrapItem(VaultInfo{
 			Vault: vault,
 			State: "unlocked",
 		})
 	}
+	if err := s.checkVaultIdle(vaultId); err != nil {
+		return err
+	}
+	logger.Info().
+		Int64("vaultId", vaultId).
+		Str("from", vault.Path).
+		Str("to", path).
+		Msg("Relocating vault")
+	vault.Path = s.storedVaultPath(path, path)
+	vault.PathTemplate = ""
+	if vault.Identity == "" {
+		vault.Identity = vaultIdentity(vault.Backend, path, vault.Reverse)
+	}
+	if err := s.repo.Update(&vault, nil); err != nil {
+		return err
+	}
+	if vault, err = s.repo.Get(vaultId, nil); err != nil {
+		return err
+	}
+	vaultInfo := VaultInfo{Vault: vault, State: "locked"}
+	s.events.Publish(Event{Type: EventVaultOptionsChanged, VaultID: vaultId, Data: vaultInfo})
+	return ErrOk.WrapItem(vaultInfo)
+}
 
-	// Start a gocryptfs process to change password
-	if form.Password != "" {
-		if err = s.GocryptfsChangeVaultPassword(vault.Path, form.Password, form.NewPassword); err != nil {
-			return err
-		}
-	} else if form.MasterKey != "" {
-		if err = s.GocryptfsResetVaultPassword(vault.Path, form.MasterKey, form.NewPassword); err != nil {
-			return err
-		}
-	} else {
-		return ErrMalformedInput
+// confError converts errors from loading gocryptfs configs to ApiError.
+func confError(err error) *ApiError {
+	switch {
+	case errors.Is(err, gocryptfs.ErrConfMalformed):
+		return ErrMalformedVaultConf.Reformat(err)
+	case errors.Is(err, gocryptfs.ErrConfReverse), errors.Is(err, gocryptfs.ErrConfNotReverse):
+		return ErrVaultConfMismatch.Reformat(err)
+	default:
+		return ErrCantOpenVaultConf
 	}
-	return ErrOk
 }
 
-// RemoveVault removes vault specified by ID from database repository,
-// the corresponding directory remains on the disk.
-func (s *ApiServer) RemoveVault(c echo.Context) error {
+// ChangeVaultPassword changes password for given vault
+func (s *ApiServer) ChangeVaultPassword(c echo.Context) error {
 	// Pre-check on ID
 	vaultId, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
 		return ErrMalformedInput
 	}
 
-	// Lock internal maps
-	s.lock.Lock()
-	defer s.lock.Unlock()
-	// Lock vault if necessary
-	// stop corresponding gocryptfs process to lock this vault
-	if _, ok := s.mountPoints[vaultId]; ok {
-		if err := s.processes[vaultId].Process.Signal(os.Interrupt); err != nil {
-			return err
-		}
+	var form struct {
+		Password    string `json:"password"`  // optional, either `password` or `masterkey` will do
+		MasterKey   string `json:"masterkey"` // optional, either `password` or `masterkey` will do
+		NewPassword string `json:"newpassword"`
+		Async       bool   `json:"async"` // optional, run as a job
+	}
+	if err := c.Bind(&form); err != nil {
+		return ErrMalformedInput
+	}
+	if form.Password == "" && form.MasterKey == "" {
+		return ErrMalformedInput
 	}
 
-	var vault models.Vault
-	err = s.repo.WithTransaction(func(tx models.Transactional) error {
-		vault, err = s.repo.Get(vaultId, tx)
+	operation := AuditPasswordChange
+	if form.Password == "" {
+		operation = AuditPasswordReset
+	}
+
+	// The vault is reserved while the backend process runs, instead of holding `s.lock` for that long
+	vault, err := s.reserveLockedVault(vaultId, "password", nil)
+	if err != nil {
+		s.Audit(vaultId, operation, err)
+		return err
+	}
+	return s.runMaybeAsync(form.Async, "password", vaultId, func(ctx context.Context, _ func(int)) (interface{}, error) {
+		// Start a backend process to change password
+		var err error
+		if form.Password != "" {
+			err = s.ChangePassword(ctx, vault, form.Password, form.NewPassword, 0)
+		} else {
+			err = s.GocryptfsResetVaultPassword(ctx, vault, form.MasterKey, form.NewPassword)
+		}
+		s.Audit(vaultId, operation, err)
 		if err != nil {
-			// Vault ID not found
-			if err == sql.ErrNoRows {
-				return ErrVaultNotExist
-			}
-			return err
+			return nil, err
 		}
-		return s.repo.Delete(&vault, tx)
+		// Keep the stored password and identity in sync
+		s.rememberPassword(vault, form.NewPassword)
+		s.recordIdentity(vault)
+		return nil, nil
 	})
+}
+
+// ForgetVaultPassword deletes the password of given vault stored in system keyring.
+// The `rememberpassword` option is kept, so the password gets stored again on next unlock if it's on.
+func (s *ApiServer) ForgetVaultPassword(c echo.Context) error {
+	// Pre-check on ID
+	vaultId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return ErrMalformedInput
+	}
+
+	// Locate vault in repository
+	vault, err := s.repo.Get(vaultId, nil)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrVaultNotExist
+		}
+		return err
+	}
+	if err := s.ForgetPassword(vault); err != nil {
 		return err
 	}
-	// Deleted
-	logger.Debug().
-		Int64("vaultId", vaultId).
-		Str("vaultPath", vault.Path).
-		Msg("Vault removed. It is no longer managed by Cloak.")
 	return ErrOk
 }
 
-// AddOrCreateVault adds an existing gocryptfs vault to the repository,
-// Or it creates a new vault at specified location (not currently supported).
-// When adding an existing vault `path` will be the absolute path of `gocryptfs.conf`;
-// When creating a new vault `path` will be the parent directory of the new vault.
-func (s *ApiServer) AddOrCreateVault(c echo.Context) error {
+// StartVaultFsck starts checking integrity of given locked vault in background.
+// The password is optional if it's stored in system keyring.
+func (s *ApiServer) StartVaultFsck(c echo.Context) error {
+	// Pre-check on ID
+	vaultId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return ErrMalformedInput
+	}
+
 	var form struct {
-		Op       string `json:"op"` // add/create
-		Path     string `json:"path"`
-		Name     string `json:"name"`     // optional, only when op=create
-		Password string `json:"password"` // optional, only when op=create
+		Password string `json:"password"`
 	}
 	if err := c.Bind(&form); err != nil {
 		return ErrMalformedInput
 	}
 
-	switch form.Op {
-	case "add":
-		// Check path existence
-		if pathInfo, err := os.Stat(form.Path); err != nil || pathInfo.IsDir() {
-			return ErrPathNotExist
-		}
-		vaultPath := filepath.Dir(form.Path)
+	status, err := s.StartFsck(vaultId, form.Password)
+	if err != nil {
+		return err
+	}
+	return ErrOk.WrapItem(status)
+}
 
-		var err error
-		var vault models.Vault
-		err = s.repo.WithTransaction(func(tx models.Transactional) error {
-			vault, err = s.repo.Create(echo.Map{"path": vaultPath}, tx)
-			if err != nil {
-				logger.Error().Err(err).
-					Str("vaultPath", vaultPath).
-					Msg("Failed to add existing vault")
-			}
-			return err
-		})
-		if err != nil {
-			return err
-		}
-		logger.Debug().
-			Str("vaultPath", vaultPath).
-			Int64("vaultId", vault.ID).
-			Msg("Added existing vault")
-		return ErrOk.WrapItem(VaultInfo{
-			Vault: vault,
-			State: "locked",
-		})
-	case "create":
-		// Check path existence
-		if pathInfo, err := os.Stat(form.Path); err != nil || !pathInfo.IsDir() {
-			return ErrPathNotExist
-		}
-		vaultPath := filepath.Join(form.Path, form.Name)
-		if err := os.Mkdir(vaultPath, 0700); err != nil {
-			logger.Error().Err(err).
-				Str("vaultDirectroy", form.Path).
-				Str("vaultName", form.Name).
-				Msg("Failed to create vault directory")
-			return ErrVaultMkdirFailed.Reformat(err)
+// GetVaultFsck returns progress of the running check of given vault, or its latest result.
+func (s *ApiServer) GetVaultFsck(c echo.Context) error {
+	// Pre-check on ID
+	vaultId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return ErrMalformedInput
+	}
+
+	status, err := s.GetFsck(vaultId)
+	if err != nil {
+		return err
+	}
+	if status == nil {
+		return ErrOk
+	}
+	return ErrOk.WrapItem(status)
+}
+
+// CancelVaultFsck cancels the running check of given vault.
+func (s *ApiServer) CancelVaultFsck(c echo.Context) error {
+	// Pre-check on ID
+	vaultId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return ErrMalformedInput
+	}
+
+	status, err := s.CancelFsck(vaultId)
+	if err != nil {
+		return err
+	}
+	return ErrOk.WrapItem(status)
+}
+
+// UpgradeVaultKDF re-wraps the masterkey of given vault with scrypt cost `scryptn`, keeping its password.
+// The vault must be locked. Config values before and after the upgrade are returned.
+func (s *ApiServer) UpgradeVaultKDF(c echo.Context) error {
+	// Pre-check on ID
+	vaultId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return ErrMalformedInput
+	}
+
+	var form struct {
+		Password string `json:"password"`
+		ScryptN  int    `json:"scryptn"` // scrypt cost parameter as logarithm
+		Async    bool   `json:"async"`   // optional, run as a job
+	}
+	if err := c.Bind(&form); err != nil || form.Password == "" {
+		return ErrMalformedInput
+	}
+	if form.ScryptN < gocryptfs.ScryptMinLogN || form.ScryptN > gocryptfs.ScryptMaxLogN {
+		return ErrInvalidScryptN.Reformat(fmt.Sprintf("scryptn must be between %d and %d", gocryptfs.ScryptMinLogN, gocryptfs.ScryptMaxLogN))
+	}
+
+	// The vault is reserved while scrypt runs, instead of holding `s.lock` for that long
+	var before *gocryptfs.Conf
+	vault, err := s.reserveLockedVault(vaultId, "upgrade_kdf", func(vault models.Vault) error {
+		if err := requireGocryptfs(vault, "scrypt cost"); err != nil {
+			return err
+		}
+		conf, err := gocryptfs.LoadVault(vault.Path, vault.Reverse)
+		if err != nil {
+			return confError(err)
 		}
+		// An upgrade never weakens the key derivation
+		if form.ScryptN <= conf.ScryptObject.LogN() {
+			return ErrInvalidScryptN.Reformat(fmt.Sprintf("scryptn must be greater than the current one (%d)", conf.ScryptObject.LogN()))
+		}
+		before = conf
+		return nil
+	})
+	if err != nil {
+		s.Audit(vaultId, AuditKDFUpgrade, err)
+		return err
+	}
+	return s.runMaybeAsync(form.Async, "upgrade_kdf", vaultId, func(ctx context.Context, _ func(int)) (interface{}, error) {
+		err := s.ChangePassword(ctx, vault, form.Password, form.Password, form.ScryptN)
+		s.Audit(vaultId, AuditKDFUpgrade, err)
+		if err != nil {
+			return nil, err
+		}
+		after, err := gocryptfs.LoadVault(vault.Path, vault.Reverse)
+		if err != nil {
+			return nil, confError(err)
+		}
+		s.recordIdentity(vault)
+		logger.Debug().
+			Int64("vaultId", vaultId).
+			Int("fromScryptN", before.ScryptObject.LogN()).
+			Int("toScryptN", after.ScryptObject.LogN()).
+			Msg("Upgraded KDF of vault")
+		return echo.Map{
+			"before": before.Info(),
+			"after":  after.Info(),
+		}, nil
+	})
+}
+
+// fallbackScryptMemory caps memory used by suggested scrypt costs, when available memory is unknown.
+const fallbackScryptMemory = 1 << 30
+
+// BenchmarkKDF times scrypt on this computer and suggests the scrypt cost for new vaults,
+// so that unlocking takes about `target` milliseconds (1000 by default) without using too much memory.
+func (s *ApiServer) BenchmarkKDF(c echo.Context) error {
+	target := 1000
+	if value := c.QueryParam("target"); value != "" {
+		var err error
+		if target, err = strconv.Atoi(value); err != nil || target <= 0 {
+			return ErrMalformedInput
+		}
+	}
+
+	// Measure with a cost cheap enough to be quick, the rest is extrapolated
+	const logN = gocryptfs.ScryptMinLogN + 4
+	var measured time.Duration
+	for i := 0; i < 3; i++ {
+		duration, err := gocryptfs.BenchmarkScrypt(logN)
+		if err != nil {
+			return err
+		}
+		if measured == 0 || duration < measured {
+			measured = duration
+		}
+	}
+	// Unlocking should never make the computer swap, so only half of available memory is used
+	maxMemory := int64(fallbackScryptMemory)
+	if available, err := extension.AvailableMemory(); err == nil {
+		maxMemory = available / 2
+	} else {
+		logger.Debug().Err(err).Msg("Cannot tell available memory, scrypt cost is capped by a fallback")
+	}
+	suggested := gocryptfs.SuggestScryptLogN(time.Duration(target)*time.Millisecond, logN, measured, maxMemory)
+	var estimated time.Duration
+	if suggested >= logN {
+		estimated = measured << (suggested - logN)
+	} else {
+		estimated = measured >> (logN - suggested)
+	}
+	return ErrOk.WrapItem(echo.Map{
+		"target":    target,
+		"scryptn":   suggested,
+		"estimated": estimated.Milliseconds(),
+		"memory":    gocryptfs.ScryptMemory(suggested),
+	})
+}
+
+// RemoveVault removes vault specified by ID from database repository,
+// the corresponding directory remains on the disk.
+func (s *ApiServer) RemoveVault(c echo.Context) error {
+	// Pre-check on ID
+	vaultId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return ErrMalformedInput
+	}
+
+	// Lock vault if necessary
+	if err := s.LockVault(vaultId, false); err != nil && err != ErrVaultAlreadyLocked {
+		return err
+	}
+	if _, err := s.CancelFsck(vaultId); err != nil && err != ErrFsckNotRunning {
+		return err
+	}
+	// Jobs of the vault are useless now, they release the vault once cancelled
+	s.jobs.CancelVault(vaultId)
 
-		err := s.GocryptfsCreateVault(vaultPath, form.Password)
+	// Lock internal maps
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// Operations running synchronously can't be cancelled
+	if err := s.checkVaultIdle(vaultId); err != nil {
+		return err
+	}
+
+	var vault models.Vault
+	err = s.repo.WithTransaction(func(tx models.Transactional) error {
+		vault, err = s.repo.Get(vaultId, tx)
 		if err != nil {
+			// Vault ID not found
+			if err == sql.ErrNoRows {
+				return ErrVaultNotExist
+			}
+			return err
+		}
+		if err := s.repo.DeleteFsckResults(vaultId, tx); err != nil {
+			return err
+		}
+		if err := s.repo.DeleteVaultGrouping(vaultId, tx); err != nil {
 			return err
 		}
+		return s.repo.Delete(&vault, tx)
+	})
+	if err != nil {
+		return err
+	}
+	// Stored password is useless now
+	if vault.RememberPassword {
+		_ = s.ForgetPassword(vault)
+	}
+	delete(s.outputs, vaultId)
+	removeDebugLog(vaultId)
+	// Deleted
+	logger.Debug().
+		Int64("vaultId", vaultId).
+		Str("vaultPath", vault.Path).
+		Msg("Vault removed. It is no longer managed by Cloak.")
+	s.events.Publish(Event{Type: EventVaultRemoved, VaultID: vaultId})
+	return ErrOk
+}
+
+// AddOrCreateVault adds an existing vault to the repository,
+// Or it creates a new vault at specified location (not currently supported).
+// When adding an existing vault `path` will be the absolute path of its config, e.g. `gocryptfs.conf` or `cryfs.config`;
+// When creating a new vault `path` will be the parent directory of the new vault.
+func (s *ApiServer) AddOrCreateVault(c echo.Context) error {
+	var form struct {
+		Op       string `json:"op"` // add/create
+		Path     string `json:"path"`
+		Name     string `json:"name"`     // optional, only when op=create
+		Password string `json:"password"` // optional, only when op=create
+		Backend  string `json:"backend"`  // optional, only when op=create, gocryptfs by default
+
+		Options         CreateOptions `json:"options"`         // optional, only when op=create
+		Async           bool          `json:"async"`           // optional, only when op=create, run as a job
+		Exclude         []string      `json:"exclude"`         // optional, only when op=create for reverse vaults
+		ExcludeWildcard []string      `json:"excludewildcard"` // optional, only when op=create for reverse vaults
+	}
+	if err := c.Bind(&form); err != nil {
+		return ErrMalformedInput
+	}
+
+	switch form.Op {
+	case "add":
+		// Check path existence, the path might refer to path variables
+		confPath := s.pathVariables().Expand(form.Path)
+		if pathInfo, err := os.Stat(confPath); err != nil || pathInfo.IsDir() {
+			return ErrPathNotExist
+		}
+		vaultPath := filepath.Dir(confPath)
+		values := echo.Map{"path": s.storedVaultPath(filepath.Dir(form.Path), vaultPath)}
+		// Backends and reverse vaults are recognized by their config file, gocryptfs is assumed for unknown ones
+		kind, reverse := backend.ParseConfigName(filepath.Base(confPath))
+		if kind == "" {
+			kind = backend.Gocryptfs
+		}
+		values["backend"] = kind
+		if reverse {
+			values["reverse"] = true
+			values["readonly"] = true
+		}
+		// Make sure it's a usable gocryptfs config, configs of other backends are encrypted
+		if kind == backend.Gocryptfs {
+			conf, err := gocryptfs.Load(confPath)
+			if err != nil {
+				logger.Error().Err(err).
+					Str("confPath", confPath).
+					Msg("Failed to load config of existing vault")
+				return confError(err)
+			}
+			values["identity"] = conf.Identity()
+		}
 
-		// Vault created, add to vault repository
 		var vault models.Vault
-		if err := s.repo.WithTransaction(func(tx models.Transactional) error {
-			vault, err = s.repo.Create(echo.Map{"path": vaultPath}, tx)
+		err := s.repo.WithTransaction(func(tx models.Transactional) (err error) {
+			vault, err = s.repo.Create(values, tx)
 			if err != nil {
 				logger.Error().Err(err).
 					Str("vaultPath", vaultPath).
-					Msg("Failed to add newly created vault")
+					Msg("Failed to add existing vault")
 			}
 			return err
-		}); err != nil {
+		})
+		if err != nil {
 			return err
 		}
 		logger.Debug().
 			Str("vaultPath", vaultPath).
 			Int64("vaultId", vault.ID).
-			Msg("Added newly created vault")
-		return ErrOk.WrapItem(VaultInfo{Vault: vault, State: "locked"})
+			Msg("Added existing vault")
+		vaultInfo := VaultInfo{Vault: vault, State: "locked"}
+		s.events.Publish(Event{Type: EventVaultAdded, VaultID: vault.ID, Data: vaultInfo})
+		return ErrOk.WrapItem(vaultInfo)
+	case "create":
+		// Check path existence, the path might refer to path variables
+		parentPath := s.pathVariables().Expand(form.Path)
+		if pathInfo, err := os.Stat(parentPath); err != nil || !pathInfo.IsDir() {
+			return ErrPathNotExist
+		}
+		if form.Backend == "" {
+			form.Backend = backend.Gocryptfs
+		}
+		if _, err := s.backend(form.Backend); err != nil {
+			return err
+		}
+		if err := s.CheckCreateOptions(form.Backend, form.Options); err != nil {
+			return err
+		}
+		if !form.Options.Reverse && (len(form.Exclude) > 0 || len(form.ExcludeWildcard) > 0) {
+			return ErrInvalidCreateOptions.Reformat("exclude and excludewildcard are for reverse vaults only")
+		}
+
+		vaultPath := filepath.Join(parentPath, form.Name)
+		values := echo.Map{
+			"path":    s.storedVaultPath(filepath.Join(form.Path, form.Name), vaultPath),
+			"backend": form.Backend,
+		}
+		if form.Options.Reverse {
+			// Reverse vaults are created in place for existing plaintext directories
+			vaultPath = filepath.Clean(parentPath)
+			values = echo.Map{
+				"path":            s.storedVaultPath(form.Path, vaultPath),
+				"backend":         form.Backend,
+				"reverse":         true,
+				"readonly":        true,
+				"exclude":         form.Exclude,
+				"excludewildcard": form.ExcludeWildcard,
+			}
+		} else if err := os.Mkdir(vaultPath, 0700); err != nil {
+			logger.Error().Err(err).
+				Str("vaultDirectroy", parentPath).
+				Str("vaultName", form.Name).
+				Msg("Failed to create vault directory")
+			return ErrVaultMkdirFailed.Reformat(err)
+		}
+
+		return s.runMaybeAsync(form.Async, "create", 0, func(ctx context.Context, _ func(int)) (interface{}, error) {
+			err := s.InitVault(ctx, form.Backend, vaultPath, form.Password, form.Options)
+			if err != nil {
+				return nil, err
+			}
+
+			// Vault created, add to vault repository
+			values["identity"] = vaultIdentity(form.Backend, vaultPath, form.Options.Reverse)
+			var vault models.Vault
+			if err := s.repo.WithTransaction(func(tx models.Transactional) error {
+				vault, err = s.repo.Create(values, tx)
+				if err != nil {
+					logger.Error().Err(err).
+						Str("vaultPath", vaultPath).
+						Msg("Failed to add newly created vault")
+				}
+				return err
+			}); err != nil {
+				return nil, err
+			}
+			logger.Debug().
+				Str("vaultPath", vaultPath).
+				Int64("vaultId", vault.ID).
+				Msg("Added newly created vault")
+			vaultInfo := VaultInfo{Vault: vault, State: "locked"}
+			s.events.Publish(Event{Type: EventVaultAdded, VaultID: vault.ID, Data: vaultInfo})
+			return vaultInfo, nil
+		})
 	default:
 		return ErrUnsupportedOperation
 	}
 }
 
+// reserveLockedVault locates given vault and reserves it for an operation of `kind` which rewrites its config,
+// the vault must be locked and idle. `check` might reject the vault further, it runs with `s.lock` held.
+// The reserved vault should be passed to `runMaybeAsync`, which releases it once the operation finished.
+func (s *ApiServer) reserveLockedVault(vaultId int64, kind string, check func(vault models.Vault) error) (models.Vault, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// Locate vault in repository
+	vault, err := s.repo.Get(vaultId, nil)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return vault, ErrVaultNotExist
+		}
+		return vault, err
+	}
+
+	// Vault must be locked to update its config
+	if _, ok := s.mountPoints[vaultId]; ok {
+		return vault, ErrVaultAlreadyUnlocked.WrapItem(VaultInfo{
+			Vault: vault,
+			State: "unlocked",
+		})
+	}
+	if check != nil {
+		if err := check(vault); err != nil {
+			return vault, err
+		}
+	}
+	return vault, s.reserveVault(vaultId, kind)
+}
+
+// runMaybeAsync runs `f` right away and responds its result, or runs it as a job if `async` is true,
+// in which case the queued job is responded instead. `s.lock` must not be held by the caller.
+// A vault given by `vaultId` must be reserved by the caller with `reserveVault`, it's released once `f` finished.
+func (s *ApiServer) runMaybeAsync(async bool, kind string, vaultId int64, f JobFunc) error {
+	var release func()
+	if vaultId != 0 {
+		release = func() { s.releaseVault(vaultId) }
+	}
+	if async {
+		return ErrOk.WrapItem(s.jobs.SubmitWithCleanup(kind, vaultId, f, release))
+	}
+	if release != nil {
+		defer release()
+	}
+	result, err := f(context.Background(), func(int) {})
+	if err != nil {
+		return err
+	}
+	if result == nil {
+		return ErrOk
+	}
+	return ErrOk.WrapItem(result)
+}
+
+// ListJobs lists all known jobs, the latest first.
+func (s *ApiServer) ListJobs(_ echo.Context) error {
+	return ErrOk.WrapList(s.jobs.List())
+}
+
+// GetJob returns the job of given ID.
+func (s *ApiServer) GetJob(c echo.Context) error {
+	jobId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return ErrMalformedInput
+	}
+	job, ok := s.jobs.Get(jobId)
+	if !ok {
+		return ErrJobNotExist
+	}
+	return ErrOk.WrapItem(job)
+}
+
+// CancelJob cancels the job of given ID, and waits for it to stop.
+func (s *ApiServer) CancelJob(c echo.Context) error {
+	jobId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return ErrMalformedInput
+	}
+	job, err := s.jobs.Cancel(jobId)
+	if err != nil {
+		return err
+	}
+	return ErrOk.WrapItem(job)
+}
+
 // ListSubPaths lists items in given path.
 // - `pwd` identifies the path to use, the special value `$HOME` translates to home directory of current user.
 func (s *ApiServer) ListSubPaths(c echo.Context) error {
@@ -574,8 +1271,9 @@ func (s *ApiServer) ListSubPaths(c echo.Context) error {
 	for _, item := range items {
 		var subItem Item
 		subItem.Name = item.Name()
-		// Skip hidden items
-		if subItem.Name[0] == '.' {
+		// Skip hidden items, except config files of vaults, e.g. `.securefs.json`
+		configKind, _ := backend.ParseConfigName(subItem.Name)
+		if subItem.Name[0] == '.' && configKind == "" {
 			continue
 		}
 
@@ -614,8 +1312,8 @@ func (s *ApiServer) ListSubPaths(c echo.Context) error {
 		if item.IsDir() {
 			subItem.Type = "directory"
 		} else {
-			// We are not interested in files other than `gocryptfs.conf`
-			if subItem.Name != "gocryptfs.conf" {
+			// We are not interested in files other than vault configs, e.g. `gocryptfs.conf`
+			if configKind == "" {
 				continue
 			}
 			subItem.Type = "file"
@@ -644,39 +1342,115 @@ func (s *ApiServer) GetOptions(c echo.Context) error {
 			"gitCommit": version.GitCommit,
 		},
 		"options": echo.Map{
-			"locale":   i18n.GetLocalizer().GetCurrentLocale(),
-			"loglevel": strings.ToUpper(zerolog.GlobalLevel().String()),
+			"locale":        i18n.GetLocalizer().GetCurrentLocale(),
+			"loglevel":      strings.ToUpper(zerolog.GlobalLevel().String()),
+			"lockonsuspend": s.config.Get("lockonsuspend") == "true",
+			"notifications": s.config.Get("notifications") != "false",
+			"pathvars":      s.pathVariables(),
 		},
 	})
 }
 
 // SetOptions persists application options.
-// Currently the only available option is `locale`.
 func (s *ApiServer) SetOptions(c echo.Context) error {
 	var appOption struct {
-		Locale   string `json:"locale"`
-		LogLevel string `json:"loglevel"`
+		Locale        string `json:"locale"`
+		LogLevel      string `json:"loglevel"`
+		LockOnSuspend *bool  `json:"lockonsuspend"`
+		Notifications *bool  `json:"notifications"`
+
+		PathVars map[string]string `json:"pathvars"` // path variables to set, empty paths unset them
 	}
 	if err := c.Bind(&appOption); err != nil {
 		return ErrMalformedInput
 	}
 
+	changes := make(map[string]string)
 	if appOption.Locale != "" && appOption.Locale != i18n.GetLocalizer().GetCurrentLocale() {
-		s.configCh <- map[string]string{"locale": appOption.Locale}
+		changes["locale"] = appOption.Locale
 	}
 
 	if appOption.LogLevel != "" && appOption.LogLevel != zerolog.GlobalLevel().String() {
-		s.configCh <- map[string]string{"loglevel": appOption.LogLevel}
+		changes["loglevel"] = appOption.LogLevel
+	}
+
+	if appOption.LockOnSuspend != nil {
+		changes["lockonsuspend"] = strconv.FormatBool(*appOption.LockOnSuspend)
+	}
+
+	if appOption.Notifications != nil {
+		changes["notifications"] = strconv.FormatBool(*appOption.Notifications)
+	}
+
+	for name, path := range appOption.PathVars {
+		path, err := checkPathVariable(name, path)
+		if err != nil {
+			return err
+		}
+		changes[pathVarsPrefix+name] = path
+	}
+
+	if len(changes) > 0 {
+		s.configCh <- changes
 	}
 	return ErrOk
 }
 
-// RevealVaultMasterkey returns masterkey for given vault.
-func (s *ApiServer) RevealVaultMasterkey(c echo.Context) error {
-	if s.xrayCmd == "" {
-		return ErrMissingGocryptfsXrayBinary
+// ExportVaults responds vaults along with their options, tags & groups, plus app settings, as a JSON file.
+func (s *ApiServer) ExportVaults(c echo.Context) error {
+	doc, err := s.Export()
+	if err != nil {
+		return err
+	}
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="cloak-export.json"`)
+	return c.JSON(http.StatusOK, doc)
+}
+
+// ImportVaults imports vaults, groups & app settings from a document responded by `ExportVaults`.
+func (s *ApiServer) ImportVaults(c echo.Context) error {
+	var form struct {
+		Mode     string         `json:"mode"`   // merge/replace, merge by default
+		DryRun   bool           `json:"dryrun"` // optional, only tell what would change
+		Document ExportDocument `json:"document"`
+	}
+	if err := c.Bind(&form); err != nil {
+		return ErrMalformedInput
+	}
+	if form.Mode != "" && form.Mode != "merge" && form.Mode != "replace" {
+		return ErrMalformedInput
+	}
+
+	summary, err := s.Import(form.Document, form.Mode == "replace", form.DryRun)
+	if err != nil {
+		return err
+	}
+	return ErrOk.WrapItem(summary)
+}
+
+// ImportForeignVaults adds vaults saved by other gocryptfs front-ends, or previews them.
+func (s *ApiServer) ImportForeignVaults(c echo.Context) error {
+	source := c.Param("source")
+	if source != importer.SourceSiriKali && source != importer.SourceVaults {
+		return ErrUnsupportedOperation
+	}
+
+	var form struct {
+		CipherDirs []string `json:"cipherdirs"` // optional, vault directories to add, all addable ones if empty
+		DryRun     bool     `json:"dryrun"`     // optional, only list found vaults
+	}
+	if err := c.Bind(&form); err != nil {
+		return ErrMalformedInput
+	}
+
+	vaults, err := s.ImportForeign(source, form.CipherDirs, form.DryRun)
+	if err != nil {
+		return err
 	}
+	return ErrOk.WrapList(vaults)
+}
 
+// RevealVaultMasterkey returns masterkey for given vault.
+func (s *ApiServer) RevealVaultMasterkey(c echo.Context) error {
 	// Pre-check on ID
 	vaultId, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
@@ -692,18 +1466,182 @@ func (s *ApiServer) RevealVaultMasterkey(c echo.Context) error {
 		return ErrMalformedInput
 	}
 
+	// The config must not change while it's being decrypted
+	s.lock.Lock()
 	vault, err := s.repo.Get(vaultId, nil)
+	if err == nil {
+		err = s.reserveVault(vaultId, "masterkey")
+	}
+	s.lock.Unlock()
+	if err == sql.ErrNoRows {
+		err = ErrVaultNotExist
+	}
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return ErrVaultNotExist
-		}
+		s.Audit(vaultId, AuditMasterkeyReveal, err)
 		return err
 	}
+	defer s.releaseVault(vaultId)
 
-	masterKey, err := s.GocryptfsShowVaultMasterkey(vault.Path, form.Password)
+	masterKey, err := s.GocryptfsShowVaultMasterkey(vault, form.Password)
+	s.Audit(vaultId, AuditMasterkeyReveal, err)
 	if err != nil {
 		return err
 	}
 
 	return ErrOk.WrapItem(masterKey)
 }
+
+// UpdateVaultTags replaces tags of given vault, the vault can be either locked or unlocked.
+func (s *ApiServer) UpdateVaultTags(c echo.Context) error {
+	// Pre-check on ID
+	vaultId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return ErrMalformedInput
+	}
+
+	var form struct {
+		Tags []string `json:"tags"`
+	}
+	if err := c.Bind(&form); err != nil {
+		return ErrMalformedInput
+	}
+
+	tags, err := s.SetVaultTags(vaultId, form.Tags)
+	if err != nil {
+		return err
+	}
+	return ErrOk.WrapList(tags)
+}
+
+// ListVaultGroups returns a list of all groups along with their vaults
+func (s *ApiServer) ListVaultGroups(_ echo.Context) error {
+	groups, err := s.ListGroups()
+	if err != nil {
+		return err
+	}
+	return ErrOk.WrapList(groups)
+}
+
+// CreateVaultGroup creates a new group of given `name`, with `vaults` in it.
+func (s *ApiServer) CreateVaultGroup(c echo.Context) error {
+	var form struct {
+		Name   string  `json:"name"`
+		Vaults []int64 `json:"vaults"` // optional
+	}
+	if err := c.Bind(&form); err != nil {
+		return ErrMalformedInput
+	}
+	if form.Vaults == nil {
+		form.Vaults = []int64{}
+	}
+
+	group, err := s.SaveGroup(models.Group{Name: form.Name}, form.Vaults)
+	if err != nil {
+		return err
+	}
+	return ErrOk.WrapItem(group)
+}
+
+// OperateOnGroup performs operations on all vaults of a group, or updates the group.
+// - `op` identifies the operation to perform
+func (s *ApiServer) OperateOnGroup(c echo.Context) error {
+	// Pre-check on ID
+	groupId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return ErrMalformedInput
+	}
+
+	var form struct {
+		Op       string   `json:"op"`       // update/unlock/lock
+		Name     string   `json:"name"`     // for `update` op only, optional, the group is not renamed if it's empty
+		Vaults   *[]int64 `json:"vaults"`   // for `update` op only, optional, vaults are kept if it's absent
+		Password string   `json:"password"` // for `unlock` op only, stored passwords are used if it's empty
+		Force    bool     `json:"force"`    // for `lock` op only, unmount lazily even if vaults are busy
+	}
+	if err := c.Bind(&form); err != nil {
+		return ErrMalformedInput
+	}
+
+	switch form.Op {
+	case "update":
+		var vaultIds []int64
+		if form.Vaults != nil {
+			vaultIds = append([]int64{}, *form.Vaults...)
+		}
+		group, err := s.SaveGroup(models.Group{ID: groupId, Name: form.Name}, vaultIds)
+		if err != nil {
+			return err
+		}
+		return ErrOk.WrapItem(group)
+	case "unlock":
+		results, err := s.UnlockGroup(groupId, form.Password)
+		if err != nil {
+			return err
+		}
+		return ErrOk.WrapList(results)
+	case "lock":
+		results, err := s.LockGroup(groupId, form.Force)
+		if err != nil {
+			return err
+		}
+		return ErrOk.WrapList(results)
+	default:
+		return ErrUnsupportedOperation
+	}
+}
+
+// RemoveVaultGroup deletes a group, its vaults are kept.
+func (s *ApiServer) RemoveVaultGroup(c echo.Context) error {
+	// Pre-check on ID
+	groupId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return ErrMalformedInput
+	}
+	if err := s.RemoveGroup(groupId); err != nil {
+		return err
+	}
+	return ErrOk
+}
+
+// StreamEvents streams vault events to the client as Server-Sent Events.
+// Each event is sent with its type as SSE event name, and its JSON representation as data.
+func (s *ApiServer) StreamEvents(c echo.Context) error {
+	events, cancel := s.Subscribe()
+	defer cancel()
+
+	resp := c.Response()
+	resp.Header().Set(echo.HeaderContentType, "text/event-stream")
+	resp.Header().Set(echo.HeaderCacheControl, "no-cache")
+	resp.Header().Set(echo.HeaderConnection, "keep-alive")
+	resp.WriteHeader(http.StatusOK)
+	resp.Flush()
+
+	// Send comments periodically so that idle connections are kept alive by proxies and browsers
+	keepAlive := time.NewTicker(time.Second * 15)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(resp, ": keepalive\n\n"); err != nil {
+				return nil
+			}
+			resp.Flush()
+		case event, ok := <-events:
+			if !ok { // Server is shutting down
+				return nil
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				logger.Error().Err(err).Str("type", event.Type).Msg("Failed to encode event")
+				continue
+			}
+			if _, err := fmt.Fprintf(resp, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+				return nil
+			}
+			resp.Flush()
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package server
+
+import (
+	"Cloak/config"
+	"Cloak/models"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/suite"
+)
+
+// vaultColumns are columns of the vaults table, in the order of `models.Vault` fields.
+var vaultColumns = []string{"id", "path", "mountpoint", "autoreveal", "readonly", "idletimeout", "maxlifetime", "locksuspend", "reverse", "exclude", "excludewildcard", "rememberpassword", "debugmount", "mountoptions", "name", "notes", "icon", "identity", "backend"}
+
+// vaultRows returns given vaults as rows of the vaults table.
+func vaultRows(vaults ...models.Vault) *sqlmock.Rows {
+	list := func(l models.StringList) string {
+		data, _ := json.Marshal([]string(l))
+		return string(data)
+	}
+	rows := sqlmock.NewRows(vaultColumns)
+	for _, v := range vaults {
+		rows.AddRow(v.ID, v.Path, v.MountPoint, v.AutoReveal, v.ReadOnly, v.IdleTimeout, v.MaxLifetime, v.LockSuspend, v.Reverse,
+			list(v.Exclude), list(v.ExcludeWildcard), v.RememberPassword, v.DebugMount, list(v.MountOptions),
+			v.Name, v.Notes, v.Icon, v.Identity, v.Backend)
+	}
+	return rows
+}
+
+// newTestManager creates a vault manager backed by a mocked database and an empty config in a temporary directory.
+func newTestManager(t *testing.T) (*VaultManager, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	cfg, err := config.NewConfigurator(filepath.Join(t.TempDir(), "options.ini"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Load(); err != nil {
+		t.Fatal(err)
+	}
+	return NewVaultManager(models.NewVaultRepo(db), cfg, false, make(chan map[string]string, 10)), mock
+}
+
+// call calls an API handler for vault `id` with given JSON body, and returns its response.
+func call(handler echo.HandlerFunc, id string, body string) error {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	c := echo.New().NewContext(req, httptest.NewRecorder())
+	c.SetParamNames("id")
+	c.SetParamValues(id)
+	return handler(c)
+}
+
+type serverTestSuite struct {
+	suite.Suite
+	s    *ApiServer
+	mock sqlmock.Sqlmock
+	dir  string
+}
+
+func (s *serverTestSuite) SetupTest() {
+	m, mock := newTestManager(s.T())
+	s.s, s.mock = &ApiServer{VaultManager: m}, mock
+	s.dir = s.T().TempDir()
+}
+
+func (s *serverTestSuite) AfterTest(_, _ string) {
+	s.Require().NoError(s.mock.ExpectationsWereMet())
+}
+
+// vaultDir creates a vault directory with a config of given name under the temporary directory.
+func (s *serverTestSuite) vaultDir(path string, confName string) string {
+	dir := filepath.Join(s.dir, path)
+	s.Require().NoError(os.MkdirAll(dir, 0700))
+	s.Require().NoError(os.WriteFile(filepath.Join(dir, confName), []byte("{}"), 0600))
+	return dir
+}
+
+func (s *serverTestSuite) Test_01_ListMissing() {
+	present := s.vaultDir("present", "gocryptfs.conf")
+	cryfs := s.vaultDir("cryfs", "cryfs.config")
+	// A gocryptfs config doesn't make the reverse vault present
+	reverse := s.vaultDir("reverse", "gocryptfs.conf")
+	s.mock.ExpectQuery(`SELECT \* FROM vaults`).WillReturnRows(vaultRows(
+		models.Vault{ID: 1, Path: present, Backend: "gocryptfs"},
+		models.Vault{ID: 2, Path: filepath.Join(s.dir, "moved"), Backend: "gocryptfs"},
+		models.Vault{ID: 3, Path: cryfs, Backend: "cryfs"},
+		models.Vault{ID: 4, Path: reverse, Backend: "gocryptfs", Reverse: true},
+		models.Vault{ID: 5, Path: filepath.Join(s.dir, "unlocked"), Backend: "gocryptfs"},
+	))
+	s.mock.ExpectQuery(`SELECT \* FROM groups`).WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+	s.mock.ExpectQuery(`SELECT groupid, vaultid FROM vault_groups`).WillReturnRows(sqlmock.NewRows([]string{"groupid", "vaultid"}))
+	s.mock.ExpectQuery(`SELECT vaultid, tag FROM vault_tags`).WillReturnRows(sqlmock.NewRows([]string{"vaultid", "tag"}))
+	// Unlocked vaults are in use, whatever happened to their directories
+	s.s.mountPoints[5] = filepath.Join(s.dir, "mnt")
+
+	err := s.s.ListVaults(nil)
+	s.Require().Equal(ErrOk.Code, toApiError(err).Code)
+	vaults := err.(*DataContainer).Items.([]VaultInfo)
+	s.Require().Len(vaults, 5)
+	missing := make(map[int64]bool)
+	for _, v := range vaults {
+		missing[v.ID] = v.Missing
+	}
+	s.Equal(map[int64]bool{1: false, 2: true, 3: false, 4: true, 5: false}, missing)
+	s.Equal("unlocked", vaults[4].State)
+}
+
+func (s *serverTestSuite) Test_02_Relocate() {
+	vault := models.Vault{ID: 1, Path: filepath.Join(s.dir, "old"), Backend: "gocryptfs", Name: "work"}
+	other := models.Vault{ID: 2, Path: s.vaultDir("roots/a/other", "gocryptfs.conf"), Backend: "gocryptfs"}
+	s.vaultDir("roots/a/cryfs", "cryfs.config")
+	moved := s.vaultDir("roots/b/moved", "gocryptfs.conf")
+
+	// Directories of other vaults and of other backends are no candidates
+	s.mock.ExpectQuery(`SELECT \* FROM vaults WHERE id = \?`).WithArgs(1).WillReturnRows(vaultRows(vault))
+	s.mock.ExpectQuery(`SELECT \* FROM vaults ORDER BY`).WillReturnRows(vaultRows(vault, other))
+	s.mock.ExpectExec(`UPDATE vaults SET`).
+		WithArgs(moved, "", false, false, 0, 0, false, false, "[]", "[]", false, false, "[]", "work", "", "", "", "gocryptfs", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	relocated := vault
+	relocated.Path = moved
+	s.mock.ExpectQuery(`SELECT \* FROM vaults WHERE id = \?`).WithArgs(1).WillReturnRows(vaultRows(relocated))
+	events, unsubscribe := s.s.Subscribe()
+	defer unsubscribe()
+
+	roots := `{"roots": ["` + filepath.Join(s.dir, "roots") + `"]}`
+	err := call(s.s.RelocateVault, "1", roots)
+	s.Require().Equal(ErrOk.Code, toApiError(err).Code, "%v", err)
+	s.Equal(moved, err.(*DataContainer).Item.(VaultInfo).Path)
+	event := <-events
+	s.Equal(EventVaultOptionsChanged, event.Type)
+	s.EqualValues(1, event.VaultID)
+}
+
+func (s *serverTestSuite) Test_03_RelocateRejected() {
+	vault := models.Vault{ID: 1, Path: filepath.Join(s.dir, "old"), Backend: "gocryptfs"}
+	search := func(vaults ...models.Vault) {
+		s.mock.ExpectQuery(`SELECT \* FROM vaults WHERE id = \?`).WithArgs(1).WillReturnRows(vaultRows(vault))
+		s.mock.ExpectQuery(`SELECT \* FROM vaults ORDER BY`).WillReturnRows(vaultRows(append([]models.Vault{vault}, vaults...)...))
+	}
+	roots := `{"roots": ["` + filepath.Join(s.dir, "roots") + `"], "depth": 2}`
+
+	// Malformed requests
+	for _, body := range []string{`{}`, `{"roots": ["relative"]}`, `{"roots": ["/"], "depth": 9}`} {
+		s.Equal(ErrMalformedInput.Code, toApiError(call(s.s.RelocateVault, "1", body)).Code, body)
+	}
+	s.mock.ExpectQuery(`SELECT \* FROM vaults WHERE id = \?`).WithArgs(7).WillReturnRows(vaultRows())
+	s.Equal(ErrVaultNotExist.Code, toApiError(call(s.s.RelocateVault, "7", roots)).Code)
+
+	search()
+	s.Equal(ErrVaultNotFound.Code, toApiError(call(s.s.RelocateVault, "1", roots)).Code)
+
+	// Too deep to be found
+	s.vaultDir("roots/a/b/deep", "gocryptfs.conf")
+	search()
+	s.Equal(ErrVaultNotFound.Code, toApiError(call(s.s.RelocateVault, "1", roots)).Code)
+
+	s.vaultDir("roots/a/one", "gocryptfs.conf")
+	s.vaultDir("roots/b", "gocryptfs.conf")
+	search()
+	err := call(s.s.RelocateVault, "1", roots)
+	s.Require().Equal(ErrVaultAmbiguous.Code, toApiError(err).Code)
+	s.Len(err.(*DataContainer).Items, 2)
+
+	// Vaults must be locked and idle
+	s.Require().NoError(os.RemoveAll(filepath.Join(s.dir, "roots", "b")))
+	search()
+	s.s.mountPoints[1] = filepath.Join(s.dir, "mnt")
+	s.Equal(ErrVaultAlreadyUnlocked.Code, toApiError(call(s.s.RelocateVault, "1", roots)).Code)
+	delete(s.s.mountPoints, 1)
+	search()
+	s.s.busy[1] = "password"
+	s.Equal(ErrVaultOperationRunning.Code, toApiError(call(s.s.RelocateVault, "1", roots)).Code)
+}
+
+func Test_Server(t *testing.T) {
+	suite.Run(t, new(serverTestSuite))
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"Cloak/extension"
+	"Cloak/i18n"
+	"Cloak/models"
+	"Cloak/server"
+	"fmt"
+	"fyne.io/systray"
+	"github.com/pkg/browser"
+	"time"
+)
+
+// How long to wait for more vault changes before rebuilding the tray menu, so bursts of events cause one rebuild
+const trayRebuildDelay = time.Millisecond * 200
+
+// Vault events after which the tray menu is rebuilt
+var trayEvents = map[string]bool{
+	server.EventVaultAdded:          true,
+	server.EventVaultRemoved:        true,
+	server.EventVaultUnlocked:       true,
+	server.EventVaultLocked:         true,
+	server.EventVaultExited:         true,
+	server.EventVaultOptionsChanged: true,
+}
+
+// buildTray builds the tray menu from scratch, with a submenu for each vault.
+// Items of the previous menu are removed, which stops goroutines handling their clicks.
+func (a *App) buildTray() {
+	systray.ResetMenu()
+	translator := i18n.GetLocalizer()
+
+	onClick(systray.AddMenuItem(translator.T("open"), ""), func() {
+		browser.OpenURL(a.apiServer.GetAccessUrl())
+	})
+
+	vaults, err := a.repo.List(nil)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to list vaults for tray menu")
+	}
+	if len(vaults) > 0 {
+		systray.AddSeparator()
+	}
+	anyUnlocked := false
+	for _, vault := range vaults {
+		if a.addVaultMenu(vault) {
+			anyUnlocked = true
+		}
+	}
+
+	systray.AddSeparator()
+	lockAllMenu := systray.AddMenuItem(translator.T("lock_all"), "")
+	if !anyUnlocked {
+		lockAllMenu.Disable()
+	}
+	onClick(lockAllMenu, func() {
+		a.apiServer.LockAllVaults("", nil)
+	})
+	onClick(systray.AddMenuItem(translator.T("quit"), ""), systray.Quit)
+}
+
+// addVaultMenu adds a submenu for given vault, showing its state and operations on it.
+// It reports whether the vault is unlocked.
+func (a *App) addVaultMenu(vault models.Vault) bool {
+	translator := i18n.GetLocalizer()
+	mountPoint, unlocked := a.apiServer.MountPoint(vault.ID)
+	title := translator.T("vault.locked")
+	if unlocked {
+		title = translator.T("vault.unlocked")
+	}
+
+	vaultMenu := systray.AddMenuItem(fmt.Sprintf(title, vault.Label()), vault.Path)
+	unlockMenu := vaultMenu.AddSubMenuItem(translator.T("vault.unlock"), "")
+	lockMenu := vaultMenu.AddSubMenuItem(translator.T("vault.lock"), "")
+	revealMountPointMenu := vaultMenu.AddSubMenuItem(translator.T("vault.reveal_mountpoint"), mountPoint)
+	revealVaultMenu := vaultMenu.AddSubMenuItem(translator.T("vault.reveal_vault"), vault.Path)
+	if unlocked {
+		unlockMenu.Disable()
+	} else {
+		lockMenu.Disable()
+		revealMountPointMenu.Disable()
+	}
+
+	// Passwords are never asked for in tray, the UI does it
+	onClick(unlockMenu, func() {
+		browser.OpenURL(a.apiServer.GetVaultUrl(vault.ID))
+	})
+	onClick(lockMenu, func() {
+		err := a.apiServer.LockVault(vault.ID, false)
+		a.apiServer.Audit(vault.ID, server.AuditLock, err)
+		if err != nil {
+			logger.Error().Err(err).
+				Int64("vaultId", vault.ID).
+				Str("vaultPath", vault.Path).
+				Msg("Failed to lock vault from tray")
+		}
+	})
+	onClick(revealMountPointMenu, func() {
+		extension.OpenPath(mountPoint)
+	})
+	onClick(revealVaultMenu, func() {
+		extension.OpenPath(vault.Path)
+	})
+	return unlocked
+}
+
+// onClick calls `f` each time given menu item is clicked, until the item is removed.
+func onClick(item *systray.MenuItem, f func()) {
+	go func() {
+		for range item.ClickedCh {
+			f()
+		}
+	}()
+}